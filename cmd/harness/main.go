@@ -0,0 +1,76 @@
+// Command harness replays fixture Pub/Sub notifications through the
+// pipeline against the Firestore emulator and a fake Gmail mailbox:
+//
+//	FIRESTORE_EMULATOR_HOST=localhost:8081 go run ./cmd/harness testdata/harness/*.json
+//
+// It exits non-zero when any scenario's expectations don't hold.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/harness"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: harness [fixture.json ...]  (default testdata/harness/*.json)")
+	}
+	flag.Parse()
+	logger.Init()
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths, _ = filepath.Glob("testdata/harness/*.json")
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "no fixtures found")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	client, err := harness.NewClient(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer client.Close()
+
+	if err := email.LoadTemplates(ctx, nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		scenario, err := harness.Load(path)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", path, err)
+			continue
+		}
+		restore := harness.Setenv(scenario)
+		res := harness.Run(ctx, client, scenario)
+		restore()
+		if res.Passed() {
+			fmt.Printf("PASS  %s (%s)\n", res.Name, res.Duration.Round(1e6))
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %s\n", res.Name)
+		for _, f := range res.Failures {
+			fmt.Printf("      %s\n", f)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d scenario(s) failed\n", failed, len(paths))
+		client.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/config"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/tenant"
+
+	"cloud.google.com/go/firestore"
+	"github.com/spf13/cobra"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCmd builds the CLI. Run without a subcommand it serves HTTP, so the
+// container's existing entrypoint keeps working.
+func newRootCmd() *cobra.Command {
+	var port string
+	root := &cobra.Command{
+		Use:           "server",
+		Short:         "Voicemail transcription service and operational tools",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The config file can set LOG_LEVEL, so it's read before logging starts.
+			applied, err := config.Load()
+			logger.Init()
+			if err != nil {
+				logger.Error.Printf("❌ %v", err)
+				return err
+			}
+			if len(applied) > 0 {
+				logger.Info.Printf("📄 Loaded %d setting(s) from %s: %s", len(applied), config.Path(), strings.Join(applied, ", "))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serve(resolvePort(port))
+			return nil
+		},
+	}
+	root.Flags().StringVar(&port, "port", "", "port to listen on (default $PORT or 8080)")
+
+	root.AddCommand(
+		newServeCmd(),
+		newWatchCmd(),
+		newAuthCmd(),
+		newHistoryCmd(),
+		newSeedHistoryCmd("seed-history"),
+		newReplayCmd(),
+		newTranscribeCmd(),
+		newDoctorCmd(),
+	)
+	return root
+}
+
+func newServeCmd() *cobra.Command {
+	var port string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serve(resolvePort(port))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&port, "port", "", "port to listen on (default $PORT or 8080)")
+	return cmd
+}
+
+// resolvePort prefers the --port flag, then PORT, then 8080.
+func resolvePort(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+	return "8080"
+}
+
+// gmailServices is shared by everything in the process that talks to Gmail,
+// so each mailbox is authenticated once.
+var gmailServices = auth.NewRegistry()
+
+// connect creates the Gmail and Firestore clients and loads the tenant config
+// and email templates, as every command that processes mail needs them.
+func connect(ctx context.Context) (*gmailapi.Service, *firestore.Client, error) {
+	mailbox := os.Getenv("EMAIL_RESPONSE_ADDRESS")
+	if mailbox == "" {
+		return nil, nil, fmt.Errorf("failed to load Gmail service: EMAIL_RESPONSE_ADDRESS must be set")
+	}
+	srv, err := gmailServices.Service(ctx, mailbox)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load Gmail service: %w", err)
+	}
+
+	fsClient, err := firestore.NewClient(ctx, os.Getenv("GCP_PROJECT_ID"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize Firestore client: %w", err)
+	}
+
+	if err := tenant.Load(ctx, fsClient); err != nil {
+		logger.Warn.Printf("⚠️ Continuing without tenant config: %v", err)
+	}
+
+	if err := email.LoadTemplates(ctx, fsClient); err != nil {
+		fsClient.Close()
+		return nil, nil, fmt.Errorf("failed to load email templates: %w", err)
+	}
+	return srv, fsClient, nil
+}
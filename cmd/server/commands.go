@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/gmail"
+
+	"github.com/spf13/cobra"
+)
+
+func newWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Manage the Gmail push notification watch",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "setup",
+		Short: "Create or renew the Gmail watch on PUBSUB_TOPIC_NAME",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			srv, fsClient, err := connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer fsClient.Close()
+
+			expires, renewed, err := gmail.NewHandler(srv, fsClient, nil).EnsureWatch(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+			if !renewed {
+				fmt.Println("Another instance is renewing the watch; try again shortly.")
+			}
+			fmt.Printf("Watch active until %s\n", expires.Format(time.RFC3339))
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "stop",
+		Short: "Stop the Gmail watch so no more notifications are published",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			srv, fsClient, err := connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer fsClient.Close()
+
+			if err := gmail.NewHandler(srv, fsClient, nil).StopWatch(cmd.Context()); err != nil {
+				return err
+			}
+			fmt.Println("Watch stopped")
+			return nil
+		},
+	})
+	return cmd
+}
+
+func newAuthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage Gmail credentials",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "login",
+		Short: "Authorize a Gmail account for GMAIL_AUTH_MODE=oauth",
+		Long: "Run the OAuth installed-app flow for the client stored in " + auth.OAuthClientSecret + "\n" +
+			"and save the resulting refresh token to " + auth.OAuthTokenSecret + " in Secret Manager.\n" +
+			"Use this for personal Gmail accounts that can't use domain-wide delegation.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := auth.Login(cmd.Context(), func(url string) {
+				fmt.Printf("Open this URL in a browser on this machine and grant access:\n\n%s\n\n", url)
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println("Token saved. Set GMAIL_AUTH_MODE=oauth to use it.")
+			return nil
+		},
+	})
+	return cmd
+}
+
+func newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Inspect and reset the stored Gmail history ID",
+	}
+	cmd.AddCommand(newSeedHistoryCmd("seed"))
+	return cmd
+}
+
+// newSeedHistoryCmd builds the history seeding command, available both as
+// "history seed" and the top-level "seed-history".
+func newSeedHistoryCmd(use string) *cobra.Command {
+	var (
+		historyID uint64
+		force     bool
+	)
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: "Store the latest message's history ID as the starting point",
+		Long: "Fetch the latest Gmail message and store its history ID in Firestore, so\n" +
+			"processing resumes from now. The stored ID normally only moves forward;\n" +
+			"use --force to overwrite it after an incident.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			srv, fsClient, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer fsClient.Close()
+
+			mailbox := os.Getenv("EMAIL_RESPONSE_ADDRESS")
+			previous, err := gmail.LoadHistoryIDFromFirestore(ctx, fsClient, mailbox)
+			if err != nil {
+				fmt.Printf("No stored history ID for %s (%v)\n", mailbox, err)
+			} else {
+				fmt.Printf("Stored history ID for %s: %d\n", mailbox, previous)
+			}
+
+			if historyID == 0 {
+				if historyID, err = gmail.LatestHistoryID(ctx, gmail.NewClient(srv)); err != nil {
+					return err
+				}
+			}
+
+			if force {
+				err = gmail.ResetHistoryID(ctx, fsClient, mailbox, historyID)
+			} else {
+				err = gmail.SaveHistoryIDToFirestore(ctx, fsClient, mailbox, historyID)
+			}
+			if err != nil {
+				return err
+			}
+
+			current, err := gmail.LoadHistoryIDFromFirestore(ctx, fsClient, mailbox)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("History ID for %s is now %d\n", mailbox, current)
+			if current != historyID {
+				fmt.Println("The stored ID was already ahead; use --force to move it back.")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Uint64Var(&historyID, "history-id", 0, "store this history ID instead of the latest message's")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite the stored ID even if it is newer")
+	return cmd
+}
+
+func newReplayCmd() *cobra.Command {
+	var (
+		start, end uint64
+		from, to   string
+	)
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Re-run processing over a history ID or date range",
+		Long: "Re-run processing over a window of mailbox history, e.g. after an outage\n" +
+			"where notifications were dropped. Already processed messages are skipped.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if start == 0 && from == "" {
+				return fmt.Errorf("--start or --from is required")
+			}
+
+			srv, fsClient, err := connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer fsClient.Close()
+			h := gmail.NewHandler(srv, fsClient, nil)
+
+			var ids []string
+			if start != 0 {
+				ids, err = h.ReplayHistory(cmd.Context(), start, end)
+			} else {
+				fromTime, perr := gmail.ParseReplayTime(from)
+				if perr != nil {
+					return fmt.Errorf("invalid --from: %w", perr)
+				}
+				var toTime time.Time
+				if to != "" {
+					if toTime, perr = gmail.ParseReplayTime(to); perr != nil {
+						return fmt.Errorf("invalid --to: %w", perr)
+					}
+				}
+				ids, err = h.ReplayRange(cmd.Context(), fromTime, toTime)
+			}
+			fmt.Printf("Replayed %d message(s)\n", len(ids))
+			return err
+		},
+	}
+	cmd.Flags().Uint64Var(&start, "start", 0, "first history ID to replay")
+	cmd.Flags().Uint64Var(&end, "end", 0, "last history ID to replay (default: latest)")
+	cmd.Flags().StringVar(&from, "from", "", "replay messages received after this time (RFC 3339 or YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "replay messages received before this time")
+	return cmd
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/gmail"
+	"voicemail-transcriber-production/internal/transcriber"
+
+	"cloud.google.com/go/firestore"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/pubsub/v1"
+)
+
+// gmailPushAccount is the service account Gmail publishes watch
+// notifications as; it needs publish rights on the topic.
+const gmailPushAccount = "serviceAccount:gmail-api-push@system.gserviceaccount.com"
+
+// publisherRoles grant pubsub.topics.publish.
+var publisherRoles = []string{"roles/pubsub.publisher", "roles/pubsub.editor", "roles/pubsub.admin", "roles/editor", "roles/owner"}
+
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check credentials and access to Gmail, Pub/Sub, Firestore and Deepgram",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			checks := []doctorCheck{
+				{"Gmail delegation", func(ctx context.Context) error {
+					_, err := auth.LoadGmailServices(ctx)
+					return err
+				}},
+				{"Pub/Sub topic", checkTopic},
+				{"Firestore write", checkFirestoreWrite},
+				{"Deepgram API key", transcriber.Ping},
+			}
+
+			failed := 0
+			for _, c := range checks {
+				ctx, cancel := context.WithTimeout(cmd.Context(), 20*time.Second)
+				err := c.run(ctx)
+				cancel()
+				if err != nil {
+					failed++
+					fmt.Printf("FAIL  %-18s %v\n", c.name, err)
+					continue
+				}
+				fmt.Printf("PASS  %s\n", c.name)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+			}
+			fmt.Println("All checks passed")
+			return nil
+		},
+	}
+}
+
+// checkTopic verifies the watch topic exists and that Gmail's push account
+// is allowed to publish to it.
+func checkTopic(ctx context.Context) error {
+	topic, err := gmail.WatchTopic()
+	if err != nil {
+		return err
+	}
+	svc, err := pubsub.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	if _, err := svc.Projects.Topics.Get(topic).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("topic %s: %w", topic, err)
+	}
+
+	policy, err := svc.Projects.Topics.GetIamPolicy(topic).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to read IAM policy for %s: %w", topic, err)
+	}
+	for _, b := range policy.Bindings {
+		if slices.Contains(publisherRoles, b.Role) && slices.Contains(b.Members, gmailPushAccount) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s cannot publish to %s; grant it roles/pubsub.publisher", gmailPushAccount, topic)
+}
+
+// checkFirestoreWrite writes and then deletes a scratch document.
+func checkFirestoreWrite(ctx context.Context) error {
+	client, err := firestore.NewClient(ctx, os.Getenv("GCP_PROJECT_ID"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize Firestore client: %w", err)
+	}
+	defer client.Close()
+
+	host, _ := os.Hostname()
+	doc := client.Collection("doctor_checks").Doc(fmt.Sprintf("%s-%d", host, time.Now().UnixNano()))
+	if _, err := doc.Set(ctx, map[string]interface{}{"checkedAt": time.Now()}); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	if _, err := doc.Delete(ctx); err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/gmail"
+	"voicemail-transcriber-production/internal/transcriber"
+
+	"google.golang.org/api/iterator"
+)
+
+// healthCheckTimeout bounds each dependency check in deep mode.
+const healthCheckTimeout = 3 * time.Second
+
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latencyMs"`
+}
+
+// checkDependencies pings Firestore, Gmail and Deepgram concurrently and
+// reports each one's status, along with whether the Gmail watch has lapsed
+// when one is managed.
+func (s *AppState) checkDependencies(ctx context.Context) map[string]dependencyStatus {
+	checks := map[string]func(context.Context) error{
+		"firestore": func(ctx context.Context) error {
+			iter := s.fsClient.Collection("gmail_state").Limit(1).Documents(ctx)
+			defer iter.Stop()
+			if _, err := iter.Next(); err != nil && err != iterator.Done {
+				return err
+			}
+			return nil
+		},
+		"gmail": func(ctx context.Context) error {
+			_, err := s.srv.Users.GetProfile("me").Context(ctx).Do()
+			return err
+		},
+		"deepgram": transcriber.Ping,
+	}
+	if os.Getenv("PUBSUB_TOPIC_NAME") != "" && !gmail.PollMode() {
+		checks["gmail_watch"] = func(ctx context.Context) error {
+			expires, err := gmail.LoadWatchExpiration(ctx, s.fsClient, s.handler.Mailbox)
+			if err != nil {
+				return err
+			}
+			if expires.IsZero() {
+				return fmt.Errorf("no watch recorded")
+			}
+			if time.Now().After(expires) {
+				return fmt.Errorf("watch lapsed at %s", expires.Format(time.RFC3339))
+			}
+			return nil
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]dependencyStatus, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			status := dependencyStatus{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				status.Status, status.Error = "error", err.Error()
+			}
+
+			mu.Lock()
+			results[name] = status
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
+
+// handleHealth reports readiness. With ?deep=true it also checks each
+// dependency and returns 503 when any of them fails.
+func handleHealth(state *AppState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := "initializing"
+		if state.isReady() {
+			status = "ok"
+		}
+		resp := map[string]interface{}{
+			"status": status,
+			"time":   time.Now().Format(time.RFC3339),
+		}
+		if state.isReady() {
+			if expires := state.handler.WatchExpiry(); !expires.IsZero() {
+				resp["watchExpiresAt"] = expires.Format(time.RFC3339)
+			}
+		}
+
+		code := http.StatusOK
+		if r.URL.Query().Get("deep") == "true" {
+			if !state.isReady() {
+				code = http.StatusServiceUnavailable
+			} else {
+				deps := state.checkDependencies(r.Context())
+				for _, d := range deps {
+					if d.Status != "ok" {
+						resp["status"] = "degraded"
+						code = http.StatusServiceUnavailable
+					}
+				}
+				resp["dependencies"] = deps
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
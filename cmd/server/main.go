@@ -8,15 +8,29 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"voicemail-transcriber-production/internal/access"
+	"voicemail-transcriber-production/internal/api"
 	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/config"
+	"voicemail-transcriber-production/internal/dashboard"
+	"voicemail-transcriber-production/internal/email"
 	"voicemail-transcriber-production/internal/gmail"
 	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/metrics"
+	"voicemail-transcriber-production/internal/ratelimit"
+	"voicemail-transcriber-production/internal/report"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/routing"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/tasks"
+	"voicemail-transcriber-production/internal/tenant"
 
 	"cloud.google.com/go/firestore"
-	"github.com/google/uuid"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	gmailapi "google.golang.org/api/gmail/v1"
@@ -25,35 +39,270 @@ import (
 type AppState struct {
 	srv       *gmailapi.Service
 	fsClient  *firestore.Client
+	handler   *gmail.Handler
+	api       http.Handler
+	dashboard http.Handler
 	ready     bool
 	readyLock sync.RWMutex
-	initOnce  sync.Once
+
+	// initLock guards the initialization state below. A failed attempt is
+	// retried by a later request once initRetryAt has passed.
+	initLock    sync.Mutex
+	initialized bool
+	initErr     error
+	initRetryAt time.Time
+	initBackoff time.Duration
+
+	// background is cancelled on shutdown to stop the periodic jobs.
+	background context.Context
+	stop       context.CancelFunc
+}
+
+func newAppState() *AppState {
+	ctx, stop := context.WithCancel(context.Background())
+	return &AppState{background: ctx, stop: stop}
+}
+
+// Close stops the background jobs and releases the Firestore client.
+func (s *AppState) Close() {
+	s.setReady(false)
+	s.stop()
+	if s.fsClient != nil {
+		if err := s.fsClient.Close(); err != nil {
+			logger.Warn.Printf("⚠️ Failed to close Firestore client: %v", err)
+		}
+	}
+}
+
+// Bounds on the wait between initialization attempts, which doubles after
+// each failure.
+const (
+	minInitBackoff = 5 * time.Second
+	maxInitBackoff = 5 * time.Minute
+)
+
+// initialize sets the application up on first use. A failure, such as a
+// transient Secret Manager error, is returned to callers until the backoff
+// has passed, after which the next caller tries again. Setup runs on the
+// background context, not the request's, since the clients it creates
+// outlive the request.
+func (s *AppState) initialize() error {
+	s.initLock.Lock()
+	defer s.initLock.Unlock()
+	if s.initialized {
+		return nil
+	}
+	if s.initErr != nil && time.Now().Before(s.initRetryAt) {
+		return s.initErr
+	}
+
+	if err := s.setup(s.background); err != nil {
+		if s.fsClient != nil {
+			s.fsClient.Close()
+			s.fsClient = nil
+		}
+		s.initBackoff = min(max(2*s.initBackoff, minInitBackoff), maxInitBackoff)
+		s.initRetryAt = time.Now().Add(s.initBackoff)
+		s.initErr = err
+		logger.Warn.Printf("⚠️ Initialization will be retried after %s", s.initBackoff)
+		return err
+	}
+	s.initialized = true
+	s.initErr = nil
+	return nil
+}
+
+// setup connects to Gmail and Firestore and starts the background jobs.
+func (s *AppState) setup(ctx context.Context) error {
+	auth.SetBackground(ctx)
+	var err error
+	s.srv, s.fsClient, err = connect(ctx)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		return err
+	}
+
+	s.handler = gmail.NewHandler(s.srv, s.fsClient, nil)
+	if err := gmail.InitFirestoreHistory(ctx, s.handler.Gmail, s.handler.History, s.handler.Mailbox); err != nil {
+		logger.Error.Printf("❌ Failed to initialize Firestore history: %v", err)
+		return err
+	}
+
+	queue, err := tasks.New(ctx)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		return err
+	}
+	if queue != nil {
+		s.handler.Queue = queue
+	}
+
+	s.api = api.NewHandler(s.fsClient)
+	s.dashboard = dashboard.NewHandler(s.fsClient)
+	go s.cleanupLoop(s.background)
+	go s.deferredLoop(s.background)
+	go s.flushOutbox(s.background)
+	if gmail.BounceInterval() > 0 {
+		go s.bounceLoop(s.background)
+	}
+	if report.Enabled() {
+		go s.reportLoop(s.background)
+	}
+	go secret.WatchRotation(s.background)
+	if gmail.PollMode() {
+		logger.Info.Printf("📥 Polling for new mail every %s instead of using a Gmail watch", gmail.PollInterval())
+		go s.pollLoop(s.background)
+	} else if os.Getenv("PUBSUB_TOPIC_NAME") != "" {
+		go s.watchLoop(s.background)
+	}
+	go tenant.Watch(s.background, s.fsClient)
+	go email.WatchTemplates(s.background, s.fsClient)
+	go s.reloadOnSignal(s.background)
+
+	if gmail.DryRun() {
+		logger.Warn.Println("🧪 DRY_RUN is set: voicemails will be transcribed and stored but not delivered")
+	}
+	s.setReady(true)
+	logger.Info.Println("✅ Application initialization complete")
+	return nil
 }
 
-func (s *AppState) initialize(ctx context.Context) error {
-	var initErr error
-	s.initOnce.Do(func() {
-		s.srv, initErr = auth.LoadGmailService(ctx)
-		if initErr != nil {
-			logger.Error.Printf("Failed to load Gmail service: %v", initErr)
+// cleanupLoop periodically removes expired processed-message claims.
+func (s *AppState) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := s.handler.Dedup.Cleanup(ctx); err != nil {
+			logger.Error.Printf("❌ Dedup cleanup failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
+	}
+}
+
+// watchLoop checks the Gmail watch hourly and renews it when it is close to
+// expiring, replacing a fixed renewal schedule.
+func (s *AppState) watchLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
 
-		s.fsClient, initErr = firestore.NewClient(ctx, os.Getenv("GCP_PROJECT_ID"))
-		if initErr != nil {
-			logger.Error.Printf("Failed to initialize Firestore client: %v", initErr)
+	for {
+		expires, renewed, err := s.handler.EnsureWatch(ctx, false)
+		switch {
+		case err != nil:
+			logger.Error.Printf("❌ Gmail watch renewal failed: %v", err)
+		case renewed:
+			logger.Info.Printf("👀 Renewed Gmail watch until %s", expires.Format(time.RFC3339))
+		}
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
+	}
+}
 
-		if initErr = gmail.InitFirestoreHistory(ctx, s.srv, s.fsClient); initErr != nil {
-			logger.Error.Printf("❌ Failed to initialize Firestore history: %v", initErr)
+// pollLoop picks up unread mail on POLL_INTERVAL when INGEST_MODE=poll.
+func (s *AppState) pollLoop(ctx context.Context) {
+	ticker := time.NewTicker(gmail.PollInterval())
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.handler.Poll(ctx); err != nil {
+			logger.Error.Printf("❌ Polling failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
+	}
+}
 
-		s.setReady(true)
-		logger.Info.Println("✅ Application initialization complete")
-	})
-	return initErr
+// deferredLoop retries messages queued while transcription was unavailable.
+func (s *AppState) deferredLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.handler.RetryDeferred(ctx); err != nil {
+			logger.Error.Printf("❌ %v", err)
+		}
+	}
+}
+
+// bounceLoop checks for bounced transcription emails on
+// BOUNCE_CHECK_INTERVAL.
+func (s *AppState) bounceLoop(ctx context.Context) {
+	ticker := time.NewTicker(gmail.BounceInterval())
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.handler.CheckBounces(ctx); err != nil {
+			logger.Error.Printf("❌ Bounce check failed: %v", err)
+		} else if n > 0 {
+			logger.Warn.Printf("📭 Found %d bounced transcription email(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flushOutbox sends transcription emails a previous run composed but never
+// sent.
+func (s *AppState) flushOutbox(ctx context.Context) {
+	sent, err := email.NewOutbox(s.fsClient, email.WithFallback(email.Backend(s.handler.Gmail))).Flush(ctx)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+	}
+	if sent > 0 {
+		logger.Info.Printf("📤 Flushed %d transcription email(s) from the outbox", sent)
+	}
+}
+
+// reloadOnSignal re-reads the config file, tenant config, routing rules and
+// email templates on SIGHUP, without restarting the service.
+func (s *AppState) reloadOnSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		logger.Info.Println("🔄 SIGHUP received, reloading configuration")
+		if applied, err := config.Load(); err != nil {
+			logger.Error.Printf("❌ Keeping previous config file settings: %v", err)
+		} else if len(applied) > 0 {
+			logger.Info.Printf("📄 Reloaded %d setting(s) from %s", len(applied), config.Path())
+		}
+		if err := logger.SetLevel(os.Getenv("LOG_LEVEL")); err != nil {
+			logger.Warn.Printf("⚠️ Invalid LOG_LEVEL: %v", err)
+		}
+		if err := tenant.Load(ctx, s.fsClient); err != nil {
+			logger.Warn.Printf("⚠️ Keeping previous tenant config: %v", err)
+		}
+		routing.Invalidate()
+		if err := email.LoadTemplates(ctx, s.fsClient); err != nil {
+			logger.Error.Printf("❌ Keeping previous email templates: %v", err)
+		}
+	}
 }
 
 func (s *AppState) setReady(ready bool) {
@@ -62,10 +311,12 @@ func (s *AppState) setReady(ready bool) {
 	s.ready = ready
 }
 
+// isReady reports whether initialization finished and the Gmail
+// credentials are currently working.
 func (s *AppState) isReady() bool {
 	s.readyLock.RLock()
 	defer s.readyLock.RUnlock()
-	return s.ready
+	return s.ready && auth.TokenReady()
 }
 
 func handleNotify(w http.ResponseWriter, r *http.Request, state *AppState, reqID string) {
@@ -85,7 +336,7 @@ func handleNotify(w http.ResponseWriter, r *http.Request, state *AppState, reqID
 	}
 
 	// Ensure service is initialized
-	if err := state.initialize(r.Context()); err != nil {
+	if err := state.initialize(); err != nil {
 		logger.Error.Printf("[%s] ❌ Service initialization failed: %v", reqID, err)
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
 		return
@@ -113,7 +364,7 @@ func handleNotify(w http.ResponseWriter, r *http.Request, state *AppState, reqID
 	newReq := r.Clone(r.Context())
 	newReq.Body = io.NopCloser(bytes.NewReader(body))
 
-	if err := gmail.PubSubHandler(w, newReq); err != nil {
+	if err := state.handler.PubSubHandler(w, newReq); err != nil {
 		logger.Error.Printf("[%s] ❌ Handler error: %v", reqID, err)
 		switch {
 		case strings.Contains(err.Error(), "not ready"):
@@ -129,31 +380,33 @@ func handleNotify(w http.ResponseWriter, r *http.Request, state *AppState, reqID
 	logger.Info.Printf("[%s] ✅ Request processed successfully", reqID)
 }
 
-func main() {
-	logger.Init()
+// withState initializes the application on first use and serves the request
+// with the handler picked from the initialized state.
+func withState(state *AppState, pick func(s *AppState) http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := state.initialize(); err != nil {
+			logger.Error.Printf("❌ Service initialization failed: %v", err)
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		pick(state).ServeHTTP(w, r)
+	}
+}
+
+// serve runs the HTTP service on port until it receives SIGTERM.
+func serve(port string) {
 	logger.Info.Println("🚀 Starting voicemail transcriber service...")
 
-	state := &AppState{}
+	state := newAppState()
 
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		status := "initializing"
-		if state.isReady() {
-			status = "ok"
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status": status,
-			"time":   time.Now().Format(time.RFC3339),
-		})
-	})
+	mux.HandleFunc("/health", handleHealth(state))
 
 	mux.HandleFunc("/debug", func(w http.ResponseWriter, r *http.Request) {
-		reqID := uuid.New().String()[:8]
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"id":           reqID,
+			"id":           requestid.FromContext(r.Context()),
 			"ready":        state.isReady(),
 			"timestamp":    time.Now().Format(time.RFC3339),
 			"buildVersion": os.Getenv("BUILD_VERSION"),
@@ -168,32 +421,89 @@ func main() {
 	})
 
 	mux.HandleFunc("/notify", func(w http.ResponseWriter, r *http.Request) {
-		reqID := uuid.New().String()[:8]
-		handleNotify(w, r, state, reqID)
+		handleNotify(w, r, state, requestid.FromContext(r.Context()))
 	})
 
-	mux.HandleFunc("/history", gmail.HistoryRetrieveHandler)
+	// Cloud Tasks calls this with an OIDC token for TASK_SERVICE_ACCOUNT, so
+	// AUTH_METHODS needs to include oidc and OIDC_AUDIENCE must be set when
+	// TASK_QUEUE is.
+	mux.Handle("POST "+tasks.ProcessPath, access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.TaskHandler)
+	})))
+
+	mux.Handle("/history", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.HistoryRetrieveHandler)
+	})))
+
+	mux.Handle("/api/", access.Require(withState(state, func(s *AppState) http.Handler {
+		return s.api
+	})))
+
+	mux.Handle("/dashboard/", access.Require(withState(state, func(s *AppState) http.Handler {
+		return s.dashboard
+	})))
+
+	mux.Handle("/admin/dead-letters", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.DeadLetterHandler)
+	})))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	mux.Handle("POST /admin/reprocess/{id}", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.ReprocessHandler)
+	})))
+
+	mux.Handle("POST /admin/watch", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.WatchHandler)
+	})))
+
+	mux.Handle("POST /admin/replay", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.ReplayHandler)
+	})))
+
+	mux.Handle("POST /admin/report", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handleReport)
+	})))
+
+	mux.Handle("GET /status", access.Require(withState(state, func(s *AppState) http.Handler {
+		return http.HandlerFunc(s.handler.StatusHandler)
+	})))
+
+	mux.Handle("GET /metrics", access.Require(metrics.Handler()))
+
+	if pprofEnabled() {
+		mux.Handle("/debug/pprof/", pprofHandler())
 	}
 
 	h2s := &http2.Server{
 		IdleTimeout: 120 * time.Second,
 	}
 
+	// Health checks bypass rate limiting so probes never see a 429.
+	limited := ratelimit.New().Middleware(mux)
+
+	// inflight tracks requests itself because server.Shutdown doesn't wait
+	// for HTTP/2 connections taken over by h2c.
+	var inflight sync.WaitGroup
+
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := uuid.New().String()[:8]
+		inflight.Add(1)
+		defer inflight.Done()
+
+		reqID := requestid.FromRequest(r)
 		start := time.Now()
+		r = r.WithContext(requestid.NewContext(r.Context(), reqID))
 
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-Request-ID", reqID)
+		w.Header().Set(requestid.Header, reqID)
 
 		logger.Info.Printf("[%s] 👉 Request started: %s %s %s", reqID, r.Method, r.URL.Path, r.Proto)
-		mux.ServeHTTP(w, r)
-		logger.Info.Printf("[%s] 👈 Request completed in %v", reqID, time.Since(start))
+		if r.URL.Path == "/health" {
+			mux.ServeHTTP(w, r)
+		} else {
+			limited.ServeHTTP(w, r)
+		}
+		logger.Log.InfoContext(r.Context(), "request completed",
+			"method", r.Method, "path", r.URL.Path, "duration_ms", time.Since(start).Milliseconds())
 	})
 
 	server := &http.Server{
@@ -208,7 +518,75 @@ func main() {
 	logger.Info.Printf("🚀 Server starting on port %s", port)
 	logger.Info.Printf("🌐 Build Version: %s", os.Getenv("BUILD_VERSION"))
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Error.Fatalf("❌ Server failed to start: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error.Fatalf("❌ Server failed to start: %v", err)
+		}
+	case <-ctx.Done():
+		shutdown(server, state, &inflight)
+	}
+}
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT, how long in-flight requests get to
+// finish after SIGTERM. Cloud Run allows 10 seconds before killing the
+// instance.
+func shutdownTimeout() time.Duration {
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid SHUTDOWN_TIMEOUT %q, using default", v)
+	}
+	return 9 * time.Second
+}
+
+// stopWatchOnShutdown reads WATCH_STOP_ON_SHUTDOWN. The watch belongs to the
+// mailbox rather than the instance, so only enable it for single-instance
+// deployments or when decommissioning; otherwise the remaining instances
+// stop receiving notifications.
+func stopWatchOnShutdown() bool {
+	return os.Getenv("WATCH_STOP_ON_SHUTDOWN") == "true"
+}
+
+// shutdown stops accepting connections, waits for in-flight requests such as
+// running transcriptions to finish, then releases the application's clients.
+func shutdown(server *http.Server, state *AppState, inflight *sync.WaitGroup) {
+	timeout := shutdownTimeout()
+	logger.Info.Printf("🛑 Shutdown signal received, draining requests for up to %v", timeout)
+
+	state.setReady(false)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if stopWatchOnShutdown() && state.handler != nil {
+		if err := state.handler.StopWatch(ctx); err != nil {
+			logger.Error.Printf("❌ %v", err)
+		}
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error.Printf("❌ Connections still open at shutdown deadline: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Error.Println("❌ Requests still running at shutdown deadline")
 	}
+	state.Close()
+	logger.Info.Println("👋 Shutdown complete")
 }
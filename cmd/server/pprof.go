@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/access"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+// pprofEnabled reads PPROF_ENABLED. Profiling is off unless it is "true".
+func pprofEnabled() bool {
+	return strings.EqualFold(os.Getenv("PPROF_ENABLED"), "true")
+}
+
+// pprofHandler returns the profiling endpoints on their own mux, so they
+// never end up on the default mux, guarded like the other admin routes.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	logger.Warn.Println("⚠️ pprof endpoints enabled at /debug/pprof/")
+	return access.Require(mux)
+}
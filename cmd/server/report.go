@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/report"
+	"voicemail-transcriber-production/internal/requestid"
+)
+
+// reportLoop generates last month's report once the month has ended, when
+// REPORT_ENABLED is set. Every instance runs it, and report.Generate makes
+// sure only one of them stores and sends it.
+func (s *AppState) reportLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		loc := report.Location()
+		lastMonth := report.MonthStart(time.Now(), loc).AddDate(0, -1, 0)
+		if _, err := report.Generate(ctx, s.fsClient, email.Backend(s.handler.Gmail), lastMonth, false); err != nil {
+			logger.Error.Printf("❌ Monthly report failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleReport regenerates and resends a month's report on demand.
+// The month query parameter (YYYY-MM) defaults to last month.
+func (s *AppState) handleReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	loc := report.Location()
+	from := report.MonthStart(time.Now(), loc).AddDate(0, -1, 0)
+	if v := r.URL.Query().Get("month"); v != "" {
+		month, err := time.ParseInLocation("2006-01", v, loc)
+		if err != nil {
+			http.Error(w, "Invalid month, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+		from = month
+	}
+
+	rep, err := report.Generate(ctx, s.fsClient, email.Backend(s.handler.Gmail), from, true)
+	if err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+		if rep == nil {
+			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
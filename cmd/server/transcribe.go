@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/gmail"
+	"voicemail-transcriber-production/internal/transcriber"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"github.com/spf13/cobra"
+)
+
+func newTranscribeCmd() *cobra.Command {
+	var (
+		mimeType string
+		asJSON   bool
+		sendTo   []string
+	)
+	cmd := &cobra.Command{
+		Use:   "transcribe <audio-file>",
+		Short: "Transcribe a local audio file with the configured provider",
+		Long: "Transcribe a local audio file with the configured provider and print the\n" +
+			"result, optionally emailing it, to debug provider issues without a real voicemail.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if _, err := os.Stat(path); err != nil {
+				return err
+			}
+			if mimeType == "" {
+				mimeType = mime.TypeByExtension(filepath.Ext(path))
+			}
+
+			start := time.Now()
+			result, err := transcriber.Transcribe(cmd.Context(), path, mimeType)
+			if err != nil {
+				return err
+			}
+			elapsed := time.Since(start)
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(map[string]interface{}{
+					"file":            path,
+					"provider":        result.Provider,
+					"transcript":      result.Transcript,
+					"durationSeconds": result.Duration.Seconds(),
+					"confidence":      result.Confidence,
+					"elapsedMs":       elapsed.Milliseconds(),
+				}); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("Provider:   %s\n", result.Provider)
+				fmt.Printf("Length:     %s\n", voicemail.FormatDuration(result.Duration))
+				fmt.Printf("Confidence: %.0f%%\n", result.Confidence*100)
+				fmt.Printf("Took:       %s\n\n", elapsed.Round(time.Millisecond))
+				fmt.Println(result.Transcript)
+			}
+
+			if len(sendTo) == 0 {
+				return nil
+			}
+			return emailTranscript(cmd, path, result, sendTo)
+		},
+	}
+	cmd.Flags().StringVar(&mimeType, "mime-type", "", "audio MIME type (default: guessed from the file extension)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the result as JSON")
+	cmd.Flags().StringSliceVar(&sendTo, "email", nil, "also email the transcript to these addresses")
+	return cmd
+}
+
+// emailTranscript sends the result through the normal transcription email
+// templates, as a test of the delivery path.
+func emailTranscript(cmd *cobra.Command, path string, result *transcriber.Result, to []string) error {
+	srv, err := auth.LoadGmailService(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if err := email.LoadTemplates(cmd.Context(), nil); err != nil {
+		return err
+	}
+
+	vm := &voicemail.Voicemail{
+		Subject:    "Test transcription: " + filepath.Base(path),
+		Caller:     voicemail.Withheld,
+		ReceivedAt: time.Now(),
+		Recordings: []voicemail.Recording{{
+			Filename:   filepath.Base(path),
+			Transcript: result.Transcript,
+			Duration:   result.Duration,
+			Confidence: result.Confidence,
+		}},
+	}
+	if err := email.SendTranscription(cmd.Context(), email.Backend(gmail.NewClient(srv)), vm, email.Recipients{To: to}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Emailed transcript to %v\n", to)
+	return nil
+}
@@ -0,0 +1,223 @@
+// Package access guards the admin, API and dashboard endpoints. A request is
+// let through when any of the methods enabled by AUTH_METHODS accepts it.
+package access
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+
+	"google.golang.org/api/idtoken"
+)
+
+const (
+	MethodIAP    = "iap"
+	MethodOIDC   = "oidc"
+	MethodAPIKey = "apikey"
+
+	iapHeader = "X-Goog-IAP-JWT-Assertion"
+)
+
+// errNoCredentials means the request didn't present anything the method
+// recognises, as opposed to presenting invalid credentials.
+var errNoCredentials = errors.New("no credentials")
+
+type principalKey struct{}
+
+// Principal returns who the request was authenticated as, e.g.
+// "iap:jane@example.com" or "apikey:scheduler".
+func Principal(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// Methods reads AUTH_METHODS, a comma-separated list of iap, oidc and apikey.
+// It defaults to apikey.
+func Methods() []string {
+	var methods []string
+	for _, m := range strings.Split(os.Getenv("AUTH_METHODS"), ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		switch m {
+		case MethodIAP, MethodOIDC, MethodAPIKey:
+			methods = append(methods, m)
+		case "":
+		default:
+			logger.Warn.Printf("⚠️ Ignoring unknown auth method %q", m)
+		}
+	}
+	if len(methods) == 0 {
+		return []string{MethodAPIKey}
+	}
+	return methods
+}
+
+// Require rejects requests that no enabled auth method accepts.
+func Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var failures []string
+		for _, method := range Methods() {
+			principal, err := authenticate(r, method)
+			if err == nil {
+				logger.Info.Printf("🔑 %s %s by %s from %s", r.Method, r.URL.Path, principal, r.RemoteAddr)
+				ctx := context.WithValue(r.Context(), principalKey{}, principal)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			if !errors.Is(err, errNoCredentials) {
+				failures = append(failures, method+": "+err.Error())
+			}
+		}
+
+		if len(failures) > 0 {
+			logger.Warn.Printf("⚠️ Rejected request %s %s from %s: %s", r.Method, r.URL.Path, r.RemoteAddr, strings.Join(failures, "; "))
+		} else {
+			logger.Warn.Printf("⚠️ Rejected unauthenticated request %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func authenticate(r *http.Request, method string) (string, error) {
+	switch method {
+	case MethodIAP:
+		return checkIAP(r)
+	case MethodOIDC:
+		return checkOIDC(r)
+	default:
+		return checkAPIKey(r)
+	}
+}
+
+// checkIAP validates the signed header Identity-Aware Proxy adds, against
+// the IAP_AUDIENCE configured for the backend service.
+func checkIAP(r *http.Request) (string, error) {
+	assertion := r.Header.Get(iapHeader)
+	if assertion == "" {
+		return "", errNoCredentials
+	}
+	audience := os.Getenv("IAP_AUDIENCE")
+	if audience == "" {
+		return "", errors.New("IAP_AUDIENCE is not set")
+	}
+
+	payload, err := idtoken.Validate(r.Context(), assertion, audience)
+	if err != nil {
+		return "", err
+	}
+	email, _ := payload.Claims["email"].(string)
+	return "iap:" + email, nil
+}
+
+// checkOIDC validates a Google-signed ID token sent as a bearer token, such
+// as one from Cloud Scheduler or gcloud, against OIDC_AUDIENCE, and checks
+// its email against OIDC_ALLOWED_EMAILS.
+func checkOIDC(r *http.Request) (string, error) {
+	token := bearerToken(r)
+	if token == "" || strings.Count(token, ".") != 2 {
+		return "", errNoCredentials
+	}
+	// Without an audience idtoken skips the check, and a token the same
+	// account got for any other service would be accepted.
+	audience := os.Getenv("OIDC_AUDIENCE")
+	if audience == "" {
+		return "", errors.New("OIDC_AUDIENCE is not set")
+	}
+
+	payload, err := idtoken.Validate(r.Context(), token, audience)
+	if err != nil {
+		return "", err
+	}
+	email, _ := payload.Claims["email"].(string)
+	verified, _ := payload.Claims["email_verified"].(bool)
+	if !verified || !emailAllowed(email) {
+		return "", errors.New("email " + email + " is not allowed")
+	}
+	return "oidc:" + email, nil
+}
+
+// emailAllowed matches email against OIDC_ALLOWED_EMAILS, which lists
+// addresses or "@domain" entries.
+func emailAllowed(email string) bool {
+	email = strings.ToLower(email)
+	for _, entry := range strings.Split(os.Getenv("OIDC_ALLOWED_EMAILS"), ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == email || (strings.HasPrefix(entry, "@") && strings.HasSuffix(email, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAPIKey compares the X-API-Key header or bearer token with the keys
+// in the API_KEYS_SECRET secret (default api-key).
+func checkAPIKey(r *http.Request) (string, error) {
+	got := r.Header.Get("X-API-Key")
+	if got == "" {
+		got = bearerToken(r)
+	}
+	if got == "" {
+		return "", errNoCredentials
+	}
+
+	name := os.Getenv("API_KEYS_SECRET")
+	if name == "" {
+		name = "api-key"
+	}
+	data, err := secret.LoadSecret(r.Context(), name)
+	if err != nil {
+		return "", err
+	}
+
+	matched := ""
+	for _, k := range parseKeys(string(data)) {
+		// Compare against every key so timing doesn't reveal which one matched.
+		if subtle.ConstantTimeCompare([]byte(got), []byte(k.key)) == 1 {
+			matched = k.name
+		}
+	}
+	if matched == "" {
+		return "", errors.New("invalid API key")
+	}
+	return "apikey:" + matched, nil
+}
+
+type apiKey struct {
+	name, key string
+}
+
+// parseKeys reads one key per line, either "name:key" or a bare key, which
+// is named "default". Blank lines and lines starting with # are skipped.
+func parseKeys(data string) []apiKey {
+	var keys []apiKey
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, key, ok := strings.Cut(line, ":")
+		if !ok {
+			name, key = "default", line
+		}
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if key != "" {
+			keys = append(keys, apiKey{name: name, key: key})
+		}
+	}
+	return keys
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"voicemail-transcriber-production/internal/archive"
+	"voicemail-transcriber-production/internal/audit"
+	"voicemail-transcriber-production/internal/gmail"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Server serves the transcripts API backed by the stored Firestore records.
+type Server struct {
+	Firestore *firestore.Client
+}
+
+// NewHandler returns the /api/ routes. Callers are authenticated by
+// access.Require.
+func NewHandler(fsClient *firestore.Client) http.Handler {
+	s := &Server{Firestore: fsClient}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/transcripts", s.ListTranscripts)
+	mux.HandleFunc("GET /api/transcripts/search", s.SearchTranscripts)
+	mux.HandleFunc("GET /api/transcripts/export", s.ExportTranscripts)
+	mux.HandleFunc("GET /api/transcripts/{id}", s.GetTranscript)
+	mux.HandleFunc("GET /api/costs", s.Costs)
+	mux.HandleFunc("GET /api/audit/{id}", s.AuditTrail)
+	return mux
+}
+
+// ListTranscripts handles GET /api/transcripts. Query parameters: from and
+// to (RFC 3339 or YYYY-MM-DD), caller, limit and pageToken.
+func (s *Server) ListTranscripts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := transcripts.ListOptions{
+		Caller:    q.Get("caller"),
+		PageToken: q.Get("pageToken"),
+	}
+
+	var err error
+	if opts.From, err = parseTime(q.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if opts.To, err = parseTime(q.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	records, next, err := transcripts.List(r.Context(), s.Firestore, opts)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list transcripts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"transcripts":   records,
+		"nextPageToken": next,
+	})
+}
+
+// GetTranscript handles GET /api/transcripts/{id}.
+func (s *Server) GetTranscript(w http.ResponseWriter, r *http.Request) {
+	rec, err := transcripts.Get(r.Context(), s.Firestore, r.PathValue("id"))
+	if errors.Is(err, transcripts.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "transcript not found")
+		return
+	}
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load transcript")
+		return
+	}
+	archive.SignRecordings(r.Context(), rec)
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// AuditTrail handles GET /api/audit/{id}, every recorded decision about a
+// Gmail message, oldest first, with its current processing state. It covers
+// messages that were skipped and never got a transcript.
+func (s *Server) AuditTrail(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	entries, err := audit.List(r.Context(), s.Firestore, id)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load audit trail")
+		return
+	}
+	state, err := gmail.LoadState(r.Context(), s.Firestore, id)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load message state")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"messageId": id,
+		"state":     state,
+		"entries":   entries,
+	})
+}
+
+// SearchTranscripts handles GET /api/transcripts/search?q=...&limit=...
+func (s *Server) SearchTranscripts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	results, err := transcripts.Search(r.Context(), s.Firestore, query, limit)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to search transcripts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":   query,
+		"results": results,
+	})
+}
+
+// Costs handles GET /api/costs, the estimated transcription costs per month
+// and provider. Query parameters: from and to, defaulting to the start of
+// the current month and now.
+func (s *Server) Costs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, err := parseTime(q.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	to, err := parseTime(q.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	if from.IsZero() {
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if to.IsZero() {
+		to = now
+	}
+
+	totals, err := transcripts.CostTotals(r.Context(), s.Firestore, from, to)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to total costs")
+		return
+	}
+
+	var usd float64
+	for _, t := range totals {
+		usd += t.USD
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":     from,
+		"to":       to,
+		"totalUsd": usd,
+		"months":   totals,
+	})
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
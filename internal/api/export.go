@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+)
+
+var csvHeader = []string{
+	"message_id", "received_at", "caller", "carrier", "mailbox", "subject",
+	"duration_seconds", "status", "confidence", "transcript",
+}
+
+// ExportTranscripts handles GET /api/transcripts/export?from=&to=, streaming
+// a CSV of the transcripts received in the range. Without from/to it exports
+// the last 30 days.
+func (s *Server) ExportTranscripts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, err := parseTime(q.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	to, err := parseTime(q.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	} else if len(q.Get("to")) == len("2006-01-02") {
+		// A bare date includes the whole day.
+		to = to.AddDate(0, 0, 1)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	filename := fmt.Sprintf("transcripts-%s-%s.csv", from.Format("20060102"), to.Format("20060102"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return
+	}
+
+	rows := 0
+	err = transcripts.Each(r.Context(), s.Firestore, from, to, func(rec *transcripts.Record) error {
+		rows++
+		if err := cw.Write(csvRow(rec)); err != nil {
+			return err
+		}
+		if rows%100 == 0 {
+			cw.Flush()
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		return cw.Error()
+	})
+	cw.Flush()
+
+	if err != nil {
+		// Headers are already sent, so the best we can do is log and stop.
+		logger.Error.Printf("❌ CSV export aborted after %d rows: %v", rows, err)
+		return
+	}
+	logger.Info.Printf("📄 Exported %d transcript(s) to CSV (%s to %s)", rows, from.Format(time.RFC3339), to.Format(time.RFC3339))
+}
+
+func csvRow(rec *transcripts.Record) []string {
+	received := ""
+	if !rec.ReceivedAt.IsZero() {
+		received = rec.ReceivedAt.Format(time.RFC3339)
+	}
+	return []string{
+		rec.MessageID,
+		received,
+		sanitizeCell(rec.Caller),
+		sanitizeCell(rec.Carrier),
+		sanitizeCell(rec.Mailbox),
+		sanitizeCell(rec.Subject),
+		strconv.FormatFloat(rec.DurationSeconds, 'f', 1, 64),
+		rec.Status,
+		strconv.FormatFloat(rec.Confidence, 'f', 3, 64),
+		sanitizeCell(rec.Transcript),
+	}
+}
+
+// sanitizeCell stops spreadsheet apps from interpreting a cell as a formula.
+func sanitizeCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+// Package archive keeps a copy of each voicemail recording in Cloud Storage
+// and hands out short-lived signed URLs for playing it back.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/storage/v1"
+)
+
+var (
+	storageOnce sync.Once
+	storageSrv  *storage.Service
+	storageErr  error
+)
+
+// Bucket reads ARCHIVE_BUCKET. Archival is disabled when it is empty.
+func Bucket() string {
+	return strings.TrimPrefix(os.Getenv("ARCHIVE_BUCKET"), "gs://")
+}
+
+// Enabled reports whether recordings are archived.
+func Enabled() bool {
+	return Bucket() != ""
+}
+
+func service(ctx context.Context) (*storage.Service, error) {
+	storageOnce.Do(func() {
+		storageSrv, storageErr = storage.NewService(ctx, option.WithScopes(storage.DevstorageReadWriteScope))
+		if storageErr != nil {
+			storageErr = fmt.Errorf("failed to create storage service: %w", storageErr)
+		}
+	})
+	return storageSrv, storageErr
+}
+
+// ObjectName returns the object a recording is archived under.
+func ObjectName(msgID, filename string) string {
+	return path.Join("voicemails", msgID, path.Base(filename))
+}
+
+// Upload stores a recording and returns its object name.
+func Upload(ctx context.Context, msgID, filename, mimeType string, data []byte) (string, error) {
+	srv, err := service(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	name := ObjectName(msgID, filename)
+	obj := &storage.Object{
+		Name:        name,
+		ContentType: mimeType,
+		Metadata:    map[string]string{"gmailMessageId": msgID},
+	}
+	_, err = srv.Objects.Insert(Bucket(), obj).
+		Media(bytes.NewReader(data)).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+
+	logger.Info.Printf("🗄️ Archived %s to gs://%s/%s", filename, Bucket(), name)
+	return name, nil
+}
+
+// urlTTL reads SIGNED_URL_TTL, how long a playback link stays valid.
+func urlTTL() time.Duration {
+	if v := os.Getenv("SIGNED_URL_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 && d <= 7*24*time.Hour {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid SIGNED_URL_TTL %q, using default", v)
+	}
+	return 15 * time.Minute
+}
@@ -0,0 +1,147 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+
+	"cloud.google.com/go/compute/metadata"
+	"google.golang.org/api/iamcredentials/v1"
+)
+
+const storageHost = "storage.googleapis.com"
+
+var (
+	iamOnce sync.Once
+	iamSrv  *iamcredentials.Service
+	iamErr  error
+)
+
+// signerEmail reads ARCHIVE_SIGNER_EMAIL, falling back to the service
+// account the service runs as.
+func signerEmail(ctx context.Context) (string, error) {
+	if email := os.Getenv("ARCHIVE_SIGNER_EMAIL"); email != "" {
+		return email, nil
+	}
+	email, err := metadata.EmailWithContext(ctx, "default")
+	if err != nil {
+		return "", fmt.Errorf("failed to find service account for signing (set ARCHIVE_SIGNER_EMAIL): %w", err)
+	}
+	return email, nil
+}
+
+// SignedURL returns a V4 signed GET URL for an archived object, valid for
+// SIGNED_URL_TTL. The signature comes from the IAM Credentials API, so no
+// private key has to be deployed with the service.
+func SignedURL(ctx context.Context, object string) (string, error) {
+	if !Enabled() {
+		return "", fmt.Errorf("archival is not enabled")
+	}
+
+	email, err := signerEmail(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	datestamp := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	scope := datestamp + "/auto/storage/goog4_request"
+
+	query := url.Values{
+		"X-Goog-Algorithm":     {"GOOG4-RSA-SHA256"},
+		"X-Goog-Credential":    {email + "/" + scope},
+		"X-Goog-Date":          {timestamp},
+		"X-Goog-Expires":       {fmt.Sprint(int(urlTTL().Seconds()))},
+		"X-Goog-SignedHeaders": {"host"},
+	}
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+	canonicalPath := "/" + Bucket() + "/" + escapePath(object)
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalPath,
+		canonicalQuery,
+		"host:" + storageHost + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"GOOG4-RSA-SHA256",
+		timestamp,
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	signature, err := signBlob(ctx, email, []byte(stringToSign))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("https://%s%s?%s&X-Goog-Signature=%s",
+		storageHost, canonicalPath, canonicalQuery, hex.EncodeToString(signature)), nil
+}
+
+func signBlob(ctx context.Context, email string, payload []byte) ([]byte, error) {
+	iamOnce.Do(func() {
+		iamSrv, iamErr = iamcredentials.NewService(ctx)
+	})
+	if iamErr != nil {
+		return nil, fmt.Errorf("failed to create IAM credentials service: %w", iamErr)
+	}
+
+	resp, err := iamSrv.Projects.ServiceAccounts.SignBlob(
+		"projects/-/serviceAccounts/"+email,
+		&iamcredentials.SignBlobRequest{Payload: base64.StdEncoding.EncodeToString(payload)},
+	).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign URL: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(resp.SignedBlob)
+}
+
+// escapePath percent-encodes an object name for the canonical request,
+// keeping only unreserved characters and slashes.
+func escapePath(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// SignRecordings fills in the AudioURL of each archived recording on rec.
+// Failures are logged and leave the URL empty.
+func SignRecordings(ctx context.Context, rec *transcripts.Record) {
+	if !Enabled() {
+		return
+	}
+	for i := range rec.Recordings {
+		if rec.Recordings[i].AudioObject == "" {
+			continue
+		}
+		u, err := SignedURL(ctx, rec.Recordings[i].AudioObject)
+		if err != nil {
+			logger.Warn.Printf("⚠️ %v", err)
+			continue
+		}
+		rec.Recordings[i].AudioURL = u
+	}
+}
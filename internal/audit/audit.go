@@ -0,0 +1,94 @@
+// Package audit keeps an append-only trail of the decisions made about each
+// message, so "why did my voicemail never arrive?" can be answered from the
+// record rather than from logs.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Collection holds one document per audit event.
+const Collection = "audit_log"
+
+// Events recorded for a message.
+const (
+	EventReceived    = "received"
+	EventSkipped     = "skipped"
+	EventDeferred    = "deferred"
+	EventTranscribed = "transcribed"
+	EventDelivered   = "delivered"
+	EventFailed      = "failed"
+	EventBounced     = "bounced"
+)
+
+// Entry is one audit event.
+type Entry struct {
+	MessageID string    `firestore:"messageId" json:"messageId"`
+	Event     string    `firestore:"event" json:"event"`
+	Detail    string    `firestore:"detail,omitempty" json:"detail,omitempty"`
+	RequestID string    `firestore:"requestId,omitempty" json:"requestId,omitempty"`
+	At        time.Time `firestore:"at" json:"at"`
+	// ExpiresAt is for a Firestore TTL policy on the collection.
+	ExpiresAt time.Time `firestore:"expiresAt" json:"-"`
+}
+
+// retention reads AUDIT_RETENTION, how long entries are kept (default 90
+// days).
+func retention() time.Duration {
+	if v := os.Getenv("AUDIT_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid AUDIT_RETENTION %q, using default", v)
+	}
+	return 90 * 24 * time.Hour
+}
+
+// Record appends an event for msgID. Entries are only ever added, never
+// updated. A failed write is logged rather than returned, so auditing never
+// holds up processing.
+func Record(ctx context.Context, client *firestore.Client, msgID, event, detail string) {
+	if client == nil || msgID == "" {
+		return
+	}
+	now := time.Now()
+	_, _, err := client.Collection(Collection).Add(context.WithoutCancel(ctx), Entry{
+		MessageID: msgID,
+		Event:     event,
+		Detail:    detail,
+		RequestID: requestid.FromContext(ctx),
+		At:        now,
+		ExpiresAt: now.Add(retention()),
+	})
+	if err != nil {
+		logger.Warn.Printf("%s⚠️ Failed to write audit entry %s for message %s: %v", requestid.Prefix(ctx), event, msgID, err)
+	}
+}
+
+// List returns the audit trail of msgID, oldest first.
+func List(ctx context.Context, client *firestore.Client, msgID string) ([]Entry, error) {
+	docs, err := client.Collection(Collection).Where("messageId", "==", msgID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit trail for message %s: %w", msgID, err)
+	}
+	entries := make([]Entry, 0, len(docs))
+	for _, doc := range docs {
+		var e Entry
+		if err := doc.DataTo(&e); err != nil {
+			return nil, fmt.Errorf("failed to decode audit entry %s: %w", doc.Ref.ID, err)
+		}
+		entries = append(entries, e)
+	}
+	// Sorted here rather than in the query, which would need a composite
+	// index.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries, nil
+}
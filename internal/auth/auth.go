@@ -3,67 +3,86 @@ package auth
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"voicemail-transcriber-production/internal/logger"
 	"voicemail-transcriber-production/internal/secret"
 
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 )
 
-var IsTokenReady bool
+// Services are Gmail services keyed by the mailbox they act as.
+type Services map[string]*gmail.Service
 
-func LoadGmailService(ctx context.Context) (*gmail.Service, error) {
-	userToImpersonate := os.Getenv("EMAIL_RESPONSE_ADDRESS")
-	if userToImpersonate == "" {
-		return nil, fmt.Errorf("EMAIL_RESPONSE_ADDRESS must be set")
+// Subjects reads GMAIL_SUBJECTS, the comma-separated mailboxes to
+// impersonate, defaulting to EMAIL_RESPONSE_ADDRESS alone.
+func Subjects() []string {
+	var subjects []string
+	for _, s := range strings.Split(os.Getenv("GMAIL_SUBJECTS"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			subjects = append(subjects, s)
+		}
 	}
-	logger.Info.Printf("🔍 Debug: Starting Gmail service initialization for: %s", userToImpersonate)
-
-	// Load service account credentials
-	credBytes, err := secret.LoadSecret(ctx, "gmail-token-json")
-	if err != nil {
-		logger.Error.Printf("❌ Debug: Failed to load service account credentials: %v", err)
-		return nil, fmt.Errorf("failed to load service account credentials: %w", err)
+	if len(subjects) == 0 {
+		if addr := os.Getenv("EMAIL_RESPONSE_ADDRESS"); addr != "" {
+			subjects = append(subjects, addr)
+		}
 	}
-	logger.Info.Printf("✅ Debug: Successfully loaded service account credentials")
+	return subjects
+}
 
-	scopes := []string{
-		gmail.GmailSendScope,
-		gmail.GmailModifyScope,
-		gmail.GmailReadonlyScope,
+// LoadGmailServices builds a service for every mailbox in Subjects, failing
+// if any of them can't be initialized.
+func LoadGmailServices(ctx context.Context) (Services, error) {
+	subjects := Subjects()
+	if len(subjects) == 0 {
+		return nil, fmt.Errorf("GMAIL_SUBJECTS or EMAIL_RESPONSE_ADDRESS must be set")
+	}
+	if authMode() == "oauth" && len(subjects) > 1 {
+		return nil, fmt.Errorf("GMAIL_AUTH_MODE=oauth supports a single mailbox, got %d", len(subjects))
 	}
 
-	// Create JWT config from service account
-	config, err := google.JWTConfigFromJSON(credBytes, scopes...)
-	if err != nil {
-		logger.Error.Printf("❌ Debug: Failed to create JWT config: %v", err)
-		return nil, fmt.Errorf("failed to create JWT config: %w", err)
+	services := make(Services, len(subjects))
+	for _, subject := range subjects {
+		srv, err := LoadGmailServiceFor(ctx, subject)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", subject, err)
+		}
+		services[subject] = srv
 	}
-	logger.Info.Printf("✅ Debug: Successfully created JWT config")
+	return services, nil
+}
 
-	// Set up domain-wide delegation
-	config.Subject = userToImpersonate
-	logger.Info.Printf("🔍 Debug: Set impersonation subject to: %s", userToImpersonate)
+// LoadGmailService builds the service for EMAIL_RESPONSE_ADDRESS.
+func LoadGmailService(ctx context.Context) (*gmail.Service, error) {
+	userToImpersonate := os.Getenv("EMAIL_RESPONSE_ADDRESS")
+	if userToImpersonate == "" {
+		return nil, fmt.Errorf("EMAIL_RESPONSE_ADDRESS must be set")
+	}
+	return LoadGmailServiceFor(ctx, userToImpersonate)
+}
 
-	// Create token source
-	ts := config.TokenSource(ctx)
-	logger.Info.Printf("✅ Debug: Created token source")
+// LoadGmailServiceFor builds a service acting as userToImpersonate and checks
+// that the credentials really reach that mailbox.
+func LoadGmailServiceFor(ctx context.Context, userToImpersonate string) (*gmail.Service, error) {
+	logger.Info.Printf("🔍 Debug: Starting Gmail service initialization for: %s", userToImpersonate)
 
-	// Test token generation
-	token, err := ts.Token()
+	src, err := newTokenSource(ctx, userToImpersonate)
 	if err != nil {
-		logger.Error.Printf("❌ Debug: Failed to generate token: %v", err)
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
-	logger.Info.Printf("✅ Debug: Successfully generated token (expires: %v)", token.Expiry)
+	ts := &recoveringSource{subject: userToImpersonate, src: src}
 
 	// Create Gmail service
-	srv, err := gmail.NewService(ctx,
-		option.WithTokenSource(ts),
-		option.WithScopes(scopes...),
-	)
+	// Tag Gmail API calls with the request ID of the work that made them.
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{Source: ts, Base: &unauthorizedTransport{source: ts}},
+	}
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(httpClient))
 	if err != nil {
 		logger.Error.Printf("❌ Debug: Failed to create Gmail service: %v", err)
 		return nil, fmt.Errorf("failed to create Gmail service: %w", err)
@@ -84,7 +103,101 @@ func LoadGmailService(ctx context.Context) (*gmail.Service, error) {
 			profile.EmailAddress, userToImpersonate)
 	}
 
-	IsTokenReady = true
+	register(ts)
 	logger.Info.Printf("✅ Debug: Gmail service fully initialized for: %s", userToImpersonate)
 	return srv, nil
 }
+
+// KeySecret holds the service account key used by the "key" auth mode.
+const KeySecret = "gmail-token-json"
+
+var gmailScopes = []string{
+	gmail.GmailSendScope,
+	gmail.GmailModifyScope,
+	gmail.GmailReadonlyScope,
+}
+
+// authMode reads GMAIL_AUTH_MODE: "key" (the default) signs delegation
+// assertions with the gmail-token-json service account key, "iam" signs them
+// through the IAM Credentials API without a key, and "oauth" uses a stored
+// user refresh token instead of delegation.
+func authMode() string {
+	switch mode := strings.ToLower(os.Getenv("GMAIL_AUTH_MODE")); mode {
+	case "", "key":
+		return "key"
+	case "iam", "oauth":
+		return mode
+	default:
+		logger.Warn.Printf("⚠️ Invalid GMAIL_AUTH_MODE %q, using default", mode)
+		return "key"
+	}
+}
+
+// newTokenSource returns a token source impersonating subject, checked by
+// generating a token.
+func newTokenSource(ctx context.Context, subject string) (oauth2.TokenSource, error) {
+	var ts oauth2.TokenSource
+	var err error
+	switch authMode() {
+	case "iam":
+		ts, err = newIAMTokenSource(ctx, subject, gmailScopes)
+		if err != nil {
+			logger.Error.Printf("❌ Debug: Failed to set up IAM delegation: %v", err)
+			return nil, err
+		}
+		logger.Info.Printf("✅ Debug: Created IAM-signed token source for: %s", subject)
+	case "oauth":
+		ts, err = newOAuthTokenSource(ctx)
+		if err != nil {
+			logger.Error.Printf("❌ Debug: Failed to set up OAuth token: %v", err)
+			return nil, err
+		}
+		logger.Info.Printf("✅ Debug: Created OAuth token source")
+	default:
+		ts, err = newKeyTokenSource(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Test token generation
+	token, err := ts.Token()
+	if err != nil {
+		logger.Error.Printf("❌ Debug: Failed to generate token: %v", err)
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	logger.Info.Printf("✅ Debug: Successfully generated token (expires: %v)", token.Expiry)
+	return ts, nil
+}
+
+// newKeyTokenSource loads the service account key and returns a token
+// source impersonating subject.
+func newKeyTokenSource(ctx context.Context, subject string) (oauth2.TokenSource, error) {
+	// Load service account credentials
+	credBytes, err := secret.LoadSecret(ctx, KeySecret)
+	if err != nil {
+		logger.Error.Printf("❌ Debug: Failed to load service account credentials: %v", err)
+		return nil, fmt.Errorf("failed to load service account credentials: %w", err)
+	}
+	logger.Info.Printf("✅ Debug: Successfully loaded service account credentials")
+
+	// Create JWT config from service account
+	config, err := google.JWTConfigFromJSON(credBytes, gmailScopes...)
+	if err != nil {
+		logger.Error.Printf("❌ Debug: Failed to create JWT config: %v", err)
+		return nil, fmt.Errorf("failed to create JWT config: %w", err)
+	}
+	logger.Info.Printf("✅ Debug: Successfully created JWT config")
+
+	// Set up domain-wide delegation
+	config.Subject = subject
+	logger.Info.Printf("🔍 Debug: Set impersonation subject to: %s", subject)
+
+	// Create token source. It refreshes long after ctx, possibly the first
+	// request's, has finished, so it gets a context that is never cancelled
+	// and a client with a timeout instead.
+	refreshCtx := context.WithValue(context.WithoutCancel(ctx), oauth2.HTTPClient, &http.Client{Timeout: tokenTimeout})
+	ts := config.TokenSource(refreshCtx)
+	logger.Info.Printf("✅ Debug: Created token source")
+	return ts, nil
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/iamcredentials/v1"
+)
+
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// delegatorEmail reads GMAIL_SERVICE_ACCOUNT, the service account granted
+// domain-wide delegation, falling back to the service account the service
+// runs as.
+func delegatorEmail(ctx context.Context) (string, error) {
+	if email := os.Getenv("GMAIL_SERVICE_ACCOUNT"); email != "" {
+		return email, nil
+	}
+	email, err := metadata.EmailWithContext(ctx, "default")
+	if err != nil {
+		return "", fmt.Errorf("failed to find service account for delegation (set GMAIL_SERVICE_ACCOUNT): %w", err)
+	}
+	return email, nil
+}
+
+// iamTokenSource impersonates subject with tokens whose assertions are
+// signed by the IAM Credentials API, so no service account key is needed.
+// The runtime identity needs roles/iam.serviceAccountTokenCreator on the
+// delegating service account.
+type iamTokenSource struct {
+	ctx     context.Context
+	iam     *iamcredentials.Service
+	email   string
+	subject string
+	scopes  []string
+}
+
+func newIAMTokenSource(ctx context.Context, subject string, scopes []string) (oauth2.TokenSource, error) {
+	// The source outlives ctx, which may be the request that first needed
+	// Gmail, so refreshes must not inherit its cancellation.
+	ctx = context.WithoutCancel(ctx)
+	email, err := delegatorEmail(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iam, err := iamcredentials.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IAM credentials service: %w", err)
+	}
+	src := &iamTokenSource{ctx: ctx, iam: iam, email: email, subject: subject, scopes: scopes}
+	return oauth2.ReuseTokenSource(nil, src), nil
+}
+
+// tokenTimeout bounds each signing and exchange, which no longer have a
+// caller's deadline.
+const tokenTimeout = 30 * time.Second
+
+func (s *iamTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, tokenTimeout)
+	defer cancel()
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   s.email,
+		"sub":   s.subject,
+		"scope": strings.Join(s.scopes, " "),
+		"aud":   googleTokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := s.iam.Projects.ServiceAccounts.SignJwt(
+		"projects/-/serviceAccounts/"+s.email,
+		&iamcredentials.SignJwtRequest{Payload: string(claims)},
+	).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign delegation JWT as %s: %w", s.email, err)
+	}
+	return exchangeJWT(ctx, signed.SignedJwt)
+}
+
+// exchangeJWT trades a signed JWT bearer assertion for an access token.
+func exchangeJWT(ctx context.Context, assertion string) (*oauth2.Token, error) {
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange delegation JWT: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange failed with status %d: %s %s", resp.StatusCode, body.Error, body.Description)
+	}
+	return &oauth2.Token{
+		AccessToken: body.AccessToken,
+		TokenType:   body.TokenType,
+		Expiry:      time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Secrets used by the "oauth" auth mode, for personal Gmail accounts that
+// can't use domain-wide delegation.
+const (
+	// OAuthClientSecret holds the installed-app client JSON downloaded from
+	// the Cloud console.
+	OAuthClientSecret = "gmail-oauth-client"
+	// OAuthTokenSecret holds the user's token, including the refresh token,
+	// as written by Login.
+	OAuthTokenSecret = "gmail-oauth-token"
+)
+
+func oauthConfig(ctx context.Context) (*oauth2.Config, error) {
+	data, err := secret.LoadSecret(ctx, OAuthClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OAuth client: %w", err)
+	}
+	config, err := google.ConfigFromJSON(data, gmailScopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OAuth client: %w", err)
+	}
+	return config, nil
+}
+
+// newOAuthTokenSource returns a token source refreshing the stored user
+// token. The mailbox is whichever account granted it, not subject.
+func newOAuthTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	config, err := oauthConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := secret.LoadSecret(ctx, OAuthTokenSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OAuth token (run \"server auth login\"): %w", err)
+	}
+	var tok oauth2.Token
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, fmt.Errorf("failed to parse OAuth token: %w", err)
+	}
+	if tok.RefreshToken == "" {
+		return nil, fmt.Errorf("stored OAuth token has no refresh token (run \"server auth login\")")
+	}
+	// Refreshes happen long after ctx, possibly the first request's, has
+	// finished, so they get a context of their own that is never cancelled
+	// and a client with a timeout instead.
+	refreshCtx := context.WithValue(context.WithoutCancel(ctx), oauth2.HTTPClient, &http.Client{Timeout: 30 * time.Second})
+	return &persistingSource{ctx: refreshCtx, base: config.TokenSource(refreshCtx, &tok), refreshToken: tok.RefreshToken}, nil
+}
+
+// persistingSource saves the token back to Secret Manager whenever Google
+// issues a new refresh token, so a restart doesn't load a stale one.
+// Ordinary access token refreshes aren't saved, to avoid a secret version
+// per hour.
+type persistingSource struct {
+	ctx  context.Context
+	base oauth2.TokenSource
+
+	mu           sync.Mutex
+	refreshToken string
+}
+
+func (s *persistingSource) Token() (*oauth2.Token, error) {
+	tok, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.RefreshToken != "" && tok.RefreshToken != s.refreshToken {
+		if err := saveOAuthToken(s.ctx, tok); err != nil {
+			logger.Warn.Printf("⚠️ Could not persist rotated OAuth token: %v", err)
+		} else {
+			s.refreshToken = tok.RefreshToken
+		}
+	}
+	return tok, nil
+}
+
+func saveOAuthToken(ctx context.Context, tok *oauth2.Token) error {
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	return secret.SaveSecret(ctx, OAuthTokenSecret, data)
+}
+
+// Login runs the installed-app authorization flow: it prints a consent URL,
+// waits for Google to redirect back to a loopback listener, exchanges the
+// code and stores the token in Secret Manager for the "oauth" auth mode.
+func Login(ctx context.Context, prompt func(url string)) error {
+	config, err := oauthConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start callback listener: %w", err)
+	}
+	defer listener.Close()
+	config.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+
+	state := fmt.Sprintf("%x", randomState())
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("state") != state:
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		case q.Get("error") != "":
+			select {
+			case errs <- fmt.Errorf("authorization denied: %s", q.Get("error")):
+			default:
+			}
+		default:
+			select {
+			case codes <- q.Get("code"):
+			default:
+			}
+		}
+		fmt.Fprintln(w, "Authorization received; you can close this window.")
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	// Offline access with forced consent so Google returns a refresh token.
+	prompt(config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce))
+
+	var code string
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errs:
+		return err
+	case code = <-codes:
+	}
+
+	tok, err := config.Exchange(ctx, code)
+	if err != nil {
+		return fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	if tok.RefreshToken == "" {
+		return fmt.Errorf("Google returned no refresh token; revoke the app's access and try again")
+	}
+	return saveOAuthToken(ctx, tok)
+}
+
+func randomState() []byte {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return b
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/retry"
+	"voicemail-transcriber-production/internal/secret"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	sourcesMu sync.Mutex
+	// sources holds the token source of every initialized mailbox.
+	sources = map[string]*recoveringSource{}
+	// manualReady is the readiness reported when no mailbox has been
+	// initialized here; see SetTokenReady.
+	manualReady atomic.Bool
+
+	backgroundMu sync.Mutex
+	// background is the context re-initialization runs on; see
+	// SetBackground.
+	background = context.Background()
+)
+
+// SetBackground sets the context that failing credentials are
+// re-initialized on. Cancelling it, as the server does on shutdown, stops
+// any re-initialization still retrying.
+func SetBackground(ctx context.Context) {
+	backgroundMu.Lock()
+	defer backgroundMu.Unlock()
+	background = ctx
+}
+
+func backgroundContext() context.Context {
+	backgroundMu.Lock()
+	defer backgroundMu.Unlock()
+	return background
+}
+
+// TokenReady reports whether Gmail credentials are currently usable for
+// every initialized mailbox. It goes false when a token source starts
+// failing and true again once its credentials have been re-initialized.
+func TokenReady() bool {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if len(sources) == 0 {
+		return manualReady.Load()
+	}
+	for _, s := range sources {
+		if !s.ready.Load() {
+			return false
+		}
+	}
+	return true
+}
+
+// SetTokenReady sets the readiness reported before any mailbox has been
+// initialized, for callers such as the harness that don't authenticate
+// through LoadGmailService.
+func SetTokenReady(ready bool) {
+	manualReady.Store(ready)
+}
+
+var rotationOnce sync.Once
+
+// register marks s ready and tracks it, replacing any earlier source for the
+// same mailbox.
+func register(s *recoveringSource) {
+	s.ready.Store(true)
+	sourcesMu.Lock()
+	sources[s.subject] = s
+	sourcesMu.Unlock()
+
+	rotationOnce.Do(func() {
+		for _, name := range []string{KeySecret, OAuthClientSecret, OAuthTokenSecret} {
+			secret.OnRotate(name, rotateAll)
+		}
+	})
+}
+
+// rotateAll rebuilds every mailbox's token source after its credentials were
+// rotated.
+func rotateAll() {
+	sourcesMu.Lock()
+	all := make([]*recoveringSource, 0, len(sources))
+	for _, s := range sources {
+		all = append(all, s)
+	}
+	sourcesMu.Unlock()
+
+	for _, s := range all {
+		s.rotate()
+	}
+}
+
+// rotate swaps in a token source built from the current credentials, keeping
+// the old one if the new credentials don't produce a token.
+func (s *recoveringSource) rotate() {
+	src, err := newTokenSource(context.Background(), s.subject)
+	if err != nil {
+		logger.Error.Printf("❌ Rotated Gmail credentials for %s don't work, keeping the current ones: %v", s.subject, err)
+		return
+	}
+	s.mu.Lock()
+	s.src = src
+	s.mu.Unlock()
+	s.ready.Store(true)
+	logger.Info.Printf("🔑 Switched Gmail credentials for %s to the rotated ones", s.subject)
+}
+
+// reinitPolicy reads GMAIL_AUTH_RETRY_BASE_DELAY and
+// GMAIL_AUTH_RETRY_MAX_DELAY, the backoff between attempts to re-initialize
+// failing credentials. Attempts continue until one succeeds.
+func reinitPolicy() retry.Policy {
+	return retry.FromEnv("GMAIL_AUTH", retry.Policy{BaseDelay: 5 * time.Second, MaxDelay: 5 * time.Minute})
+}
+
+// recoveringSource hands out tokens from the current delegated credentials.
+// When they stop working, for example because the key was revoked or the
+// clock drifted, it marks the token not ready and rebuilds them in the
+// background, swapping the new source in once it produces a token.
+type recoveringSource struct {
+	subject string
+	ready   atomic.Bool
+
+	mu  sync.Mutex
+	src oauth2.TokenSource
+}
+
+func (s *recoveringSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	src := s.src
+	s.mu.Unlock()
+
+	tok, err := src.Token()
+	if err != nil {
+		s.fail(err)
+		return nil, err
+	}
+	return tok, nil
+}
+
+// fail starts re-initialization, unless the credentials are already known
+// to be broken and one is under way. Sources that never finished
+// initializing are left alone.
+func (s *recoveringSource) fail(err error) {
+	if !s.ready.CompareAndSwap(true, false) {
+		return
+	}
+	logger.Error.Printf("❌ Gmail credentials for %s stopped working, re-initializing: %v", s.subject, err)
+	go s.reinitialize(backgroundContext())
+}
+
+// reinitialize rebuilds the token source until it works or ctx is done.
+func (s *recoveringSource) reinitialize(ctx context.Context) {
+	p := reinitPolicy()
+	for attempt := 1; ; attempt++ {
+		src, err := newTokenSource(ctx, s.subject)
+		if err == nil {
+			s.mu.Lock()
+			s.src = src
+			s.mu.Unlock()
+			s.ready.Store(true)
+			logger.Info.Printf("✅ Gmail credentials for %s re-initialized after %d attempt(s)", s.subject, attempt)
+			return
+		}
+		delay := p.Backoff(attempt)
+		logger.Warn.Printf("⚠️ Re-initializing Gmail credentials failed (attempt %d), retrying in %s: %v",
+			attempt, delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			logger.Warn.Printf("⚠️ Stopped re-initializing Gmail credentials for %s: %v", s.subject, ctx.Err())
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// unauthorizedTransport treats a 401 from Gmail as a sign the credentials
+// were revoked while a cached token was still valid.
+type unauthorizedTransport struct {
+	source *recoveringSource
+}
+
+func (t *unauthorizedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := (&requestid.Transport{}).RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.source.fail(fmt.Errorf("Gmail returned %s", resp.Status))
+	}
+	return resp, err
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Registry caches one Gmail service per mailbox, so handlers share a single
+// authenticated client and token source instead of building their own. It
+// is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	services Services
+	// loading serializes initialization per mailbox without holding mu.
+	loading map[string]*sync.Mutex
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{services: Services{}, loading: map[string]*sync.Mutex{}}
+}
+
+// Service returns the cached service for mailbox, initializing it with
+// LoadGmailServiceFor on first use. Failures aren't cached, so the next
+// call tries again.
+func (r *Registry) Service(ctx context.Context, mailbox string) (*gmail.Service, error) {
+	r.mu.Lock()
+	if srv, ok := r.services[mailbox]; ok {
+		r.mu.Unlock()
+		return srv, nil
+	}
+	lock, ok := r.loading[mailbox]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.loading[mailbox] = lock
+	}
+	r.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Another caller may have finished while this one waited.
+	r.mu.Lock()
+	srv, ok := r.services[mailbox]
+	r.mu.Unlock()
+	if ok {
+		return srv, nil
+	}
+
+	srv, err := LoadGmailServiceFor(ctx, mailbox)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.services[mailbox] = srv
+	r.mu.Unlock()
+	return srv, nil
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"voicemail-transcriber-production/internal/secret"
+
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+// ServiceAccountOption authenticates Google API clients as the service
+// account itself rather than a delegated mailbox, for resources shared with
+// it directly such as a spreadsheet. In "key" mode it uses the
+// gmail-token-json key; otherwise the runtime's default credentials.
+func ServiceAccountOption(ctx context.Context, scopes ...string) (option.ClientOption, error) {
+	if authMode() != "key" {
+		ts, err := google.DefaultTokenSource(ctx, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find default credentials: %w", err)
+		}
+		return option.WithTokenSource(ts), nil
+	}
+
+	key, err := secret.LoadSecret(ctx, KeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service account credentials: %w", err)
+	}
+	config, err := google.JWTConfigFromJSON(key, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWT config: %w", err)
+	}
+	return option.WithTokenSource(config.TokenSource(ctx)), nil
+}
+
+// DelegatedOption authenticates Google API clients as subject with scopes
+// beyond Gmail's, such as Tasks. The scopes must also be granted to the
+// service account's domain-wide delegation; the "oauth" mode only holds
+// Gmail consent and isn't supported.
+func DelegatedOption(ctx context.Context, subject string, scopes ...string) (option.ClientOption, error) {
+	switch authMode() {
+	case "iam":
+		ts, err := newIAMTokenSource(ctx, subject, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return option.WithTokenSource(ts), nil
+	case "oauth":
+		return nil, fmt.Errorf("GMAIL_AUTH_MODE=oauth can't authorize scopes other than Gmail's")
+	}
+
+	key, err := secret.LoadSecret(ctx, KeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service account credentials: %w", err)
+	}
+	config, err := google.JWTConfigFromJSON(key, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWT config: %w", err)
+	}
+	config.Subject = subject
+	return option.WithTokenSource(config.TokenSource(ctx)), nil
+}
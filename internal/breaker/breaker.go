@@ -0,0 +1,127 @@
+// Package breaker implements a circuit breaker that stops calls to a failing
+// dependency for a cooldown period.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+// ErrOpen is returned by Allow while the breaker is open.
+var ErrOpen = errors.New("circuit breaker open")
+
+type state int
+
+const (
+	closed state = iota
+	open
+	halfOpen
+)
+
+// Breaker opens after Threshold consecutive failures. While open, calls are
+// refused until Cooldown has passed; then a single trial call is let through,
+// closing the breaker on success and reopening it on failure.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+	// OnOpen, if set, is called once each time the breaker opens, with the
+	// failure that tripped it.
+	OnOpen func(err error)
+	// OnClose, if set, is called when the breaker closes again.
+	OnClose func()
+
+	mu       sync.Mutex
+	state    state
+	failures int
+	openedAt time.Time
+}
+
+// New returns a closed breaker.
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Name: name, Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow reports whether a call may go ahead, returning ErrOpen if not.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case open:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return ErrOpen
+		}
+		b.state = halfOpen
+		logger.Info.Printf("🔌 %s circuit half-open, trying one call", b.Name)
+		return nil
+	case halfOpen:
+		// A trial call is already in flight.
+		return ErrOpen
+	default:
+		return nil
+	}
+}
+
+// Available reports whether the breaker would let a call through now,
+// without claiming the half-open trial.
+func (b *Breaker) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == closed || (b.state == open && time.Since(b.openedAt) >= b.Cooldown)
+}
+
+// Success records a successful call.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	wasOpen := b.state != closed
+	b.state = closed
+	b.failures = 0
+	onClose := b.OnClose
+	b.mu.Unlock()
+
+	if wasOpen {
+		logger.Info.Printf("🔌 %s circuit closed", b.Name)
+		if onClose != nil {
+			onClose()
+		}
+	}
+}
+
+// Failure records a failed call, opening the breaker when the threshold is
+// reached or a half-open trial fails.
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	b.failures++
+	trip := b.state == halfOpen || (b.state == closed && b.failures >= b.Threshold)
+	first := b.state == closed
+	if trip {
+		b.state = open
+		b.openedAt = time.Now()
+	}
+	failures, onOpen := b.failures, b.OnOpen
+	b.mu.Unlock()
+
+	if !trip {
+		return
+	}
+	logger.Error.Printf("🔌 %s circuit open for %s after %d failure(s): %v", b.Name, b.Cooldown, failures, err)
+	// Only alert on the transition from closed, not on every failed trial.
+	if first && onOpen != nil {
+		onOpen(err)
+	}
+}
+
+// Cancel records a call abandoned before the dependency answered, such as
+// one whose context was cancelled. It says nothing about the dependency's
+// health, so counts are left alone; a half-open trial is handed back so the
+// next call can make it.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == halfOpen {
+		b.state = open
+	}
+}
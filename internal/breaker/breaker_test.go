@@ -0,0 +1,126 @@
+package breaker
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+var errDown = errors.New("service down")
+
+// tripped returns a breaker that has just opened after two failures.
+func tripped(t *testing.T, cooldown time.Duration) *Breaker {
+	t.Helper()
+	b := New("test", 2, cooldown)
+	b.Failure(errDown)
+	b.Failure(errDown)
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() after tripping = %v, want ErrOpen", err)
+	}
+	return b
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	b := New("test", 2, time.Minute)
+	b.Failure(errDown)
+	b.Success()
+	b.Failure(errDown)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v, want nil: a success should reset the streak", err)
+	}
+}
+
+func TestFailureOpensAtThreshold(t *testing.T) {
+	opened := 0
+	b := New("test", 3, time.Minute)
+	b.OnOpen = func(error) { opened++ }
+	for range 2 {
+		b.Failure(errDown)
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow() below the threshold = %v, want nil", err)
+		}
+	}
+	b.Failure(errDown)
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() at the threshold = %v, want ErrOpen", err)
+	}
+	if b.Available() {
+		t.Error("Available() = true while open")
+	}
+	if opened != 1 {
+		t.Errorf("OnOpen called %d time(s), want 1", opened)
+	}
+}
+
+func TestHalfOpenTrial(t *testing.T) {
+	tests := []struct {
+		name string
+		// outcome records how the trial call went.
+		outcome   func(b *Breaker)
+		wantAllow error
+		wantClose bool
+	}{
+		{
+			name:      "success closes",
+			outcome:   (*Breaker).Success,
+			wantAllow: nil,
+			wantClose: true,
+		},
+		{
+			name:      "failure reopens",
+			outcome:   func(b *Breaker) { b.Failure(errDown) },
+			wantAllow: ErrOpen,
+		},
+		{
+			name:      "cancel hands the trial back",
+			outcome:   (*Breaker).Cancel,
+			wantAllow: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tripped(t, 10*time.Millisecond)
+			closed := false
+			b.OnClose = func() { closed = true }
+			time.Sleep(20 * time.Millisecond)
+
+			if err := b.Allow(); err != nil {
+				t.Fatalf("Allow() after the cooldown = %v, want nil", err)
+			}
+			if err := b.Allow(); !errors.Is(err, ErrOpen) {
+				t.Fatalf("Allow() during the trial = %v, want ErrOpen", err)
+			}
+			tt.outcome(b)
+
+			if err := b.Allow(); !errors.Is(err, tt.wantAllow) {
+				t.Errorf("Allow() after the trial = %v, want %v", err, tt.wantAllow)
+			}
+			if closed != tt.wantClose {
+				t.Errorf("closed = %v, want %v", closed, tt.wantClose)
+			}
+		})
+	}
+}
+
+func TestCancelKeepsCounts(t *testing.T) {
+	b := New("test", 2, time.Minute)
+	b.Failure(errDown)
+	b.Cancel()
+	b.Failure(errDown)
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() = %v, want ErrOpen: a cancel must not reset the streak", err)
+	}
+
+	b = tripped(t, time.Minute)
+	b.Cancel()
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() after cancelling while open = %v, want ErrOpen", err)
+	}
+}
@@ -0,0 +1,138 @@
+package carrier
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+// Details are the facts a carrier's notification email carries about a
+// voicemail.
+type Details struct {
+	Carrier  string
+	Caller   string
+	CalledAt time.Time
+	Mailbox  string
+}
+
+// Parser extracts Details from a carrier's voicemail notification.
+type Parser func(subject, body string) Details
+
+type registration struct {
+	name   string
+	parser Parser
+}
+
+var (
+	registry     = make(map[string]registration)
+	registryLock sync.RWMutex
+)
+
+// Register associates a parser with a sender domain. Subdomains of domain
+// (e.g. mail.bt.com for bt.com) use the same parser.
+func Register(domain, name string, p Parser) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[strings.ToLower(domain)] = registration{name: name, parser: p}
+}
+
+// Parse selects a parser by the domain of the From address, falling back to
+// generic caller extraction for unknown senders.
+func Parse(from, subject, body string) Details {
+	if reg, ok := lookup(senderDomain(from)); ok {
+		d := reg.parser(subject, body)
+		d.Carrier = reg.name
+		if d.Caller == "" {
+			d.Caller = voicemail.ExtractCaller(subject, body)
+		}
+		return d
+	}
+	return Details{Caller: voicemail.ExtractCaller(subject, body)}
+}
+
+func lookup(domain string) (registration, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	for domain != "" {
+		if reg, ok := registry[domain]; ok {
+			return reg, true
+		}
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return registration{}, false
+}
+
+func senderDomain(from string) string {
+	addr := from
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		addr = parsed.Address
+	}
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return strings.ToLower(strings.TrimSpace(addr[i+1:]))
+	}
+	return ""
+}
+
+// format describes how one carrier lays out its notification.
+type format struct {
+	caller   []*regexp.Regexp
+	calledAt []*regexp.Regexp
+	layouts  []string
+	mailbox  []*regexp.Regexp
+}
+
+func (f format) parse(subject, body string) Details {
+	var d Details
+	if m := firstMatch(f.caller, subject, body); m != "" {
+		d.Caller = voicemail.NormalizeNumber(m)
+	}
+	if m := firstMatch(f.mailbox, subject, body); m != "" {
+		d.Mailbox = voicemail.NormalizeNumber(m)
+	}
+	if m := firstMatch(f.calledAt, subject, body); m != "" {
+		d.CalledAt = parseTime(strings.Join(strings.Fields(m), " "), f.layouts)
+	}
+	return d
+}
+
+// firstMatch returns the first capture of patterns in the subject, then in
+// the body. They are searched separately so a match can't run from the end
+// of one into the start of the other.
+func firstMatch(patterns []*regexp.Regexp, subject, body string) string {
+	for _, text := range []string{subject, body} {
+		for _, re := range patterns {
+			if m := re.FindStringSubmatch(text); m != nil {
+				return strings.TrimSpace(m[1])
+			}
+		}
+	}
+	return ""
+}
+
+var london = loadLondon()
+
+func loadLondon() *time.Location {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func parseTime(s string, layouts []string) time.Time {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, london); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
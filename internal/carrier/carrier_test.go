@@ -0,0 +1,97 @@
+package carrier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, london)
+	}
+
+	tests := []struct {
+		name                string
+		from, subject, body string
+		want                Details
+	}{
+		{
+			name:    "BT",
+			from:    "BT <voicemail@btonephone.com>",
+			subject: "Voicemail from 07123 456789",
+			body:    "You have a new voicemail.\nReceived on 12/03/2024 at 10:15\nLeft for 01632 960123",
+			want:    Details{Carrier: "BT One Phone", Caller: "07123456789", CalledAt: at(2024, time.March, 12, 10, 15, 0), Mailbox: "01632960123"},
+		},
+		{
+			name:    "BT subdomain with the caller in the body",
+			from:    "noreply@mail.bt.com",
+			subject: "New message",
+			body:    "Caller number: +44 7123 456789\nDate: 01/07/2024 18:45:30\nMailbox number: 01632 960123",
+			want:    Details{Carrier: "BT", Caller: "07123456789", CalledAt: at(2024, time.July, 1, 18, 45, 30), Mailbox: "01632960123"},
+		},
+		{
+			name:    "BT falls back to generic caller extraction",
+			from:    "voicemail@bt.com",
+			subject: "New message",
+			body:    "Caller ID: 07123 456789",
+			want:    Details{Carrier: "BT", Caller: "07123456789"},
+		},
+		{
+			name:    "Vodafone",
+			from:    "Vodafone <voicemail@vodafone.co.uk>",
+			subject: "You have a voice message from +44 7700 900123",
+			body:    "Date: 05/06/2024 14:30:05\nYour number: 07700 900999",
+			want:    Details{Carrier: "Vodafone", Caller: "07700900123", CalledAt: at(2024, time.June, 5, 14, 30, 5), Mailbox: "07700900999"},
+		},
+		{
+			name:    "Vodafone time before date",
+			from:    "voicemail@vodafone.com",
+			subject: "New voicemail",
+			body:    "From: 07700 900123\nLeft at 14:30 on 05/06/2024",
+			want:    Details{Carrier: "Vodafone", Caller: "07700900123", CalledAt: at(2024, time.June, 5, 14, 30, 0)},
+		},
+		{
+			name:    "EE with a date on the body's first line",
+			from:    "EE <voicemail@ee.co.uk>",
+			subject: "New voicemail from 07123 456789",
+			body:    "12 March 2024 at 10:15\nReceived: 12 March 2024 at 10:15\nSent to 07999 111222",
+			want:    Details{Carrier: "EE", Caller: "07123456789", CalledAt: at(2024, time.March, 12, 10, 15, 0), Mailbox: "07999111222"},
+		},
+		{
+			name:    "EE numeric date",
+			from:    "voicemail@ee.co.uk",
+			subject: "Voicemail",
+			body:    "Caller: 020 7946 0000\nSent 03/11/2024 at 08:05",
+			want:    Details{Carrier: "EE", Caller: "02079460000", CalledAt: at(2024, time.November, 3, 8, 5, 0)},
+		},
+		{
+			name:    "O2",
+			from:    "O2 <voicemail@o2.co.uk>",
+			subject: "Voicemail",
+			body:    "Message from 07123 456789\nTime: 09:05 on 01/02/2024\nMailbox: 07700 900555",
+			want:    Details{Carrier: "O2", Caller: "07123456789", CalledAt: at(2024, time.February, 1, 9, 5, 0), Mailbox: "07700900555"},
+		},
+		{
+			name:    "O2 long date",
+			from:    "voicemail@o2.com",
+			subject: "Voicemail",
+			body:    "From: 07123 456789\nDate: Monday, 1 July 2024 18:45",
+			want:    Details{Carrier: "O2", Caller: "07123456789", CalledAt: at(2024, time.July, 1, 18, 45, 0)},
+		},
+		{
+			name:    "unknown sender",
+			from:    "someone@example.com",
+			subject: "Voicemail from 07123 456789",
+			body:    "12 March 2024",
+			want:    Details{Caller: "07123456789"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.from, tt.subject, tt.body)
+			if got.Carrier != tt.want.Carrier || got.Caller != tt.want.Caller || got.Mailbox != tt.want.Mailbox || !got.CalledAt.Equal(tt.want.CalledAt) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
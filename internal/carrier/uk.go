@@ -0,0 +1,96 @@
+package carrier
+
+import "regexp"
+
+// number matches a phone number on a single line: only literal spaces may
+// separate its digits.
+const number = `(\+?\d[\d \-()]{6,}\d)`
+
+var commonLayouts = []string{
+	"02/01/2006 at 15:04",
+	"02/01/2006 at 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006 15:04:05",
+	"2 January 2006 at 15:04",
+	"2 January 2006 15:04",
+	"2 Jan 2006 at 15:04",
+	"2 Jan 2006 15:04",
+	"Mon 2 Jan 2006 15:04",
+	"Monday, 2 January 2006 15:04",
+	"15:04 on 02/01/2006",
+	"15:04 on 2 January 2006",
+}
+
+var (
+	bt = format{
+		caller: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)voicemail from\s+` + number),
+			regexp.MustCompile(`(?i)caller(?: number)?:\s*` + number),
+		},
+		calledAt: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)received (?:on )?(\d{1,2}/\d{2}/\d{4}(?: at)? \d{1,2}:\d{2}(?::\d{2})?)`),
+			regexp.MustCompile(`(?i)(?:date|time):\s*(\d{1,2}/\d{2}/\d{4} \d{1,2}:\d{2}(?::\d{2})?)`),
+		},
+		layouts: commonLayouts,
+		mailbox: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:for )?mailbox(?: number)?:?\s+` + number),
+			regexp.MustCompile(`(?i)left (?:for|on)\s+` + number),
+		},
+	}
+
+	vodafone = format{
+		caller: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:voicemail|voice message|message) from\s+` + number),
+			regexp.MustCompile(`(?i)from:\s*` + number),
+		},
+		calledAt: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:date|received):\s*(\d{1,2}/\d{2}/\d{4} \d{1,2}:\d{2}(?::\d{2})?)`),
+			regexp.MustCompile(`(?i)at (\d{1,2}:\d{2} on \d{1,2}/\d{2}/\d{4})`),
+		},
+		layouts: commonLayouts,
+		mailbox: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:your number|mailbox):\s*` + number),
+		},
+	}
+
+	ee = format{
+		caller: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:new )?voicemail from\s+` + number),
+			regexp.MustCompile(`(?i)(?:caller|from):\s*` + number),
+		},
+		calledAt: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:received|sent):?\s*(\d{1,2} [A-Za-z]+ \d{4}(?: at)? \d{1,2}:\d{2})`),
+			regexp.MustCompile(`(?i)(?:received|sent):?\s*(\d{1,2}/\d{2}/\d{4}(?: at)? \d{1,2}:\d{2})`),
+		},
+		layouts: commonLayouts,
+		mailbox: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:sent to|for)\s+` + number),
+		},
+	}
+
+	o2 = format{
+		caller: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:message|voicemail) from\s+` + number),
+			regexp.MustCompile(`(?i)from:\s*` + number),
+		},
+		calledAt: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)time:\s*(\d{1,2}:\d{2} on \d{1,2}/\d{2}/\d{4})`),
+			regexp.MustCompile(`(?i)(?:time|date|received):\s*(\d{1,2}/\d{2}/\d{4} \d{1,2}:\d{2}(?::\d{2})?)`),
+			regexp.MustCompile(`(?i)(?:time|date|received):\s*((?:[A-Za-z]+,? )?\d{1,2} [A-Za-z]+ \d{4} \d{1,2}:\d{2})`),
+		},
+		layouts: commonLayouts,
+		mailbox: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)mailbox:\s*` + number),
+		},
+	}
+)
+
+func init() {
+	Register("btonephone.com", "BT One Phone", bt.parse)
+	Register("bt.com", "BT", bt.parse)
+	Register("vodafone.co.uk", "Vodafone", vodafone.parse)
+	Register("vodafone.com", "Vodafone", vodafone.parse)
+	Register("ee.co.uk", "EE", ee.parse)
+	Register("o2.co.uk", "O2", o2.parse)
+	Register("o2.com", "O2", o2.parse)
+}
@@ -0,0 +1,118 @@
+// Package config loads settings from an optional YAML or JSON file named by
+// CONFIG_FILE. Environment variables always take precedence over the file.
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"voicemail-transcriber-production/internal/routing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// routingRulesKey holds the routing rules, which have no environment
+// variable equivalent.
+const routingRulesKey = "routing_rules"
+
+// fromFile records the environment variables Load set, so a reload can
+// replace them while still leaving real environment variables alone.
+var fromFile = map[string]bool{}
+
+// Path reads CONFIG_FILE.
+func Path() string {
+	return os.Getenv("CONFIG_FILE")
+}
+
+// Load reads the config file, if one is configured, and applies it. It can be
+// called again to pick up changes to the file. Every
+// key other than routing_rules names an environment variable: nested keys
+// are joined with underscores and upper-cased, so
+//
+//	email:
+//	  to: [office@example.com, manager@example.com]
+//
+// sets EMAIL_TO=office@example.com,manager@example.com unless EMAIL_TO is
+// already set. It returns the names of the settings taken from the file.
+// Since YAML is a superset of JSON, JSON files work too.
+func Load() ([]string, error) {
+	path := Path()
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var applied []string
+	if node, ok := raw[routingRulesKey]; ok {
+		var rules []routing.Rule
+		if err := node.Decode(&rules); err != nil {
+			return nil, fmt.Errorf("invalid %s in %s: %w", routingRulesKey, path, err)
+		}
+		routing.SetStaticRules(rules)
+		applied = append(applied, routingRulesKey)
+		delete(raw, routingRulesKey)
+	} else {
+		routing.SetStaticRules(nil)
+	}
+
+	settings := map[string]string{}
+	for key, node := range raw {
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid %s in %s: %w", key, path, err)
+		}
+		flatten(envName(key), value, settings)
+	}
+
+	for name, value := range settings {
+		if _, set := os.LookupEnv(name); set && !fromFile[name] {
+			continue
+		}
+		os.Setenv(name, value)
+		fromFile[name] = true
+		applied = append(applied, name)
+	}
+	// Settings dropped from the file since the last load go back to unset.
+	for name := range fromFile {
+		if _, ok := settings[name]; !ok {
+			os.Unsetenv(name)
+			delete(fromFile, name)
+		}
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
+
+// flatten converts a decoded value into environment settings: maps recurse
+// with their keys appended, lists are comma-joined and scalars are printed.
+func flatten(name string, value interface{}, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flatten(name+"_"+envName(key), child, out)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		out[name] = strings.Join(items, ",")
+	case nil:
+	default:
+		out[name] = fmt.Sprint(v)
+	}
+}
+
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
@@ -0,0 +1,50 @@
+// Package crm looks voicemail callers up in the business's CRM.
+package crm
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second, Transport: &requestid.Transport{}}
+
+// lookupProvider reads CRM_LOOKUP, the CRM callers are looked up in:
+// "hubspot", or "" (the default) for none.
+func lookupProvider() string {
+	switch p := strings.ToLower(strings.TrimSpace(os.Getenv("CRM_LOOKUP"))); p {
+	case "", "none":
+		return ""
+	case "hubspot":
+		return p
+	default:
+		logger.Warn.Printf("⚠️ Invalid CRM_LOOKUP %q, using default", p)
+		return ""
+	}
+}
+
+// Enrich attaches the CRM contact matching vm's caller, if any. Lookup
+// failures are logged and the voicemail is delivered without a contact.
+func Enrich(ctx context.Context, vm *voicemail.Voicemail) {
+	provider := lookupProvider()
+	if provider == "" || vm.Caller == "" || vm.Caller == voicemail.Withheld {
+		return
+	}
+
+	contact, err := hubSpotLookup(ctx, vm.Caller)
+	if err != nil {
+		logger.Warn.Printf("%s⚠️ Could not look up caller %s in %s: %v", requestid.Prefix(ctx), vm.Caller, provider, err)
+		return
+	}
+	if contact == nil {
+		logger.Debug.Printf("%s👤 No %s contact for caller %s", requestid.Prefix(ctx), provider, vm.Caller)
+		return
+	}
+	logger.Info.Printf("%s👤 Caller %s is %s in %s", requestid.Prefix(ctx), vm.Caller, contact.Name, provider)
+	vm.Contact = contact
+}
@@ -0,0 +1,127 @@
+package crm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+// HubSpotTokenSecret holds a HubSpot private app access token with the
+// crm.objects.contacts.read scope.
+const HubSpotTokenSecret = "hubspot-access-token"
+
+const hubSpotSearchURL = "https://api.hubapi.com/crm/v3/objects/contacts/search"
+
+// hubSpotAppointmentProperty reads HUBSPOT_APPOINTMENT_PROPERTY, the contact
+// property holding the last appointment, defaulting to HubSpot's last booked
+// meeting date.
+func hubSpotAppointmentProperty() string {
+	if p := os.Getenv("HUBSPOT_APPOINTMENT_PROPERTY"); p != "" {
+		return p
+	}
+	return "hs_last_booked_meeting_date"
+}
+
+// hubSpotLookup finds the contact whose phone or mobile number matches
+// caller, returning nil when there is none. HubSpot indexes numbers without
+// their country code or trunk prefix, so the caller's leading 0 is dropped.
+func hubSpotLookup(ctx context.Context, caller string) (*voicemail.Contact, error) {
+	token, err := secret.LoadSecret(ctx, HubSpotTokenSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load HubSpot access token: %w", err)
+	}
+
+	number := strings.TrimPrefix(voicemail.NormalizeNumber(caller), "0")
+	appointment := hubSpotAppointmentProperty()
+	filter := func(property string) map[string]interface{} {
+		return map[string]interface{}{
+			"filters": []map[string]string{{"propertyName": property, "operator": "EQ", "value": number}},
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"filterGroups": []map[string]interface{}{
+			filter("hs_searchable_calculated_phone_number"),
+			filter("hs_searchable_calculated_mobile_number"),
+		},
+		"properties": []string{"firstname", "lastname", "company", appointment},
+		"limit":      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hubSpotSearchURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HubSpot request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HubSpot request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HubSpot response: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		secret.Invalidate(HubSpotTokenSecret)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HubSpot returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		Results []struct {
+			ID         string            `json:"id"`
+			Properties map[string]string `json:"properties"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse HubSpot response: %w", err)
+	}
+	if len(result.Results) == 0 {
+		return nil, nil
+	}
+
+	found := result.Results[0]
+	props := found.Properties
+	contact := &voicemail.Contact{
+		ID:      found.ID,
+		Name:    strings.TrimSpace(props["firstname"] + " " + props["lastname"]),
+		Company: props["company"],
+	}
+	if contact.Name == "" {
+		contact.Name = contact.Company
+	}
+	if v := props[appointment]; v != "" {
+		contact.LastAppointment = parseHubSpotTime(v)
+	}
+	return contact, nil
+}
+
+// parseHubSpotTime reads a date or datetime property, which HubSpot returns
+// as either an RFC 3339 string or epoch milliseconds.
+func parseHubSpotTime(v string) time.Time {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t
+	}
+	var ms int64
+	if _, err := fmt.Sscan(v, &ms); err == nil {
+		return time.UnixMilli(ms)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,157 @@
+package dashboard
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"voicemail-transcriber-production/internal/archive"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var funcs = template.FuncMap{
+	"preview": preview,
+	"seconds": func(s float64) string {
+		return voicemail.FormatDuration(time.Duration(s * float64(time.Second)))
+	},
+	"when": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(location).Format("Mon 2 Jan, 15:04")
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.0f%%", f*100)
+	},
+}
+
+var templates = template.Must(template.New("").Funcs(funcs).ParseFS(templateFS, "templates/*.html"))
+
+const pageSize = 50
+
+// location is the zone times are shown in, set from DASHBOARD_TIMEZONE by
+// NewHandler.
+var location = time.UTC
+
+func loadLocation() *time.Location {
+	name := os.Getenv("DASHBOARD_TIMEZONE")
+	if name == "" {
+		name = "Europe/London"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn.Printf("⚠️ Invalid DASHBOARD_TIMEZONE %q, using UTC", name)
+		return time.UTC
+	}
+	return loc
+}
+
+// Server serves the web dashboard for browsing stored transcripts.
+type Server struct {
+	Firestore *firestore.Client
+}
+
+// NewHandler returns the /dashboard/ routes.
+func NewHandler(fsClient *firestore.Client) http.Handler {
+	s := &Server{Firestore: fsClient}
+	location = loadLocation()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dashboard/{$}", s.List)
+	mux.HandleFunc("GET /dashboard/transcripts/{id}", s.Detail)
+	return mux
+}
+
+type listPage struct {
+	Records       []transcripts.Record
+	Caller        string
+	Query         string
+	NextPageToken string
+}
+
+// List shows the most recent voicemails, optionally filtered by caller or a
+// keyword search.
+func (s *Server) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page := listPage{Caller: q.Get("caller"), Query: q.Get("q")}
+
+	if page.Query != "" {
+		results, err := transcripts.Search(r.Context(), s.Firestore, page.Query, pageSize)
+		if err != nil {
+			s.fail(w, err)
+			return
+		}
+		for _, res := range results {
+			page.Records = append(page.Records, res.Record)
+		}
+	} else {
+		records, next, err := transcripts.List(r.Context(), s.Firestore, transcripts.ListOptions{
+			Caller:    page.Caller,
+			Limit:     pageSize,
+			PageToken: q.Get("pageToken"),
+		})
+		if err != nil {
+			s.fail(w, err)
+			return
+		}
+		page.Records, page.NextPageToken = records, next
+	}
+
+	render(w, "list.html", page)
+}
+
+// Detail shows a single voicemail with its full transcript.
+func (s *Server) Detail(w http.ResponseWriter, r *http.Request) {
+	rec, err := transcripts.Get(r.Context(), s.Firestore, r.PathValue("id"))
+	if errors.Is(err, transcripts.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	archive.SignRecordings(r.Context(), rec)
+	render(w, "detail.html", rec)
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	logger.Error.Printf("❌ Dashboard: %v", err)
+	http.Error(w, "Failed to load transcripts", http.StatusInternalServerError)
+}
+
+func render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		logger.Error.Printf("❌ Failed to render %s: %v", name, err)
+	}
+}
+
+// preview shortens a transcript to roughly 120 characters, breaking at a
+// word where possible.
+func preview(s string) string {
+	runes := []rune(strings.Join(strings.Fields(s), " "))
+	if len(runes) <= 120 {
+		return string(runes)
+	}
+	cut := 120
+	for i := cut; i > 60; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return string(runes[:cut]) + "…"
+}
@@ -0,0 +1,127 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Store remembers which Gmail messages have already been picked up, so
+// redelivered notifications don't produce duplicate transcriptions.
+//
+// A claim starts as a short lease held while the message is processed, and
+// Complete turns it into a mark kept for the TTL. A lease left behind by an
+// instance that died mid-message expires, so a later delivery can take the
+// message over and resume it.
+type Store interface {
+	// Claim takes a lease on msgID. It returns false when the message was
+	// already completed and the mark hasn't expired, and ErrLeased when
+	// another attempt still holds an unexpired lease.
+	Claim(ctx context.Context, msgID string) (bool, error)
+	// Complete marks a claimed message as processed for the TTL.
+	Complete(ctx context.Context, msgID string) error
+	// Release forgets a claim so the message can be picked up again.
+	Release(ctx context.Context, msgID string) error
+	// Cleanup removes expired claims.
+	Cleanup(ctx context.Context) error
+}
+
+// ErrLeased is returned by Claim while another attempt holds the message's
+// lease.
+var ErrLeased = errors.New("message is being processed by another attempt")
+
+// TTL reads DEDUP_TTL, how long a processed message is remembered.
+func TTL() time.Duration {
+	if v := os.Getenv("DEDUP_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid DEDUP_TTL %q, using default", v)
+	}
+	return 30 * 24 * time.Hour
+}
+
+// Lease reads DEDUP_LEASE, how long a claimed message is held before another
+// delivery may take it over (default 10m). It must comfortably exceed the
+// time taken to process one message.
+func Lease() time.Duration {
+	if v := os.Getenv("DEDUP_LEASE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid DEDUP_LEASE %q, using default", v)
+	}
+	return 10 * time.Minute
+}
+
+// New returns the store selected by DEDUP_STORE: "memory", or "firestore"
+// (the default) when a Firestore client is available.
+func New(client *firestore.Client) Store {
+	if strings.EqualFold(os.Getenv("DEDUP_STORE"), "memory") || client == nil {
+		return NewMemoryStore(TTL(), Lease())
+	}
+	return NewFirestoreStore(client, TTL(), Lease())
+}
+
+// MemoryStore is a per-process Store, suitable for local development.
+type MemoryStore struct {
+	ttl    time.Duration
+	lease  time.Duration
+	mu     sync.Mutex
+	claims map[string]memoryClaim
+}
+
+type memoryClaim struct {
+	expires time.Time
+	leased  bool
+}
+
+func NewMemoryStore(ttl, lease time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, lease: lease, claims: make(map[string]memoryClaim)}
+}
+
+func (m *MemoryStore) Claim(_ context.Context, msgID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.claims[msgID]; ok && time.Now().Before(c.expires) {
+		if c.leased {
+			return false, ErrLeased
+		}
+		return false, nil
+	}
+	m.claims[msgID] = memoryClaim{expires: time.Now().Add(m.lease), leased: true}
+	return true, nil
+}
+
+func (m *MemoryStore) Complete(_ context.Context, msgID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claims[msgID] = memoryClaim{expires: time.Now().Add(m.ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Release(_ context.Context, msgID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.claims, msgID)
+	return nil
+}
+
+func (m *MemoryStore) Cleanup(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, c := range m.claims {
+		if now.After(c.expires) {
+			delete(m.claims, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreLease(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore(time.Hour, 20*time.Millisecond)
+
+	if ok, err := m.Claim(ctx, "m1"); !ok || err != nil {
+		t.Fatalf("first Claim() = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := m.Claim(ctx, "m1"); ok || !errors.Is(err, ErrLeased) {
+		t.Fatalf("Claim() while leased = %v, %v, want false, ErrLeased", ok, err)
+	}
+
+	// The attempt holding the lease dies; once it runs out the message can
+	// be taken over.
+	time.Sleep(30 * time.Millisecond)
+	if ok, err := m.Claim(ctx, "m1"); !ok || err != nil {
+		t.Fatalf("Claim() after the lease expired = %v, %v, want true, nil", ok, err)
+	}
+
+	if err := m.Complete(ctx, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ok, err := m.Claim(ctx, "m1"); ok || err != nil {
+		t.Fatalf("Claim() after Complete() = %v, %v, want false, nil", ok, err)
+	}
+
+	if err := m.Release(ctx, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := m.Claim(ctx, "m1"); !ok || err != nil {
+		t.Fatalf("Claim() after Release() = %v, %v, want true, nil", ok, err)
+	}
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore(time.Hour, time.Millisecond)
+	m.Claim(ctx, "expired")
+	m.Claim(ctx, "done")
+	m.Complete(ctx, "done")
+	time.Sleep(5 * time.Millisecond)
+
+	if err := m.Cleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.claims["expired"]; ok {
+		t.Error("expired lease was not removed")
+	}
+	if _, ok := m.claims["done"]; !ok {
+		t.Error("completed claim was removed")
+	}
+}
@@ -0,0 +1,116 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const processedCollection = "processed_messages"
+
+// FirestoreStore keeps claims in the processed_messages collection so they
+// are shared by every instance and survive restarts. Each document carries an
+// expiresAt field, which a Firestore TTL policy can use to delete it; Cleanup
+// removes expired claims for databases without one. A lease is marked with
+// leased; documents written before leases existed are completed claims.
+type FirestoreStore struct {
+	client *firestore.Client
+	ttl    time.Duration
+	lease  time.Duration
+}
+
+func NewFirestoreStore(client *firestore.Client, ttl, lease time.Duration) *FirestoreStore {
+	return &FirestoreStore{client: client, ttl: ttl, lease: lease}
+}
+
+func (s *FirestoreStore) Claim(ctx context.Context, msgID string) (bool, error) {
+	ref := s.client.Collection(processedCollection).Doc(msgID)
+	claimed, leased := false, false
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed, leased = false, false
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			data := doc.Data()
+			if expires, ok := data["expiresAt"].(time.Time); ok && time.Now().Before(expires) {
+				leased, _ = data["leased"].(bool)
+				return nil
+			}
+		}
+
+		now := time.Now()
+		claimed = true
+		return tx.Set(ref, map[string]interface{}{
+			"claimedAt": now,
+			"expiresAt": now.Add(s.lease),
+			"leased":    true,
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to claim message %s: %w", msgID, err)
+	}
+	if leased {
+		return false, fmt.Errorf("message %s: %w", msgID, ErrLeased)
+	}
+	return claimed, nil
+}
+
+func (s *FirestoreStore) Complete(ctx context.Context, msgID string) error {
+	now := time.Now()
+	_, err := s.client.Collection(processedCollection).Doc(msgID).Set(ctx, map[string]interface{}{
+		"processedAt": now,
+		"expiresAt":   now.Add(s.ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete message %s: %w", msgID, err)
+	}
+	return nil
+}
+
+func (s *FirestoreStore) Release(ctx context.Context, msgID string) error {
+	if _, err := s.client.Collection(processedCollection).Doc(msgID).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to release message %s: %w", msgID, err)
+	}
+	return nil
+}
+
+func (s *FirestoreStore) Cleanup(ctx context.Context) error {
+	iter := s.client.Collection(processedCollection).
+		Where("expiresAt", "<", time.Now()).
+		Limit(500).
+		Documents(ctx)
+	defer iter.Stop()
+
+	bw := s.client.BulkWriter(ctx)
+	deleted := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			bw.End()
+			return fmt.Errorf("failed to list expired claims: %w", err)
+		}
+		if _, err := bw.Delete(doc.Ref); err != nil {
+			bw.End()
+			return fmt.Errorf("failed to delete expired claim %s: %w", doc.Ref.ID, err)
+		}
+		deleted++
+	}
+	bw.End()
+
+	if deleted > 0 {
+		logger.Info.Printf("🧹 Removed %d expired processed-message claim(s)", deleted)
+	}
+	return nil
+}
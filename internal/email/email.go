@@ -0,0 +1,138 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// MessageIDHeader carries the Gmail ID of the voicemail a transcription
+// email was sent for. Bounces quote the original headers, so it ties a
+// delivery failure back to its transcript.
+const MessageIDHeader = "X-Voicemail-Message-Id"
+
+// Sender sends a composed Gmail message on behalf of the mailbox.
+type Sender interface {
+	SendMessage(ctx context.Context, msg *gmail.Message) error
+}
+
+// SendTranscription emails the transcriptions of every recording in vm to
+// the given recipients as a single message. When the original message is
+// known the transcription is sent as a reply in its thread.
+func SendTranscription(ctx context.Context, sender Sender, vm *voicemail.Voicemail, rcpt Recipients) error {
+	if len(vm.Recordings) == 0 {
+		return fmt.Errorf("no recordings to send")
+	}
+
+	if err := rcpt.Validate(); err != nil {
+		return err
+	}
+
+	raw, err := compose(rcpt, vm)
+	if err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+
+	message := gmail.Message{
+		Raw:      base64.URLEncoding.EncodeToString(raw),
+		ThreadId: vm.ThreadID,
+	}
+
+	// Send the email, through the outbox when there is one
+	if outbox, ok := sender.(*Outbox); ok {
+		err = outbox.send(ctx, outboxKey(vm, rcpt), vm.MessageID, &message)
+	} else {
+		err = sender.SendMessage(ctx, &message)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	logger.Info.Printf("✉️ Transcription email sent successfully to %d recipient(s) (%d recording(s))",
+		len(rcpt.To)+len(rcpt.CC)+len(rcpt.BCC), len(vm.Recordings))
+	return nil
+}
+
+// compose builds an RFC 2822 multipart/alternative message with a plain-text
+// body and a styled HTML body rendered from the email templates.
+func compose(rcpt Recipients, vm *voicemail.Voicemail) ([]byte, error) {
+	subject, text, html, err := render(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+
+	writeAddressHeader(&msg, "To", rcpt.To)
+	writeAddressHeader(&msg, "Cc", rcpt.CC)
+	writeAddressHeader(&msg, "Bcc", rcpt.BCC)
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject)))
+	if vm.RFC822MessageID != "" {
+		msg.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", vm.RFC822MessageID))
+		msg.WriteString(fmt.Sprintf("References: %s\r\n", references(vm)))
+	}
+	if vm.MessageID != "" {
+		msg.WriteString(fmt.Sprintf("%s: %s\r\n", MessageIDHeader, vm.MessageID))
+	}
+	if vm.Urgent() {
+		// Flag the message as high priority in Outlook and other clients.
+		msg.WriteString("X-Priority: 1 (Highest)\r\n")
+		msg.WriteString("Importance: High\r\n")
+	}
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%s\r\n", mw.Boundary()))
+	msg.WriteString("\r\n")
+
+	if err := writePart(mw, "text/plain; charset=UTF-8", text); err != nil {
+		return nil, err
+	}
+	if err := writePart(mw, "text/html; charset=UTF-8", html); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+func writeAddressHeader(msg *bytes.Buffer, name string, addrs []string) {
+	if len(addrs) == 0 {
+		return
+	}
+	msg.WriteString(fmt.Sprintf("%s: %s\r\n", name, strings.Join(addrs, ", ")))
+}
+
+func writePart(mw *multipart.Writer, contentType, content string) error {
+	w, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func references(vm *voicemail.Voicemail) string {
+	if vm.References == "" {
+		return vm.RFC822MessageID
+	}
+	return vm.References + " " + vm.RFC822MessageID
+}
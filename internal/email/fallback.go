@@ -0,0 +1,77 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"voicemail-transcriber-production/internal/logger"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// primaryFailures counts the primary sender's failures in a row, across
+// every fallbackSender, so a broken mail setup is noticed however the
+// voicemails that hit it are spread out.
+var primaryFailures atomic.Int64
+
+// fallbackSender sends through primary, switching to fallback once primary
+// has failed fallbackAfter times in a row.
+type fallbackSender struct {
+	primary  Sender
+	fallback Sender
+	name     string
+}
+
+// WithFallback wraps sender according to EMAIL_FALLBACK. With "sendgrid",
+// once sender has failed EMAIL_FALLBACK_AFTER times in a row (default 3),
+// emails go out through SendGrid until sender next succeeds. Emails that
+// failed before the switch stay in the outbox, and Flush delivers them
+// through the fallback.
+func WithFallback(sender Sender) Sender {
+	switch v := strings.ToLower(os.Getenv("EMAIL_FALLBACK")); v {
+	case "", "none":
+		return sender
+	case "sendgrid":
+		return &fallbackSender{primary: sender, fallback: SendGrid{}, name: "SendGrid"}
+	default:
+		logger.Warn.Printf("⚠️ Invalid EMAIL_FALLBACK %q, not falling back", v)
+		return sender
+	}
+}
+
+func fallbackAfter() int64 {
+	if v := os.Getenv("EMAIL_FALLBACK_AFTER"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid EMAIL_FALLBACK_AFTER %q, using default", v)
+	}
+	return 3
+}
+
+func (f *fallbackSender) SendMessage(ctx context.Context, msg *gmail.Message) error {
+	err := f.primary.SendMessage(ctx, msg)
+	if err == nil {
+		if primaryFailures.Swap(0) >= fallbackAfter() {
+			logger.Info.Println("✅ Email sending recovered, no longer falling back")
+		}
+		return nil
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+
+	failures := primaryFailures.Add(1)
+	if failures < fallbackAfter() {
+		return err
+	}
+	logger.Warn.Printf("⚠️ Email send failed %d time(s) in a row, sending through %s: %v", failures, f.name, err)
+	if fbErr := f.fallback.SendMessage(ctx, msg); fbErr != nil {
+		return fmt.Errorf("%w; %s fallback also failed: %v", err, f.name, fbErr)
+	}
+	logger.Info.Printf("✉️ Sent email through %s", f.name)
+	return nil
+}
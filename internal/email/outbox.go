@@ -0,0 +1,201 @@
+package email
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const outboxCollection = "email_outbox"
+
+const (
+	outboxPending = "pending"
+	outboxSending = "sending"
+	outboxSent    = "sent"
+)
+
+// Outbox is a Sender that writes each transcription email to the
+// email_outbox collection before handing it to the wrapped Sender, and marks
+// it sent afterwards. An email whose send failed stays pending, so Flush can
+// still deliver it once the audio it was transcribed from is gone.
+//
+// Entries are keyed by the voicemail, its recordings and its recipients, so
+// sending the same transcription again is a no-op once it has gone out,
+// unless Resend is set.
+type Outbox struct {
+	Client *firestore.Client
+	Sender Sender
+	// Resend sends emails that already went out again, for a reprocessed
+	// message whose corrected transcript has to reach the recipients.
+	Resend bool
+}
+
+// NewOutbox returns an Outbox that sends through sender.
+func NewOutbox(client *firestore.Client, sender Sender) *Outbox {
+	return &Outbox{Client: client, Sender: sender}
+}
+
+// SendMessage sends msg without going through the outbox, for emails that
+// aren't transcriptions.
+func (o *Outbox) SendMessage(ctx context.Context, msg *gmail.Message) error {
+	return o.Sender.SendMessage(ctx, msg)
+}
+
+// outboxKey identifies a transcription email.
+func outboxKey(vm *voicemail.Voicemail, rcpt Recipients) string {
+	parts := []string{vm.MessageID}
+	for _, rec := range vm.Recordings {
+		parts = append(parts, rec.Filename)
+	}
+	parts = append(parts, strings.Join(rcpt.To, ","), strings.Join(rcpt.CC, ","), strings.Join(rcpt.BCC, ","))
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// send persists msg under key, sends it and marks it sent.
+func (o *Outbox) send(ctx context.Context, key, msgID string, msg *gmail.Message) error {
+	ref := o.Client.Collection(outboxCollection).Doc(key)
+	doc, err := ref.Get(ctx)
+	if err == nil && doc.Data()["status"] == outboxSent && !o.Resend {
+		logger.Info.Printf("⏭️ Transcription email for message %s already sent", msgID)
+		return nil
+	}
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to read outbox entry for message %s: %w", msgID, err)
+	}
+
+	now := time.Now()
+	_, err = ref.Set(ctx, map[string]interface{}{
+		"messageId": msgID,
+		"raw":       msg.Raw,
+		"threadId":  msg.ThreadId,
+		"status":    outboxSending,
+		"createdAt": now,
+		"updatedAt": now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write outbox entry for message %s: %w", msgID, err)
+	}
+
+	if err := o.Sender.SendMessage(ctx, msg); err != nil {
+		o.update(ctx, ref, outboxPending, err)
+		return err
+	}
+	o.update(ctx, ref, outboxSent, nil)
+	return nil
+}
+
+func (o *Outbox) update(ctx context.Context, ref *firestore.DocumentRef, state string, sendErr error) {
+	updates := []firestore.Update{
+		{Path: "status", Value: state},
+		{Path: "updatedAt", Value: time.Now()},
+	}
+	if state == outboxSent {
+		updates = append(updates, firestore.Update{Path: "raw", Value: firestore.Delete})
+	}
+	if sendErr != nil {
+		updates = append(updates, firestore.Update{Path: "lastError", Value: sendErr.Error()})
+	}
+	if _, err := ref.Update(ctx, updates); err != nil {
+		logger.Warn.Printf("⚠️ Failed to mark outbox entry %s %s: %v", ref.ID, state, err)
+	}
+}
+
+// flushAge reads OUTBOX_FLUSH_AGE, how long an entry must have been left
+// pending or sending before Flush picks it up. The delay keeps Flush away
+// from sends still in flight on another instance.
+func flushAge() time.Duration {
+	if v := os.Getenv("OUTBOX_FLUSH_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid OUTBOX_FLUSH_AGE %q, using default", v)
+	}
+	return 5 * time.Minute
+}
+
+// Flush sends every outbox entry left unsent, returning how many went out.
+// Each entry is claimed in a transaction first, so instances starting
+// together don't send it twice.
+func (o *Outbox) Flush(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-flushAge())
+	iter := o.Client.Collection(outboxCollection).
+		Where("status", "in", []string{outboxPending, outboxSending}).
+		Documents(ctx)
+	defer iter.Stop()
+
+	sent := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return sent, fmt.Errorf("failed to list outbox: %w", err)
+		}
+		if updated, ok := doc.Data()["updatedAt"].(time.Time); ok && updated.After(cutoff) {
+			continue
+		}
+
+		msg, err := o.claim(ctx, doc.Ref, cutoff)
+		if err != nil {
+			logger.Warn.Printf("⚠️ Could not claim outbox entry %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+		if err := o.Sender.SendMessage(ctx, msg); err != nil {
+			logger.Error.Printf("❌ Failed to flush outbox entry %s: %v", doc.Ref.ID, err)
+			o.update(ctx, doc.Ref, outboxPending, err)
+			continue
+		}
+		o.update(ctx, doc.Ref, outboxSent, nil)
+		logger.Info.Printf("📤 Flushed transcription email for message %v", doc.Data()["messageId"])
+		sent++
+	}
+	return sent, nil
+}
+
+// claim marks the entry as sending and returns its message, or nil if it
+// was sent or touched since cutoff in the meantime.
+func (o *Outbox) claim(ctx context.Context, ref *firestore.DocumentRef, cutoff time.Time) (*gmail.Message, error) {
+	var msg *gmail.Message
+	err := o.Client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		msg = nil
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		data := doc.Data()
+		if data["status"] == outboxSent {
+			return nil
+		}
+		if updated, ok := data["updatedAt"].(time.Time); ok && updated.After(cutoff) {
+			return nil
+		}
+		raw, _ := data["raw"].(string)
+		if raw == "" {
+			return nil
+		}
+		threadID, _ := data["threadId"].(string)
+		msg = &gmail.Message{Raw: raw, ThreadId: threadID}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: outboxSending},
+			{Path: "updatedAt", Value: time.Now()},
+		})
+	})
+	return msg, err
+}
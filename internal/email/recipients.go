@@ -0,0 +1,76 @@
+package email
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/tenant"
+)
+
+// Recipients lists the addresses a transcription email is delivered to.
+type Recipients struct {
+	To  []string `firestore:"to" json:"to"`
+	CC  []string `firestore:"cc" json:"cc"`
+	BCC []string `firestore:"bcc" json:"bcc"`
+}
+
+// DefaultRecipients reads the comma-separated EMAIL_TO, EMAIL_CC and EMAIL_BCC
+// lists, overridden by the tenant config. EMAIL_TO falls back to
+// EMAIL_RESPONSE_ADDRESS.
+func DefaultRecipients() Recipients {
+	to := ParseList(os.Getenv("EMAIL_TO"))
+	if len(to) == 0 {
+		to = ParseList(os.Getenv("EMAIL_RESPONSE_ADDRESS"))
+	}
+	rcpt := Recipients{
+		To:  to,
+		CC:  ParseList(os.Getenv("EMAIL_CC")),
+		BCC: ParseList(os.Getenv("EMAIL_BCC")),
+	}
+
+	cfg := tenant.Current()
+	return rcpt.Override(Recipients{To: cfg.To, CC: cfg.CC, BCC: cfg.BCC})
+}
+
+// Override returns r with every non-empty list in o replacing its
+// counterpart.
+func (r Recipients) Override(o Recipients) Recipients {
+	if len(o.To) > 0 {
+		r.To = o.To
+	}
+	if len(o.CC) > 0 {
+		r.CC = o.CC
+	}
+	if len(o.BCC) > 0 {
+		r.BCC = o.BCC
+	}
+	return r
+}
+
+// Validate checks that there is at least one recipient and every address
+// parses.
+func (r Recipients) Validate() error {
+	if len(r.To)+len(r.CC)+len(r.BCC) == 0 {
+		return fmt.Errorf("no recipients configured: set EMAIL_TO or EMAIL_RESPONSE_ADDRESS")
+	}
+	for _, list := range [][]string{r.To, r.CC, r.BCC} {
+		for _, addr := range list {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("invalid recipient %q: %w", addr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseList splits a comma-separated address list, dropping blanks.
+func ParseList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
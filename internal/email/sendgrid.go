@@ -0,0 +1,205 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/retry"
+	"voicemail-transcriber-production/internal/secret"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+const (
+	sendGridURL       = "https://api.sendgrid.com/v3/mail/send"
+	sendGridKeySecret = "sendgrid-api-key"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second, Transport: &requestid.Transport{}}
+
+// SendGrid is a Sender that delivers composed messages through the SendGrid
+// v3 API, using the key in the sendgrid-api-key secret. Messages are sent
+// from SENDGRID_FROM, which must be a verified sender in SendGrid.
+type SendGrid struct{}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to,omitempty"`
+	CC  []sendGridAddress `json:"cc,omitempty"`
+	BCC []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridMail struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+// sendGridHeaders are copied from the composed message so replies still
+// thread and urgent voicemails keep their priority.
+var sendGridHeaders = []string{"In-Reply-To", "References", "X-Priority", "Importance"}
+
+// SendMessage unpacks the raw RFC 2822 message Gmail would have sent into a
+// SendGrid request. Like the Gmail client, it only retries rate limit
+// rejections, since after a server error the message may have gone out.
+func (SendGrid) SendMessage(ctx context.Context, msg *gmail.Message) error {
+	from := os.Getenv("SENDGRID_FROM")
+	if from == "" {
+		return fmt.Errorf("SENDGRID_FROM must be set")
+	}
+	apiKey, err := secret.LoadSecret(ctx, sendGridKeySecret)
+	if err != nil {
+		return fmt.Errorf("failed to load SendGrid API key: %w", err)
+	}
+
+	m, err := sendGridMessage(msg)
+	if err != nil {
+		return err
+	}
+	m.From = sendGridAddress{Email: from}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode SendGrid request: %w", err)
+	}
+
+	policy := retry.FromEnv("SENDGRID", retry.Policy{Retries: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second})
+	return retry.Do(ctx, "SendGrid send", policy, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridURL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create SendGrid request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(apiKey)))
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("SendGrid request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusUnauthorized {
+			secret.Invalidate(sendGridKeySecret)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err := fmt.Errorf("SendGrid returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return retry.Temporary(err, retry.RetryAfter(resp.Header))
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// sendGridMessage parses the recipients, subject, bodies and threading
+// headers out of a message built by compose or SendText.
+func sendGridMessage(msg *gmail.Message) (*sendGridMail, error) {
+	raw, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	var p sendGridPersonalization
+	for _, h := range []struct {
+		name string
+		list *[]sendGridAddress
+	}{{"To", &p.To}, {"Cc", &p.CC}, {"Bcc", &p.BCC}} {
+		if parsed.Header.Get(h.name) == "" {
+			continue
+		}
+		addrs, err := parsed.Header.AddressList(h.name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", h.name, err)
+		}
+		for _, a := range addrs {
+			*h.list = append(*h.list, sendGridAddress{Email: a.Address, Name: a.Name})
+		}
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	m := &sendGridMail{Personalizations: []sendGridPersonalization{p}, Subject: subject}
+	for _, name := range sendGridHeaders {
+		if v := parsed.Header.Get(name); v != "" {
+			if m.Headers == nil {
+				m.Headers = map[string]string{}
+			}
+			m.Headers[name] = v
+		}
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err == nil && (mediaType == "text/plain" || mediaType == "text/html") {
+		// A single-part message from SendText.
+		var body io.Reader = parsed.Body
+		if strings.EqualFold(parsed.Header.Get("Content-Transfer-Encoding"), "quoted-printable") {
+			body = quotedprintable.NewReader(body)
+		}
+		value, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message body: %w", err)
+		}
+		m.Content = []sendGridContent{{Type: mediaType, Value: string(value)}}
+		return m, nil
+	}
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected message content type %q", parsed.Header.Get("Content-Type"))
+	}
+	// SendGrid wants the plain-text body before the HTML one, which is the
+	// order compose writes them in.
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message body: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != "text/plain" && partType != "text/html" {
+			continue
+		}
+		// NextPart has already undone the quoted-printable encoding.
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message body: %w", err)
+		}
+		m.Content = append(m.Content, sendGridContent{Type: partType, Value: string(value)})
+	}
+	if len(m.Content) == 0 {
+		return nil, fmt.Errorf("message has no text or HTML body")
+	}
+	return m, nil
+}
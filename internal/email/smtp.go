@@ -0,0 +1,198 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+const smtpPasswordSecret = "smtp-password"
+
+// Backend picks how emails are delivered from EMAIL_BACKEND: "gmail" (the
+// default) sends through the mailbox's Gmail API, and "smtp" through the
+// mail server described by the SMTP_* settings.
+func Backend(gmailSender Sender) Sender {
+	switch v := strings.ToLower(os.Getenv("EMAIL_BACKEND")); v {
+	case "", "gmail":
+		return gmailSender
+	case "smtp":
+		return SMTP{}
+	default:
+		logger.Warn.Printf("⚠️ Invalid EMAIL_BACKEND %q, using gmail", v)
+		return gmailSender
+	}
+}
+
+// SMTP is a Sender that delivers composed messages to SMTP_HOST on
+// SMTP_PORT (default 587). Port 465 connects over TLS; any other port
+// upgrades with STARTTLS when the server offers it, and insists on it unless
+// SMTP_REQUIRE_TLS=false. With SMTP_USERNAME set it authenticates with PLAIN
+// using the smtp-password secret. Messages are sent from SMTP_FROM.
+type SMTP struct{}
+
+func smtpPort() string {
+	if p := os.Getenv("SMTP_PORT"); p != "" {
+		return p
+	}
+	return "587"
+}
+
+func (SMTP) SendMessage(ctx context.Context, msg *gmail.Message) error {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return fmt.Errorf("SMTP_HOST and SMTP_FROM must be set")
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM %q: %w", from, err)
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		return fmt.Errorf("failed to decode message: %w", err)
+	}
+	data, rcpts, err := smtpMessage(raw, sender)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		password, err := secret.LoadSecret(ctx, smtpPasswordSecret)
+		if err != nil {
+			return fmt.Errorf("failed to load SMTP password: %w", err)
+		}
+		auth = smtp.PlainAuth("", user, strings.TrimSpace(string(password)), host)
+	}
+
+	if err := smtpSend(ctx, host, sender.Address, rcpts, data, auth); err != nil {
+		return fmt.Errorf("SMTP send via %s failed: %w", host, err)
+	}
+	return nil
+}
+
+// smtpMessage adds the From, Date and Message-ID headers Gmail would have
+// filled in, and drops the Bcc header, returning the message and its
+// envelope recipients.
+func smtpMessage(raw []byte, from *mail.Address) ([]byte, []string, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	var rcpts []string
+	for _, name := range []string{"To", "Cc", "Bcc"} {
+		if parsed.Header.Get(name) == "" {
+			continue
+		}
+		addrs, err := parsed.Header.AddressList(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s header: %w", name, err)
+		}
+		for _, a := range addrs {
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return nil, nil, fmt.Errorf("message has no recipients")
+	}
+
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%d.voicemail@%s>\r\n", time.Now().UnixNano(), domain)
+
+	// Copy the composed headers as they are, bar Bcc, then the body.
+	header, body, _ := bytes.Cut(raw, []byte("\r\n\r\n"))
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if strings.HasPrefix(strings.ToLower(line), "bcc:") {
+			continue
+		}
+		b.WriteString(line + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes(), rcpts, nil
+}
+
+func smtpRequireTLS() bool {
+	return !strings.EqualFold(os.Getenv("SMTP_REQUIRE_TLS"), "false")
+}
+
+func smtpSend(ctx context.Context, host, from string, rcpts []string, data []byte, auth smtp.Auth) error {
+	addr := net.JoinHostPort(host, smtpPort())
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	var conn net.Conn
+	var err error
+	if smtpPort() == "465" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support, so bound the whole exchange instead.
+	deadline := time.Now().Add(2 * time.Minute)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if _, isTLS := conn.(*tls.Conn); !isTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if smtpRequireTLS() {
+			return fmt.Errorf("server does not offer STARTTLS; set SMTP_REQUIRE_TLS=false to send in the clear")
+		}
+	}
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,245 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+const (
+	subjectTemplateFile = "subject.tmpl"
+	textTemplateFile    = "body.txt.tmpl"
+	htmlTemplateFile    = "body.html.tmpl"
+)
+
+// TemplateData is the value passed to the subject and body templates.
+type TemplateData struct {
+	*voicemail.Voicemail
+	Caller     string
+	Duration   string
+	Transcript string
+}
+
+type templateSet struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+var (
+	templates     *templateSet
+	templatesLock sync.RWMutex
+)
+
+var templateFuncs = map[string]interface{}{
+	"duration": voicemail.FormatDuration,
+	"inc":      func(i int) int { return i + 1 },
+	"join":     strings.Join,
+	"reply":    replySubject,
+}
+
+// LoadTemplates (re)loads the email templates. The embedded defaults can be
+// overridden per file from EMAIL_TEMPLATE_DIR, and then by the subject, text
+// and html fields of the Firestore document named by EMAIL_TEMPLATE_DOC
+// (default config/email_templates).
+func LoadTemplates(ctx context.Context, fsClient *firestore.Client) error {
+	sources := map[string]string{}
+	for _, name := range []string{subjectTemplateFile, textTemplateFile, htmlTemplateFile} {
+		data, err := defaultTemplates.ReadFile("templates/" + name)
+		if err != nil {
+			return fmt.Errorf("failed to read default template %s: %w", name, err)
+		}
+		sources[name] = string(data)
+	}
+
+	if dir := os.Getenv("EMAIL_TEMPLATE_DIR"); dir != "" {
+		for name := range sources {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read template %s: %w", name, err)
+			}
+			sources[name] = string(data)
+			logger.Info.Printf("📝 Loaded email template %s from %s", name, dir)
+		}
+	}
+
+	if fsClient != nil {
+		if err := loadFirestoreTemplates(ctx, fsClient, sources); err != nil {
+			return err
+		}
+	}
+
+	set, err := parseTemplates(sources)
+	if err != nil {
+		return err
+	}
+
+	templatesLock.Lock()
+	templates = set
+	templatesLock.Unlock()
+	return nil
+}
+
+// WatchTemplates reloads the templates whenever the EMAIL_TEMPLATE_DOC
+// document changes, until ctx is cancelled.
+func WatchTemplates(ctx context.Context, fsClient *firestore.Client) {
+	iter := fsClient.Doc(templateDocPath()).Snapshots(ctx)
+	defer iter.Stop()
+
+	// The first snapshot is the state LoadTemplates has already applied.
+	first := true
+	for {
+		if _, err := iter.Next(); err != nil {
+			if ctx.Err() == nil && status.Code(err) != codes.Canceled {
+				logger.Warn.Printf("⚠️ Stopped watching email templates: %v", err)
+			}
+			return
+		}
+		if first {
+			first = false
+			continue
+		}
+		if err := LoadTemplates(ctx, fsClient); err != nil {
+			logger.Error.Printf("❌ Keeping previous email templates: %v", err)
+			continue
+		}
+		logger.Info.Println("📝 Reloaded email templates")
+	}
+}
+
+func templateDocPath() string {
+	if p := os.Getenv("EMAIL_TEMPLATE_DOC"); p != "" {
+		return p
+	}
+	return "config/email_templates"
+}
+
+func loadFirestoreTemplates(ctx context.Context, fsClient *firestore.Client, sources map[string]string) error {
+	docPath := templateDocPath()
+	doc, err := fsClient.Doc(docPath).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load email templates from Firestore: %w", err)
+	}
+
+	fields := map[string]string{
+		"subject": subjectTemplateFile,
+		"text":    textTemplateFile,
+		"html":    htmlTemplateFile,
+	}
+	for field, name := range fields {
+		if v, ok := doc.Data()[field].(string); ok && strings.TrimSpace(v) != "" {
+			sources[name] = v
+			logger.Info.Printf("📝 Loaded email template %s from Firestore %s", field, docPath)
+		}
+	}
+	return nil
+}
+
+func parseTemplates(sources map[string]string) (*templateSet, error) {
+	subject, err := texttemplate.New("subject").Funcs(templateFuncs).Parse(sources[subjectTemplateFile])
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	text, err := texttemplate.New("text").Funcs(templateFuncs).Parse(sources[textTemplateFile])
+	if err != nil {
+		return nil, fmt.Errorf("invalid text template: %w", err)
+	}
+	html, err := htmltemplate.New("html").Funcs(templateFuncs).Parse(sources[htmlTemplateFile])
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTML template: %w", err)
+	}
+	return &templateSet{subject: subject, text: text, html: html}, nil
+}
+
+// currentTemplates returns the loaded templates, falling back to the embedded
+// defaults when LoadTemplates hasn't run.
+func currentTemplates() (*templateSet, error) {
+	templatesLock.RLock()
+	set := templates
+	templatesLock.RUnlock()
+	if set != nil {
+		return set, nil
+	}
+
+	if err := LoadTemplates(context.Background(), nil); err != nil {
+		return nil, err
+	}
+	templatesLock.RLock()
+	defer templatesLock.RUnlock()
+	return templates, nil
+}
+
+func newTemplateData(vm *voicemail.Voicemail) TemplateData {
+	data := TemplateData{Voicemail: vm, Caller: vm.Caller}
+	if data.Caller == "" {
+		data.Caller = "Unknown caller"
+	}
+	if vm.Contact != nil && vm.Contact.Name != "" {
+		data.Caller = vm.Contact.Name + " (" + data.Caller + ")"
+	}
+	if total := vm.TotalDuration(); total > 0 {
+		data.Duration = voicemail.FormatDuration(total)
+	}
+	data.Transcript = vm.Transcript()
+	return data
+}
+
+// render executes the subject, plain-text and HTML templates for vm.
+func render(vm *voicemail.Voicemail) (subject, text, html string, err error) {
+	set, err := currentTemplates()
+	if err != nil {
+		return "", "", "", err
+	}
+	data := newTemplateData(vm)
+
+	var b bytes.Buffer
+	if err := set.subject.Execute(&b, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render subject: %w", err)
+	}
+	subject = strings.Join(strings.Fields(b.String()), " ")
+
+	b.Reset()
+	if err := set.text.Execute(&b, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render text body: %w", err)
+	}
+	text = b.String()
+
+	b.Reset()
+	if err := set.html.Execute(&b, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render HTML body: %w", err)
+	}
+	html = b.String()
+
+	return subject, text, html, nil
+}
+
+// replySubject keeps the original subject for threaded replies, since Gmail
+// only threads a reply whose subject matches the conversation.
+func replySubject(subject string) string {
+	if strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
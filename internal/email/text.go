@@ -0,0 +1,39 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// SendText sends a plain-text email, for reports and alerts rather than
+// transcriptions.
+func SendText(ctx context.Context, sender Sender, to []string, subject, body string) error {
+	if err := (Recipients{To: to}).Validate(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	writeAddressHeader(&msg, "To", to)
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject)))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	msg.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&msg)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+
+	return sender.SendMessage(ctx, &gmail.Message{Raw: base64.URLEncoding.EncodeToString(msg.Bytes())})
+}
@@ -0,0 +1,105 @@
+// Package errorreport sends processing failures to Cloud Error Reporting so
+// they are grouped, counted and shown with a stack trace.
+package errorreport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+
+	"google.golang.org/api/clouderrorreporting/v1beta1"
+)
+
+const defaultService = "voicemail-transcriber"
+
+var (
+	once   sync.Once
+	client *clouderrorreporting.Service
+)
+
+// Enabled reports whether errors are sent to Error Reporting: it needs
+// GCP_PROJECT_ID and can be turned off with ERROR_REPORTING=false.
+func Enabled() bool {
+	return os.Getenv("GCP_PROJECT_ID") != "" && !strings.EqualFold(os.Getenv("ERROR_REPORTING"), "false")
+}
+
+func service() *clouderrorreporting.Service {
+	once.Do(func() {
+		srv, err := clouderrorreporting.NewService(context.Background())
+		if err != nil {
+			logger.Warn.Printf("⚠️ Error Reporting unavailable: %v", err)
+			return
+		}
+		client = srv
+	})
+	return client
+}
+
+// serviceContext names the service and version errors are grouped under,
+// from K_SERVICE (set by Cloud Run) and BUILD_VERSION.
+func serviceContext() *clouderrorreporting.ServiceContext {
+	name := os.Getenv("K_SERVICE")
+	if name == "" {
+		name = defaultService
+	}
+	return &clouderrorreporting.ServiceContext{
+		Service: name,
+		Version: os.Getenv("BUILD_VERSION"),
+	}
+}
+
+// Report sends err to Error Reporting in the background, with the caller's
+// stack trace. It never blocks the pipeline and is a no-op when disabled.
+func Report(ctx context.Context, err error) {
+	if err == nil || !Enabled() {
+		return
+	}
+
+	msg := err.Error()
+	if id := requestid.FromContext(ctx); id != "" {
+		msg = fmt.Sprintf("%s (request %s)", msg, id)
+	}
+	event := &clouderrorreporting.ReportedErrorEvent{
+		EventTime:      time.Now().UTC().Format(time.RFC3339Nano),
+		Message:        msg + "\n" + stack(),
+		ServiceContext: serviceContext(),
+	}
+
+	go func() {
+		srv := service()
+		if srv == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+
+		project := "projects/" + os.Getenv("GCP_PROJECT_ID")
+		if _, err := srv.Projects.Events.Report(project, event).Context(ctx).Do(); err != nil {
+			logger.Warn.Printf("⚠️ Failed to report error: %v", err)
+		}
+	}()
+}
+
+// stack returns the current goroutine's trace in the format Error Reporting
+// parses, starting at Report's caller.
+func stack() string {
+	buf := make([]byte, 16<<10)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) == 0 {
+		return ""
+	}
+	// lines[0] is the goroutine header, followed by function/location pairs.
+	frames := lines[1:]
+	for len(frames) >= 2 && strings.Contains(frames[0], "internal/errorreport.") {
+		frames = frames[2:]
+	}
+	return lines[0] + "\n" + strings.Join(frames, "\n")
+}
@@ -0,0 +1,77 @@
+package gmail
+
+import (
+	"context"
+	"voicemail-transcriber-production/internal/llm"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/transcriber"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// analyze annotates a transcribed voicemail before it is delivered. Optional
+// analyses that fail are logged and left out rather than holding up the
+// transcription.
+func analyze(ctx context.Context, vm *voicemail.Voicemail) {
+	flagUrgency(ctx, vm)
+
+	if llm.ActionItemsEnabled() {
+		items, err := llm.ActionItems(ctx, vm.Transcript())
+		if err != nil {
+			logger.Warn.Printf("%s⚠️ Could not extract action items for message %s: %v", requestid.Prefix(ctx), vm.MessageID, err)
+		} else {
+			vm.ActionItems = items
+		}
+	}
+
+	scoreSentiment(ctx, vm)
+}
+
+// scoreSentiment rates vm with the SENTIMENT_PROVIDER.
+func scoreSentiment(ctx context.Context, vm *voicemail.Voicemail) {
+	switch transcriber.SentimentProvider() {
+	case "deepgram":
+		vm.Sentiment, vm.SentimentScore, _ = vm.RecordingSentiment()
+	case "llm":
+		label, score, err := llm.Sentiment(ctx, vm.Transcript())
+		if err != nil {
+			logger.Warn.Printf("%s⚠️ Could not score sentiment for message %s: %v", requestid.Prefix(ctx), vm.MessageID, err)
+			return
+		}
+		vm.Sentiment, vm.SentimentScore = label, score
+	default:
+		return
+	}
+	if vm.Negative() {
+		logger.Info.Printf("%s😠 Message %s is negative (score %.2f)", requestid.Prefix(ctx), vm.MessageID, vm.SentimentScore)
+	}
+}
+
+// mergeSentiment keeps the more negative of dst's and src's sentiment, so a
+// message is rated by its unhappiest recording.
+func mergeSentiment(dst, src *voicemail.Voicemail) {
+	if src.Sentiment != "" && (dst.Sentiment == "" || src.SentimentScore < dst.SentimentScore) {
+		dst.Sentiment, dst.SentimentScore = src.Sentiment, src.SentimentScore
+	}
+}
+
+// negativeLabel reads NEGATIVE_LABEL, the label applied to voicemails scored
+// as negative so managers can find unhappy callers. "none" disables it.
+func negativeLabel() string {
+	return labelSetting("NEGATIVE_LABEL", "Voicemail-Negative")
+}
+
+// markNegative applies the negative sentiment label to the message.
+func markNegative(ctx context.Context, client GmailClient, msgID string) {
+	id := labelID(ctx, client, negativeLabel())
+	if id == "" {
+		return
+	}
+	if err := client.ModifyMessage(ctx, msgID, &gmail.ModifyMessageRequest{AddLabelIds: []string{id}}); err != nil {
+		logger.Error.Printf("Failed to label email %s as negative: %v", msgID, err)
+		return
+	}
+	logger.Info.Printf("🏷️ Labelled email %s as negative.", msgID)
+}
@@ -0,0 +1,198 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/audit"
+	"voicemail-transcriber-production/internal/dedup"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/errorreport"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// bounceQuery finds delivery status notifications in the mailbox. Bounces
+// of transcription emails sent through Gmail come back here, as do those of
+// SMTP or SendGrid sends when their from address is this mailbox.
+const bounceQuery = "from:(mailer-daemon OR postmaster) newer_than:2d"
+
+// BounceInterval reads BOUNCE_CHECK_INTERVAL, how often the mailbox is
+// checked for bounced transcription emails. "0" turns the check off.
+func BounceInterval() time.Duration {
+	if v := os.Getenv("BOUNCE_CHECK_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid BOUNCE_CHECK_INTERVAL %q, using default", v)
+	}
+	return 15 * time.Minute
+}
+
+// bounce is what CheckBounces learns from one delivery status notification.
+type bounce struct {
+	MessageID  string
+	Recipients []string
+	Reason     string
+}
+
+var (
+	finalRecipientPattern = regexp.MustCompile(`(?im)^(?:final|original)-recipient:\s*rfc822;\s*<?([^\s>]+)>?`)
+	diagnosticPattern     = regexp.MustCompile(`(?im)^diagnostic-code:\s*(?:smtp;\s*)?(.+)$`)
+	voicemailIDPattern    = regexp.MustCompile(`(?im)^` + regexp.QuoteMeta(email.MessageIDHeader) + `:\s*(\S+)`)
+)
+
+// CheckBounces looks through recent delivery status notifications for
+// bounced transcription emails, records each failure on the transcript it
+// was sent for and reports an error when a configured recipient bounces.
+// It returns how many new bounces it found.
+func (h *Handler) CheckBounces(ctx context.Context) (int, error) {
+	var ids []string
+	err := h.Gmail.ListMessages(ctx, bounceQuery, nil, pollBatchSize, func(resp *gmail.ListMessagesResponse) error {
+		for _, m := range resp.Messages {
+			ids = append(ids, m.Id)
+		}
+		return errStopPaging
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return 0, fmt.Errorf("failed to list bounces: %w", err)
+	}
+
+	found := 0
+	for _, id := range ids {
+		// Claimed under their own key, as the notification pipeline has
+		// usually claimed and skipped these messages already.
+		claimed, err := h.Dedup.Claim(ctx, "bounce-"+id)
+		if errors.Is(err, dedup.ErrLeased) {
+			continue
+		}
+		if err != nil {
+			return found, err
+		}
+		if !claimed {
+			continue
+		}
+
+		msg, err := h.Gmail.GetMessage(ctx, id, "full")
+		if err != nil {
+			h.releaseBounce(ctx, id)
+			return found, fmt.Errorf("failed to retrieve bounce %s: %w", id, err)
+		}
+		b := parseBounce(msg)
+		if b.MessageID == "" {
+			h.complete(ctx, "bounce-"+id)
+			continue
+		}
+		if err := h.recordBounce(ctx, id, b); err != nil {
+			h.releaseBounce(ctx, id)
+			return found, err
+		}
+		h.complete(ctx, "bounce-"+id)
+		found++
+	}
+	return found, nil
+}
+
+func (h *Handler) releaseBounce(ctx context.Context, id string) {
+	if err := h.Dedup.Release(context.WithoutCancel(ctx), "bounce-"+id); err != nil {
+		logger.Error.Printf("❌ %v", err)
+	}
+}
+
+func (h *Handler) recordBounce(ctx context.Context, bounceID string, b bounce) error {
+	configured := map[string]bool{}
+	rcpt := email.DefaultRecipients()
+	for _, list := range [][]string{rcpt.To, rcpt.CC, rcpt.BCC} {
+		for _, addr := range list {
+			if parsed, err := mail.ParseAddress(addr); err == nil {
+				configured[strings.ToLower(parsed.Address)] = true
+			}
+		}
+	}
+
+	for _, recipient := range b.Recipients {
+		logger.Warn.Printf("📭 Transcription email for message %s bounced for %s: %s", b.MessageID, recipient, b.Reason)
+		audit.Record(ctx, h.Firestore, b.MessageID, audit.EventBounced, recipient+": "+b.Reason)
+		err := transcripts.AddDeliveryFailure(ctx, h.Firestore, b.MessageID, transcripts.DeliveryFailure{
+			Recipient: recipient,
+			Reason:    b.Reason,
+			BounceID:  bounceID,
+			At:        time.Now(),
+		})
+		if errors.Is(err, transcripts.ErrNotFound) {
+			logger.Warn.Printf("⚠️ No transcript %s to record the bounce on", b.MessageID)
+		} else if err != nil {
+			return err
+		}
+
+		if configured[strings.ToLower(recipient)] {
+			errorreport.Report(ctx, fmt.Errorf("transcription emails to %s are bouncing: %s", recipient, b.Reason))
+		}
+	}
+	return nil
+}
+
+// parseBounce reads the voicemail a bounce is about from the quoted
+// headers of the original email, and the failed recipients and reason from
+// the delivery status report. Gmail's own bounces also name the recipients
+// in X-Failed-Recipients.
+func parseBounce(msg *gmail.Message) bounce {
+	var b bounce
+	if msg.Payload == nil {
+		return b
+	}
+
+	b.MessageID = quotedHeader(msg.Payload, email.MessageIDHeader)
+	if b.MessageID == "" {
+		for _, mimeType := range []string{"text/rfc822-headers", "message/rfc822", "text/plain"} {
+			if m := voicemailIDPattern.FindStringSubmatch(findBody(msg.Payload, mimeType)); m != nil {
+				b.MessageID = m[1]
+				break
+			}
+		}
+	}
+
+	report := findBody(msg.Payload, "message/delivery-status")
+	seen := map[string]bool{}
+	add := func(addr string) {
+		addr = strings.TrimSpace(addr)
+		if addr != "" && !seen[strings.ToLower(addr)] {
+			seen[strings.ToLower(addr)] = true
+			b.Recipients = append(b.Recipients, addr)
+		}
+	}
+	for _, addr := range strings.Split(GetHeader(msg.Payload.Headers, "X-Failed-Recipients"), ",") {
+		add(addr)
+	}
+	for _, m := range finalRecipientPattern.FindAllStringSubmatch(report, -1) {
+		add(m[1])
+	}
+
+	if m := diagnosticPattern.FindStringSubmatch(report); m != nil {
+		b.Reason = strings.TrimSpace(m[1])
+	} else {
+		b.Reason = GetHeader(msg.Payload.Headers, "Subject")
+	}
+	return b
+}
+
+// quotedHeader finds header name on any part nested in part, where Gmail
+// places the headers of a message/rfc822 attachment.
+func quotedHeader(part *gmail.MessagePart, name string) string {
+	for _, child := range part.Parts {
+		if v := GetHeader(child.Headers, name); v != "" {
+			return v
+		}
+		if v := quotedHeader(child, name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package gmail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const audioChecksumCollection = "audio_checksums"
+
+var errDuplicateAudio = errors.New("duplicate audio")
+
+// duplicateAudioWindow reads DUPLICATE_AUDIO_WINDOW_DAYS, the period during
+// which a redelivered recording with the same checksum is skipped.
+func duplicateAudioWindow() time.Duration {
+	days := 7
+	if v := os.Getenv("DUPLICATE_AUDIO_WINDOW_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			days = n
+		} else {
+			logger.Warn.Printf("⚠️ Invalid DUPLICATE_AUDIO_WINDOW_DAYS %q, using %d", v, days)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func AudioChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// isDuplicateAudio reports whether audio with the given checksum was
+// transcribed within the duplicate window.
+func isDuplicateAudio(ctx context.Context, client *firestore.Client, checksum string) (bool, error) {
+	window := duplicateAudioWindow()
+	if window == 0 {
+		return false, nil
+	}
+
+	doc, err := client.Collection(audioChecksumCollection).Doc(checksum).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load audio checksum: %w", err)
+	}
+
+	processedAt, err := doc.DataAt("processedAt")
+	if err != nil {
+		return false, nil
+	}
+	t, ok := processedAt.(time.Time)
+	if !ok {
+		return false, nil
+	}
+	return time.Since(t) < window, nil
+}
+
+// recordAudioTranscribed stores the checksum of a recording that was sent for
+// transcription.
+func recordAudioTranscribed(ctx context.Context, client *firestore.Client, checksum, msgID, filename string) error {
+	_, err := client.Collection(audioChecksumCollection).Doc(checksum).Set(ctx, map[string]interface{}{
+		"messageId":   msgID,
+		"filename":    filename,
+		"processedAt": time.Now(),
+		"decision":    "transcribed",
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to record audio checksum: %w", err)
+	}
+	return nil
+}
+
+// recordAudioSkipped notes that msgID carried a recording that had already
+// been transcribed.
+func recordAudioSkipped(ctx context.Context, client *firestore.Client, checksum, msgID string) error {
+	_, err := client.Collection(audioChecksumCollection).Doc(checksum).Update(ctx, []firestore.Update{
+		{Path: "decision", Value: "skipped_duplicate"},
+		{Path: "lastSkippedAt", Value: time.Now()},
+		{Path: "skippedMessageIds", Value: firestore.ArrayUnion(msgID)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record duplicate audio: %w", err)
+	}
+	return nil
+}
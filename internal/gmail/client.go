@@ -0,0 +1,210 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/retry"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// GmailClient is the part of the Gmail API the pipeline uses, always acting
+// as the authenticated mailbox ("me"). NewClient adapts a *gmail.Service;
+// tests can substitute a fake.
+type GmailClient interface {
+	email.Sender
+
+	GetProfile(ctx context.Context) (*gmail.Profile, error)
+	// GetMessage fetches a message in the given format ("full", "metadata"
+	// or "" for the API default).
+	GetMessage(ctx context.Context, msgID, format string) (*gmail.Message, error)
+	GetAttachment(ctx context.Context, msgID, attachmentID string) (*gmail.MessagePartBody, error)
+	ModifyMessage(ctx context.Context, msgID string, req *gmail.ModifyMessageRequest) error
+	// ListMessages calls fn for each page of messages matching query and
+	// carrying all of labelIDs, until fn returns an error.
+	ListMessages(ctx context.Context, query string, labelIDs []string, pageSize int64, fn func(*gmail.ListMessagesResponse) error) error
+	// ListHistory calls fn for each page of messageAdded history after
+	// startHistoryID, optionally limited to labelID, until fn returns an
+	// error.
+	ListHistory(ctx context.Context, startHistoryID uint64, labelID string, fn func(*gmail.ListHistoryResponse) error) error
+	ListLabels(ctx context.Context) ([]*gmail.Label, error)
+	CreateLabel(ctx context.Context, label *gmail.Label) (*gmail.Label, error)
+	Watch(ctx context.Context, req *gmail.WatchRequest) (*gmail.WatchResponse, error)
+	// StopWatch stops push notifications for the mailbox.
+	StopWatch(ctx context.Context) error
+}
+
+// NewClient returns a GmailClient backed by srv. Calls that hit Gmail's rate
+// limits or fail transiently are retried with backoff.
+func NewClient(srv *gmail.Service) GmailClient {
+	return &serviceClient{srv: srv}
+}
+
+type serviceClient struct {
+	srv *gmail.Service
+}
+
+// retryPolicy reads GMAIL_MAX_RETRIES, GMAIL_RETRY_BASE_DELAY and
+// GMAIL_RETRY_MAX_DELAY.
+func retryPolicy() retry.Policy {
+	return retry.FromEnv("GMAIL", retry.Policy{Retries: 4, BaseDelay: time.Second, MaxDelay: 16 * time.Second})
+}
+
+// rateLimitReasons are the 403 reasons Gmail uses for quota errors, which
+// unlike other 403s succeed when retried later.
+var rateLimitReasons = map[string]bool{
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+}
+
+// classify marks err retryable when Gmail rate limited the call, or, for
+// idempotent calls, when it failed with a server or network error.
+func classify(ctx context.Context, err error, idempotent bool) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		if idempotent {
+			return retry.Temporary(err, 0)
+		}
+		return err
+	}
+
+	after := retry.RetryAfter(apiErr.Header)
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		return retry.Temporary(err, after)
+	case apiErr.Code == http.StatusForbidden:
+		for _, e := range apiErr.Errors {
+			if rateLimitReasons[e.Reason] {
+				return retry.Temporary(err, after)
+			}
+		}
+	case apiErr.Code >= 500 && idempotent:
+		return retry.Temporary(err, after)
+	}
+	return err
+}
+
+// do runs an idempotent call with retries.
+func do[T any](ctx context.Context, name string, call func(...googleapi.CallOption) (T, error)) (T, error) {
+	var result T
+	err := retry.Do(ctx, "Gmail "+name, retryPolicy(), func(ctx context.Context) error {
+		var err error
+		result, err = call()
+		return classify(ctx, err, true)
+	})
+	return result, err
+}
+
+func (c *serviceClient) GetProfile(ctx context.Context) (*gmail.Profile, error) {
+	return do(ctx, "get profile", c.srv.Users.GetProfile("me").Context(ctx).Do)
+}
+
+func (c *serviceClient) GetMessage(ctx context.Context, msgID, format string) (*gmail.Message, error) {
+	call := c.srv.Users.Messages.Get("me", msgID)
+	if format != "" {
+		call = call.Format(format)
+	}
+	return do(ctx, "get message", call.Context(ctx).Do)
+}
+
+func (c *serviceClient) GetAttachment(ctx context.Context, msgID, attachmentID string) (*gmail.MessagePartBody, error) {
+	return do(ctx, "get attachment", c.srv.Users.Messages.Attachments.Get("me", msgID, attachmentID).Context(ctx).Do)
+}
+
+func (c *serviceClient) ModifyMessage(ctx context.Context, msgID string, req *gmail.ModifyMessageRequest) error {
+	_, err := do(ctx, "modify message", c.srv.Users.Messages.Modify("me", msgID, req).Context(ctx).Do)
+	return err
+}
+
+// SendMessage only retries rate limit rejections: after a server error the
+// message may already have gone out.
+func (c *serviceClient) SendMessage(ctx context.Context, msg *gmail.Message) error {
+	return retry.Do(ctx, "Gmail send", retryPolicy(), func(ctx context.Context) error {
+		_, err := c.srv.Users.Messages.Send("me", msg).Context(ctx).Do()
+		return classify(ctx, err, false)
+	})
+}
+
+// ListMessages pages by hand rather than with Pages, so a retry repeats
+// only the failed page and fn never sees a page twice.
+func (c *serviceClient) ListMessages(ctx context.Context, query string, labelIDs []string, pageSize int64, fn func(*gmail.ListMessagesResponse) error) error {
+	call := c.srv.Users.Messages.List("me").LabelIds(labelIDs...)
+	if query != "" {
+		call = call.Q(query)
+	}
+	if pageSize > 0 {
+		call = call.MaxResults(pageSize)
+	}
+	for {
+		resp, err := do(ctx, "list messages", call.Context(ctx).Do)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp); err != nil {
+			return err
+		}
+		if resp.NextPageToken == "" {
+			return nil
+		}
+		call = call.PageToken(resp.NextPageToken)
+	}
+}
+
+func (c *serviceClient) ListHistory(ctx context.Context, startHistoryID uint64, labelID string, fn func(*gmail.ListHistoryResponse) error) error {
+	call := c.srv.Users.History.List("me").
+		StartHistoryId(startHistoryID).
+		HistoryTypes("messageAdded")
+	if labelID != "" {
+		call = call.LabelId(labelID)
+	}
+	for {
+		resp, err := do(ctx, "list history", call.Context(ctx).Do)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp); err != nil {
+			return err
+		}
+		if resp.NextPageToken == "" {
+			return nil
+		}
+		call = call.PageToken(resp.NextPageToken)
+	}
+}
+
+func (c *serviceClient) ListLabels(ctx context.Context) ([]*gmail.Label, error) {
+	resp, err := do(ctx, "list labels", c.srv.Users.Labels.List("me").Context(ctx).Do)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Labels, nil
+}
+
+// CreateLabel isn't retried on server errors, as the label may have been
+// created; EnsureLabel finds it on the next call.
+func (c *serviceClient) CreateLabel(ctx context.Context, label *gmail.Label) (*gmail.Label, error) {
+	var created *gmail.Label
+	err := retry.Do(ctx, "Gmail create label", retryPolicy(), func(ctx context.Context) error {
+		var err error
+		created, err = c.srv.Users.Labels.Create("me", label).Context(ctx).Do()
+		return classify(ctx, err, false)
+	})
+	return created, err
+}
+
+func (c *serviceClient) Watch(ctx context.Context, req *gmail.WatchRequest) (*gmail.WatchResponse, error) {
+	return do(ctx, "watch", c.srv.Users.Watch("me", req).Context(ctx).Do)
+}
+
+func (c *serviceClient) StopWatch(ctx context.Context) error {
+	return retry.Do(ctx, "Gmail stop watch", retryPolicy(), func(ctx context.Context) error {
+		return classify(ctx, c.srv.Users.Stop("me").Context(ctx).Do(), true)
+	})
+}
@@ -0,0 +1,193 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	deadLetterCollection = "dead_letters"
+	attemptsCollection   = "notification_attempts"
+
+	DeadLetterPending  = "pending"
+	DeadLetterReplayed = "replayed"
+)
+
+// DeadLetter records a notification whose processing failed on every retry.
+type DeadLetter struct {
+	ID              string    `firestore:"-" json:"id"`
+	PubSubMessageID string    `firestore:"pubsubMessageId" json:"pubsubMessageId"`
+	EmailAddress    string    `firestore:"emailAddress" json:"emailAddress"`
+	HistoryID       uint64    `firestore:"historyId" json:"historyId"`
+	StartHistoryID  uint64    `firestore:"startHistoryId" json:"startHistoryId"`
+	Error           string    `firestore:"error" json:"error"`
+	MessageIDs      []string  `firestore:"messageIds" json:"messageIds"`
+	Attempts        int       `firestore:"attempts" json:"attempts"`
+	Status          string    `firestore:"status" json:"status"`
+	CreatedAt       time.Time `firestore:"createdAt" json:"createdAt"`
+	ReplayedAt      time.Time `firestore:"replayedAt,omitempty" json:"replayedAt,omitempty"`
+	ReplayError     string    `firestore:"replayError,omitempty" json:"replayError,omitempty"`
+}
+
+// HistoryError is returned by retrieveHistory and carries the IDs of the
+// messages seen before the failure.
+type HistoryError struct {
+	MessageIDs []string
+	Err        error
+}
+
+func (e *HistoryError) Error() string { return e.Err.Error() }
+func (e *HistoryError) Unwrap() error { return e.Err }
+
+// maxNotificationAttempts reads MAX_NOTIFICATION_ATTEMPTS, the number of
+// failed deliveries after which a notification is dead-lettered.
+func maxNotificationAttempts() int {
+	if v := os.Getenv("MAX_NOTIFICATION_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid MAX_NOTIFICATION_ATTEMPTS %q, using default", v)
+	}
+	return 5
+}
+
+// recordFailedAttempt counts a failed delivery of a Pub/Sub message and
+// returns the total so far. Pub/Sub's own deliveryAttempt is used when the
+// subscription provides it.
+func recordFailedAttempt(ctx context.Context, client *firestore.Client, pubsubMessageID string, deliveryAttempt int) (int, error) {
+	if deliveryAttempt > 0 {
+		return deliveryAttempt, nil
+	}
+	if pubsubMessageID == "" {
+		return 1, nil
+	}
+
+	ref := client.Collection(attemptsCollection).Doc(pubsubMessageID)
+	_, err := ref.Set(ctx, map[string]interface{}{
+		"attempts":  firestore.Increment(1),
+		"updatedAt": time.Now(),
+	}, firestore.MergeAll)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record notification attempt: %w", err)
+	}
+
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read notification attempts: %w", err)
+	}
+	attempts, _ := doc.DataAt("attempts")
+	n, _ := attempts.(int64)
+	return int(n), nil
+}
+
+func saveDeadLetter(ctx context.Context, client *firestore.Client, dl *DeadLetter) error {
+	dl.Status = DeadLetterPending
+	dl.CreatedAt = time.Now()
+
+	ref := client.Collection(deadLetterCollection).NewDoc()
+	if dl.PubSubMessageID != "" {
+		ref = client.Collection(deadLetterCollection).Doc(dl.PubSubMessageID)
+	}
+	if _, err := ref.Set(ctx, dl); err != nil {
+		return fmt.Errorf("failed to save dead letter: %w", err)
+	}
+	dl.ID = ref.ID
+
+	logger.Error.Printf("☠️ Dead-lettered notification %s (history %d) after %d attempts: %s",
+		dl.ID, dl.HistoryID, dl.Attempts, dl.Error)
+	return nil
+}
+
+// ListDeadLetters returns dead letters with the given status, oldest first.
+func ListDeadLetters(ctx context.Context, client *firestore.Client, status string) ([]DeadLetter, error) {
+	iter := client.Collection(deadLetterCollection).
+		Where("status", "==", status).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var letters []DeadLetter
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dead letters: %w", err)
+		}
+		var dl DeadLetter
+		if err := doc.DataTo(&dl); err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter %s: %w", doc.Ref.ID, err)
+		}
+		dl.ID = doc.Ref.ID
+		letters = append(letters, dl)
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetter re-runs history retrieval from the point the dead-lettered
+// notification started at and records the outcome.
+func (h *Handler) ReplayDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	logger.Info.Printf("🔁 Replaying dead letter %s from history %d", dl.ID, dl.StartHistoryID)
+
+	replayErr := h.retrieveHistory(ctx, dl.StartHistoryID)
+
+	update := []firestore.Update{{Path: "replayedAt", Value: time.Now()}}
+	if replayErr != nil {
+		update = append(update, firestore.Update{Path: "replayError", Value: replayErr.Error()})
+	} else {
+		update = append(update,
+			firestore.Update{Path: "status", Value: DeadLetterReplayed},
+			firestore.Update{Path: "replayError", Value: firestore.Delete},
+		)
+	}
+	if _, err := h.Firestore.Collection(deadLetterCollection).Doc(dl.ID).Update(ctx, update); err != nil {
+		return errors.Join(replayErr, fmt.Errorf("failed to update dead letter: %w", err))
+	}
+	return replayErr
+}
+
+// DeadLetterHandler lists pending dead letters on GET and replays them on
+// POST. An id query parameter limits a replay to a single dead letter.
+func (h *Handler) DeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	letters, err := ListDeadLetters(ctx, h.Firestore, DeadLetterPending)
+	if err != nil {
+		logger.Error.Printf("❌ %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"deadLetters": letters})
+	case http.MethodPost:
+		id := r.URL.Query().Get("id")
+		results := map[string]string{}
+		for i := range letters {
+			if id != "" && letters[i].ID != id {
+				continue
+			}
+			if err := h.ReplayDeadLetter(ctx, &letters[i]); err != nil {
+				results[letters[i].ID] = err.Error()
+				continue
+			}
+			results[letters[i].ID] = DeadLetterReplayed
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
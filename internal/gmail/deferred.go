@@ -0,0 +1,85 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"voicemail-transcriber-production/internal/audit"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/transcriber"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const deferredCollection = "deferred_messages"
+
+// errDeferred is returned by processMessage when the message was queued
+// because the transcription provider is unavailable.
+var errDeferred = errors.New("transcription deferred")
+
+// deferMessage queues msgID to be processed once transcription is available
+// again, and releases its claim so the retry isn't skipped as a duplicate.
+func (h *Handler) deferMessage(ctx context.Context, msgID string, reason error) error {
+	_, err := h.Firestore.Collection(deferredCollection).Doc(msgID).Set(ctx, map[string]interface{}{
+		"messageId":  msgID,
+		"reason":     reason.Error(),
+		"deferredAt": time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to defer message %s: %w", msgID, err)
+	}
+	if err := h.Dedup.Release(ctx, msgID); err != nil {
+		logger.Warn.Printf("%s⚠️ %v", requestid.Prefix(ctx), err)
+	}
+	logger.Warn.Printf("%s⏸️ Deferred message %s until transcription is available: %v", requestid.Prefix(ctx), msgID, reason)
+	audit.Record(ctx, h.Firestore, msgID, audit.EventDeferred, reason.Error())
+	return nil
+}
+
+// RetryDeferred processes messages queued while transcription was
+// unavailable, oldest first. It does nothing while the provider's circuit
+// breaker is still open, and returns the number of messages retried.
+func (h *Handler) RetryDeferred(ctx context.Context) (int, error) {
+	if !transcriber.Available() {
+		return 0, nil
+	}
+
+	iter := h.Firestore.Collection(deferredCollection).OrderBy("deferredAt", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	var ids []string
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to list deferred messages: %w", err)
+		}
+		ids = append(ids, doc.Ref.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	labelIDs, err := ResolveLabelIDs(ctx, h.Gmail, ConfiguredLabels())
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve labels: %w", err)
+	}
+
+	logger.Info.Printf("▶️ Retrying %d deferred message(s)", len(ids))
+	for i, id := range ids {
+		// A message that still can't be transcribed is deferred again.
+		if _, err := h.Firestore.Collection(deferredCollection).Doc(id).Delete(ctx); err != nil {
+			return i, fmt.Errorf("failed to dequeue deferred message %s: %w", id, err)
+		}
+		h.handleMessage(ctx, id, labelIDs)
+		if !transcriber.Available() {
+			return i + 1, nil
+		}
+	}
+	return len(ids), nil
+}
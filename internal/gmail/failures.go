@@ -0,0 +1,98 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/notify"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+)
+
+// consecutiveFailures counts messages in a row this instance failed to
+// process.
+var consecutiveFailures atomic.Int64
+
+// alertThreshold reads ALERT_FAILURE_THRESHOLD, how many messages in a row
+// must fail before the ops alert goes out (default 3).
+func alertThreshold() int64 {
+	if v := os.Getenv("ALERT_FAILURE_THRESHOLD"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid ALERT_FAILURE_THRESHOLD %q, using default", v)
+	}
+	return 3
+}
+
+// recordOutcome tracks whether a message was processed, alerting ops once
+// when ALERT_FAILURE_THRESHOLD messages have failed in a row and again when
+// one next succeeds.
+func (h *Handler) recordOutcome(ctx context.Context, msgID string, procErr error) {
+	threshold := alertThreshold()
+	if errors.Is(procErr, errAlreadyDone) {
+		return
+	}
+	if procErr == nil || errors.Is(procErr, errNoAudio) || errors.Is(procErr, errDeferred) {
+		fields := map[string]interface{}{"errorStreak": 0}
+		if procErr == nil {
+			fields["lastTranscribedAt"] = time.Now()
+		}
+		h.recordStatus(ctx, fields)
+		if consecutiveFailures.Swap(0) >= threshold {
+			h.alert(ctx, "Voicemail processing recovered",
+				fmt.Sprintf("Message %s was processed successfully after a run of failures.", msgID))
+		}
+		return
+	}
+
+	h.recordStatus(ctx, map[string]interface{}{
+		"lastFailureAt": time.Now(),
+		"lastError":     procErr.Error(),
+		"errorStreak":   firestore.Increment(1),
+	})
+	if consecutiveFailures.Add(1) == threshold {
+		logger.Error.Printf("%s🚨 %d messages in a row have failed to process", requestid.Prefix(ctx), threshold)
+		h.alert(ctx, fmt.Sprintf("Voicemail processing failing: %d messages in a row", threshold),
+			fmt.Sprintf("The last %d voicemail messages could not be processed.\n\nLatest failure (message %s):\n%v", threshold, msgID, procErr))
+	}
+}
+
+// alertUntranscribed tells ops which recordings on vm couldn't be
+// transcribed, with a link to the message so someone can listen to them.
+// It reports whether the alert went out.
+func (h *Handler) alertUntranscribed(ctx context.Context, vm *voicemail.Voicemail, failures []string) bool {
+	var b strings.Builder
+	fmt.Fprintf(&b, "A voicemail could not be transcribed and needs listening to by hand.\n\n")
+	fmt.Fprintf(&b, "Caller: %s\n", vm.Caller)
+	if !vm.ReceivedAt.IsZero() {
+		fmt.Fprintf(&b, "Received: %s\n", vm.ReceivedAt.Format("Mon 2 Jan 2006, 15:04"))
+	}
+	fmt.Fprintf(&b, "Subject: %s\n", vm.Subject)
+	fmt.Fprintf(&b, "Open in Gmail: %s\n\n", vm.GmailLink())
+	b.WriteString("Errors:\n- " + strings.Join(failures, "\n- ") + "\n")
+
+	return h.alert(ctx, "Voicemail from "+callerOrUnknown(vm.Caller)+" could not be transcribed", b.String())
+}
+
+// alert sends an ops alert, reporting whether it went out.
+func (h *Handler) alert(ctx context.Context, subject, text string) bool {
+	if !notify.AlertsEnabled() {
+		return false
+	}
+	sender := email.WithFallback(email.Backend(h.Gmail))
+	if err := notify.Alert(context.WithoutCancel(ctx), sender, subject, text); err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+		return false
+	}
+	return true
+}
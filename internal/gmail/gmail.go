@@ -3,17 +3,24 @@ package gmail
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"html"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"voicemail-transcriber-production/internal/logger"
 )
 
-func SaveAttachment(srv *gmail.Service, user, msgID string, part *gmail.MessagePart, downloadDir string) (string, error) {
-	att, err := srv.Users.Messages.Attachments.Get(user, msgID, part.Body.AttachmentId).Do()
+func SaveAttachment(ctx context.Context, client GmailClient, msgID string, part *gmail.MessagePart, downloadDir string) (string, error) {
+	att, err := client.GetAttachment(ctx, msgID, part.Body.AttachmentId)
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve attachment: %w", err)
 	}
@@ -23,7 +30,9 @@ func SaveAttachment(srv *gmail.Service, user, msgID string, part *gmail.MessageP
 		return "", fmt.Errorf("failed to decode attachment: %w", err)
 	}
 
-	filePath := filepath.Join(downloadDir, part.Filename)
+	// Messages are processed concurrently and carriers reuse filenames, so
+	// the path is made unique to the message part.
+	filePath := filepath.Join(downloadDir, fmt.Sprintf("%s-%s-%s", msgID, part.PartId, filepath.Base(part.Filename)))
 	err = os.WriteFile(filePath, data, 0644)
 	if err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
@@ -33,10 +42,10 @@ func SaveAttachment(srv *gmail.Service, user, msgID string, part *gmail.MessageP
 	return filePath, nil
 }
 
-func MarkAsRead(srv *gmail.Service, user, msgID string) {
-	_, err := srv.Users.Messages.Modify(user, msgID, &gmail.ModifyMessageRequest{
+func MarkAsRead(ctx context.Context, client GmailClient, msgID string) {
+	err := client.ModifyMessage(ctx, msgID, &gmail.ModifyMessageRequest{
 		RemoveLabelIds: []string{"UNREAD"},
-	}).Do()
+	})
 	if err != nil {
 		logger.Error.Printf("Failed to mark email %s as read: %v", msgID, err)
 	} else {
@@ -44,32 +53,140 @@ func MarkAsRead(srv *gmail.Service, user, msgID string) {
 	}
 }
 
+// MarkAsProcessed marks the message as read and applies the label with the
+// given ID, so handled voicemails are visible in the mailbox. Any failure
+// label left by an earlier attempt is removed.
+func MarkAsProcessed(ctx context.Context, client GmailClient, msgID, labelID, failedLabelID string) {
+	req := &gmail.ModifyMessageRequest{RemoveLabelIds: []string{"UNREAD"}}
+	if labelID != "" {
+		req.AddLabelIds = []string{labelID}
+	}
+	if failedLabelID != "" {
+		req.RemoveLabelIds = append(req.RemoveLabelIds, failedLabelID)
+	}
+
+	if err := client.ModifyMessage(ctx, msgID, req); err != nil {
+		logger.Error.Printf("Failed to mark email %s as processed: %v", msgID, err)
+		return
+	}
+	logger.Info.Printf("🏷️ Marked email %s as processed.", msgID)
+}
+
+// MarkAsFailed applies the failure label and leaves the message unread so it
+// stands out in the mailbox and can be reprocessed.
+func MarkAsFailed(ctx context.Context, client GmailClient, msgID, failedLabelID string) {
+	if failedLabelID == "" {
+		logger.Warn.Printf("⚠️ Leaving failed email %s unread", msgID)
+		return
+	}
+
+	err := client.ModifyMessage(ctx, msgID, &gmail.ModifyMessageRequest{
+		AddLabelIds: []string{failedLabelID},
+	})
+	if err != nil {
+		logger.Error.Printf("Failed to label email %s as failed: %v", msgID, err)
+		return
+	}
+	logger.Warn.Printf("🏷️ Labelled email %s as failed and left it unread.", msgID)
+}
+
 func GetHeader(headers []*gmail.MessagePartHeader, name string) string {
 	for _, h := range headers {
-		if h.Name == name {
+		if strings.EqualFold(h.Name, name) {
 			return h.Value
 		}
 	}
 	return ""
 }
 
-func SaveHistoryIDToFirestore(ctx context.Context, client *firestore.Client, id uint64) error {
-	_, err := client.Collection("gmail_state").Doc("history").Set(ctx, map[string]interface{}{
-		"historyId": int64(id),
+const (
+	historyCollection = "gmail_state"
+	// legacyHistoryDoc is the single-mailbox document used before history
+	// state was kept per mailbox.
+	legacyHistoryDoc = "history"
+)
+
+// historyDoc returns the per-mailbox history state document, keyed by the
+// lower-cased email address.
+func historyDoc(client *firestore.Client, mailbox string) *firestore.DocumentRef {
+	return client.Collection(historyCollection).Doc(strings.ToLower(strings.TrimSpace(mailbox)))
+}
+
+// SaveHistoryIDToFirestore advances the mailbox's stored history ID to id
+// inside a transaction. The stored value never moves backwards, so a slow
+// handler can't overwrite progress made by a concurrent one.
+func SaveHistoryIDToFirestore(ctx context.Context, client *firestore.Client, mailbox string, id uint64) error {
+	if mailbox == "" {
+		return fmt.Errorf("mailbox must not be empty")
+	}
+	ref := historyDoc(client, mailbox)
+	advanced := false
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		advanced = false
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if current, err := historyIDFromDoc(doc); err == nil && current >= id {
+				return nil
+			}
+		}
+
+		advanced = true
+		return tx.Set(ref, map[string]interface{}{
+			"historyId": int64(id),
+			"mailbox":   mailbox,
+			"updatedAt": time.Now(),
+		}, firestore.MergeAll)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to save history ID to Firestore: %w", err)
 	}
-	logger.Info.Printf("📌 Saved history ID to Firestore: %d", id)
+
+	if advanced {
+		logger.Info.Printf("📌 Saved history ID to Firestore for %s: %d", mailbox, id)
+	} else {
+		logger.Debug.Printf("📌 Stored history ID for %s is already at or past %d", mailbox, id)
+	}
+	return nil
+}
+
+// ResetHistoryID stores id as the mailbox's history ID even when that moves
+// it backwards, for recovering from a bad stored value.
+func ResetHistoryID(ctx context.Context, client *firestore.Client, mailbox string, id uint64) error {
+	if mailbox == "" {
+		return fmt.Errorf("mailbox must not be empty")
+	}
+	_, err := historyDoc(client, mailbox).Set(ctx, map[string]interface{}{
+		"historyId": int64(id),
+		"mailbox":   mailbox,
+		"updatedAt": time.Now(),
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to reset history ID in Firestore: %w", err)
+	}
+	logger.Warn.Printf("📌 Reset history ID for %s to %d", mailbox, id)
 	return nil
 }
 
-func LoadHistoryIDFromFirestore(ctx context.Context, client *firestore.Client) (uint64, error) {
-	doc, err := client.Collection("gmail_state").Doc("history").Get(ctx)
+// LoadHistoryIDFromFirestore returns the mailbox's stored history ID,
+// falling back to the legacy single-mailbox document when the mailbox has
+// none yet.
+func LoadHistoryIDFromFirestore(ctx context.Context, client *firestore.Client, mailbox string) (uint64, error) {
+	doc, err := historyDoc(client, mailbox).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		logger.Warn.Printf("⚠️ No history state for %s, trying legacy document", mailbox)
+		doc, err = client.Collection(historyCollection).Doc(legacyHistoryDoc).Get(ctx)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to load history ID from Firestore: %w", err)
 	}
+	return historyIDFromDoc(doc)
+}
 
+func historyIDFromDoc(doc *firestore.DocumentSnapshot) (uint64, error) {
 	id, err := doc.DataAt("historyId")
 	if err != nil {
 		return 0, fmt.Errorf("historyId not found in document: %w", err)
@@ -87,19 +204,73 @@ func LoadHistoryIDFromFirestore(ctx context.Context, client *firestore.Client) (
 	}
 }
 
-func GetLatestMessage(srv *gmail.Service, user string) (*gmail.Message, error) {
-	msgs, err := srv.Users.Messages.List(user).MaxResults(1).LabelIds("INBOX").Do()
+func GetLatestMessage(ctx context.Context, client GmailClient) (*gmail.Message, error) {
+	labelIDs, err := ResolveLabelIDs(ctx, client, ConfiguredLabels())
 	if err != nil {
-		return nil, fmt.Errorf("failed to list messages: %w", err)
-	}
-	if len(msgs.Messages) == 0 {
-		return nil, fmt.Errorf("no messages found")
+		return nil, err
 	}
 
-	msgID := msgs.Messages[0].Id
-	msg, err := srv.Users.Messages.Get(user, msgID).Do()
+	msgID, err := latestMessageID(ctx, client, labelIDs)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := client.GetMessage(ctx, msgID, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 	return msg, nil
 }
+
+// latestMessageID returns the ID of the newest message carrying labelIDs.
+func latestMessageID(ctx context.Context, client GmailClient, labelIDs []string) (string, error) {
+	var msgID string
+	err := client.ListMessages(ctx, "", labelIDs, 1, func(resp *gmail.ListMessagesResponse) error {
+		if len(resp.Messages) > 0 {
+			msgID = resp.Messages[0].Id
+		}
+		return errStopPaging
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return "", fmt.Errorf("failed to list messages: %w", err)
+	}
+	if msgID == "" {
+		return "", fmt.Errorf("no messages found")
+	}
+	return msgID, nil
+}
+
+// MessageText returns the plain-text body of msg, falling back to the HTML
+// body with tags stripped when there is no text/plain part.
+func MessageText(msg *gmail.Message) string {
+	if msg.Payload == nil {
+		return ""
+	}
+	if text := findBody(msg.Payload, "text/plain"); text != "" {
+		return text
+	}
+	return stripTags(findBody(msg.Payload, "text/html"))
+}
+
+func findBody(part *gmail.MessagePart, mimeType string) string {
+	if strings.HasPrefix(part.MimeType, mimeType) && part.Body != nil && part.Body.Data != "" {
+		data, err := base64.URLEncoding.DecodeString(part.Body.Data)
+		if err != nil {
+			data, err = base64.RawURLEncoding.DecodeString(part.Body.Data)
+		}
+		if err == nil {
+			return string(data)
+		}
+	}
+	for _, child := range part.Parts {
+		if text := findBody(child, mimeType); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(s string) string {
+	return html.UnescapeString(tagPattern.ReplaceAllString(s, " "))
+}
@@ -5,50 +5,90 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
-	"google.golang.org/api/option"
 	"io"
 	"net/http"
 	"net/mail"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"voicemail-transcriber-production/internal/audit"
 	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/dedup"
+	"voicemail-transcriber-production/internal/errorreport"
 	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/retry"
+	"voicemail-transcriber-production/internal/tenant"
 	"voicemail-transcriber-production/internal/transcriber"
 )
 
 type PubSubMessage struct {
 	Message struct {
-		Data string `json:"data"`
+		Data      string `json:"data"`
+		MessageID string `json:"messageId"`
 	} `json:"message"`
+	Subscription    string `json:"subscription"`
+	DeliveryAttempt int    `json:"deliveryAttempt"`
 }
 
-var processedMessages = make(map[string]bool)
+// Transcriber turns an audio file into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath, mimeType string) (*transcriber.Result, error)
+}
 
-func InitFirestoreHistory(ctx context.Context, srv *gmail.Service, fsClient *firestore.Client) error {
-	msgList, err := srv.Users.Messages.List("me").MaxResults(1).Do()
-	if err != nil {
-		return fmt.Errorf("failed to list messages: %w", err)
-	}
+// TranscribeFunc adapts a function to the Transcriber interface.
+type TranscribeFunc func(ctx context.Context, audioPath, mimeType string) (*transcriber.Result, error)
 
-	if len(msgList.Messages) == 0 {
-		return fmt.Errorf("no messages found")
-	}
+func (f TranscribeFunc) Transcribe(ctx context.Context, audioPath, mimeType string) (*transcriber.Result, error) {
+	return f(ctx, audioPath, mimeType)
+}
 
-	latestMsgID := msgList.Messages[0].Id
-	msg, err := srv.Users.Messages.Get("me", latestMsgID).Format("metadata").Do()
-	if err != nil {
-		return fmt.Errorf("failed to get message: %w", err)
+// Handler processes Gmail push notifications using clients shared across
+// requests. Its dependencies are interfaces so tests can replace them.
+type Handler struct {
+	// Mailbox is the email address whose history the handler processes.
+	Mailbox     string
+	Gmail       GmailClient
+	History     HistoryStore
+	Firestore   *firestore.Client
+	Transcriber Transcriber
+	Dedup       dedup.Store
+	// Queue, when set, receives new messages instead of them being
+	// processed during the notification request.
+	Queue TaskQueue
+
+	// watchExpiry caches the watch expiry in Unix milliseconds.
+	watchExpiry atomic.Int64
+}
+
+// NewHandler returns a Handler using the given clients. A nil transcriber
+// uses Deepgram.
+func NewHandler(srv *gmail.Service, fsClient *firestore.Client, t Transcriber) *Handler {
+	if t == nil {
+		t = &transcriber.Deepgram{}
 	}
+	return &Handler{
+		Mailbox:     os.Getenv("EMAIL_RESPONSE_ADDRESS"),
+		Gmail:       NewClient(srv),
+		History:     &FirestoreHistory{Client: fsClient},
+		Firestore:   fsClient,
+		Transcriber: t,
+		Dedup:       dedup.New(fsClient),
+	}
+}
 
-	historyID := msg.HistoryId
-	if historyID == 0 {
-		return fmt.Errorf("history ID is missing from message")
+func InitFirestoreHistory(ctx context.Context, client GmailClient, history HistoryStore, mailbox string) error {
+	historyID, err := LatestHistoryID(ctx, client)
+	if err != nil {
+		return err
 	}
 
-	err = SaveHistoryIDToFirestore(ctx, fsClient, historyID)
+	err = history.Save(ctx, mailbox, historyID)
 	if err != nil {
 		return fmt.Errorf("failed to save to Firestore: %w", err)
 	}
@@ -57,15 +97,35 @@ func InitFirestoreHistory(ctx context.Context, srv *gmail.Service, fsClient *fir
 	return nil
 }
 
-func PubSubHandler(w http.ResponseWriter, r *http.Request) error {
-	start := time.Now()
-	logger.Info.Printf("📨 Received PubSub request from: %s", r.RemoteAddr)
+// LatestHistoryID returns the history ID of the newest message in the
+// mailbox.
+func LatestHistoryID(ctx context.Context, client GmailClient) (uint64, error) {
+	latestMsgID, err := latestMessageID(ctx, client, nil)
+	if err != nil {
+		return 0, err
+	}
 
+	msg, err := client.GetMessage(ctx, latestMsgID, "metadata")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	if msg.HistoryId == 0 {
+		return 0, fmt.Errorf("history ID is missing from message")
+	}
+	return msg.HistoryId, nil
+}
+
+// PubSubHandler processes a Gmail push notification delivered by Pub/Sub.
+func (h *Handler) PubSubHandler(w http.ResponseWriter, r *http.Request) error {
+	start := time.Now()
 	ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
 	defer cancel()
 
-	if !auth.IsTokenReady {
-		logger.Warn.Println("⚠️ Skipping Pub/Sub handling — token not ready")
+	logger.Info.Printf("%s📨 Received PubSub request from: %s", requestid.Prefix(ctx), r.RemoteAddr)
+
+	if !auth.TokenReady() {
+		logger.Warn.Printf("%s⚠️ Skipping Pub/Sub handling — token not ready", requestid.Prefix(ctx))
 		return fmt.Errorf("app not ready: token not available yet")
 	}
 
@@ -75,52 +135,35 @@ func PubSubHandler(w http.ResponseWriter, r *http.Request) error {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		logger.Error.Printf("❌ Failed to read body: %v", err)
+		logger.Error.Printf("%s❌ Failed to read body: %v", requestid.Prefix(ctx), err)
 		return fmt.Errorf("failed to read request body: %w", err)
 	}
 
-	logger.Debug.Printf("🐛 Raw body: %s", string(body))
+	logger.Debug.Printf("%s🐛 Raw body: %s", requestid.Prefix(ctx), string(body))
 
 	var msg PubSubMessage
 	if err = json.Unmarshal(body, &msg); err != nil {
-		logger.Error.Printf("❌ Failed to unmarshal PubSub message: %v", err)
+		logger.Error.Printf("%s❌ Failed to unmarshal PubSub message: %v", requestid.Prefix(ctx), err)
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
 
 	decodedData, err := base64.StdEncoding.DecodeString(msg.Message.Data)
 	if err != nil {
-		logger.Error.Printf("❌ Failed to decode message data: %v", err)
+		logger.Error.Printf("%s❌ Failed to decode message data: %v", requestid.Prefix(ctx), err)
 		return fmt.Errorf("invalid base64 data: %w", err)
 	}
 
-	logger.Debug.Printf("📨 Decoded Pub/Sub data: %s", decodedData)
+	logger.Debug.Printf("%s📨 Decoded Pub/Sub data: %s", requestid.Prefix(ctx), decodedData)
 
 	var notificationData struct {
 		EmailAddress string `json:"emailAddress"`
 		HistoryId    uint64 `json:"historyId"`
 	}
 	if err = json.Unmarshal(decodedData, &notificationData); err != nil {
-		logger.Error.Printf("❌ Failed to unmarshal decoded data: %v", err)
+		logger.Error.Printf("%s❌ Failed to unmarshal decoded data: %v", requestid.Prefix(ctx), err)
 		return fmt.Errorf("invalid message format: %w", err)
 	}
 
-	fsClient, err := firestore.NewClient(ctx, os.Getenv("GCP_PROJECT_ID"))
-	if err != nil {
-		logger.Error.Printf("❌ Failed to create Firestore client: %v", err)
-		return fmt.Errorf("failed to create Firestore client: %w", err)
-	}
-	defer func() {
-		if err := fsClient.Close(); err != nil {
-			logger.Error.Printf("❌ Error closing Firestore client: %v", err)
-		}
-	}()
-
-	srv, err := auth.LoadGmailService(ctx)
-	if err != nil {
-		logger.Error.Printf("❌ Unable to create Gmail service: %v", err)
-		return fmt.Errorf("failed to create Gmail service: %w", err)
-	}
-
 	logger.Info.Printf("📩 Processing Pub/Sub notification for: %s (History ID: %d)",
 		notificationData.EmailAddress, notificationData.HistoryId)
 
@@ -128,29 +171,51 @@ func PubSubHandler(w http.ResponseWriter, r *http.Request) error {
 		return fmt.Errorf("context error before history processing: %w", err)
 	}
 
-	previousHistoryID, err := LoadHistoryIDFromFirestore(ctx, fsClient)
+	if !strings.EqualFold(notificationData.EmailAddress, h.Mailbox) {
+		logger.Warn.Printf("%s⚠️ Notification for %s does not match mailbox %s", requestid.Prefix(ctx), notificationData.EmailAddress, h.Mailbox)
+	}
+	h.recordStatus(ctx, map[string]interface{}{"lastNotificationAt": time.Now()})
+
+	if err := tenant.Refresh(ctx, h.Firestore); err != nil {
+		logger.Warn.Printf("%s⚠️ Using cached tenant config: %v", requestid.Prefix(ctx), err)
+	}
+
+	previousHistoryID, err := h.History.Load(ctx, h.Mailbox)
 	if err != nil {
-		logger.Error.Printf("❌ Could not load history ID from Firestore: %v", err)
+		logger.Error.Printf("%s❌ Could not load history ID from Firestore: %v", requestid.Prefix(ctx), err)
 		return fmt.Errorf("failed to load history ID: %w", err)
 	}
 
 	historyCtx, historyCancel := context.WithTimeout(ctx, 30*time.Second)
 	defer historyCancel()
 
-	if err := retrieveHistory(historyCtx, srv, previousHistoryID, fsClient); err != nil {
-		if err == context.DeadlineExceeded {
-			logger.Error.Printf("❌ History retrieval timed out after 30 seconds")
-			return fmt.Errorf("history retrieval timeout: %w", err)
+	if err := h.retrieveHistory(historyCtx, previousHistoryID); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Error.Printf("%s❌ History retrieval timed out after 30 seconds", requestid.Prefix(ctx))
+			err = fmt.Errorf("history retrieval timeout: %w", err)
+		} else {
+			logger.Error.Printf("%s❌ Failed to retrieve history: %v", requestid.Prefix(ctx), err)
+			err = fmt.Errorf("failed to retrieve history: %w", err)
 		}
-		logger.Error.Printf("❌ Failed to retrieve history: %v", err)
-		return fmt.Errorf("failed to retrieve history: %w", err)
+		errorreport.Report(ctx, err)
+
+		if !h.deadLetter(context.WithoutCancel(ctx), &msg, notificationData.EmailAddress, notificationData.HistoryId, previousHistoryID, err) {
+			return err
+		}
+
+		// Acknowledge the notification so Pub/Sub stops retrying it.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "dead-lettered"})
+		return nil
 	}
 
 	elapsed := time.Since(start)
-	logger.Info.Printf("✅ PubSub request processed successfully in %v", elapsed)
+	logger.Log.InfoContext(ctx, "notification processed",
+		"stage", "notify", "history_id", notificationData.HistoryId, "duration_ms", elapsed.Milliseconds())
 
 	if elapsed > 40*time.Second {
-		logger.Warn.Printf("⚠️ Request processing took longer than expected: %v", elapsed)
+		logger.Warn.Printf("%s⚠️ Request processing took longer than expected: %v", requestid.Prefix(ctx), elapsed)
 	}
 
 	// ✅ Write success HTTP response
@@ -163,125 +228,318 @@ func PubSubHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func HistoryRetrieveHandler(w http.ResponseWriter, r *http.Request) {
-	logger.Info.Println("🔍 Manual history polling started")
-
-	ctx := context.Background()
-	client, err := google.DefaultClient(ctx, gmail.GmailReadonlyScope, gmail.GmailModifyScope)
+// deadLetter records a failed delivery and, once the notification has failed
+// MAX_NOTIFICATION_ATTEMPTS times, saves it as a dead letter. It reports
+// whether the notification was dead-lettered.
+func (h *Handler) deadLetter(ctx context.Context, msg *PubSubMessage, email string, historyID, startHistoryID uint64, procErr error) bool {
+	attempts, err := recordFailedAttempt(ctx, h.Firestore, msg.Message.MessageID, msg.DeliveryAttempt)
 	if err != nil {
-		logger.Error.Printf("❌ Failed to get default client: %v", err)
-		http.Error(w, "Unable to get default client", http.StatusInternalServerError)
-		return
+		logger.Error.Printf("❌ %v", err)
+		return false
+	}
+	if attempts < maxNotificationAttempts() {
+		logger.Warn.Printf("⚠️ Notification %s failed (attempt %d of %d)", msg.Message.MessageID, attempts, maxNotificationAttempts())
+		return false
 	}
 
-	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		http.Error(w, "Failed to create Gmail service", http.StatusInternalServerError)
-		return
+	dl := &DeadLetter{
+		PubSubMessageID: msg.Message.MessageID,
+		EmailAddress:    email,
+		HistoryID:       historyID,
+		StartHistoryID:  startHistoryID,
+		Error:           procErr.Error(),
+		Attempts:        attempts,
+	}
+	var histErr *HistoryError
+	if errors.As(procErr, &histErr) {
+		dl.MessageIDs = histErr.MessageIDs
 	}
 
-	fsClient, err := firestore.NewClient(ctx, os.Getenv("GCP_PROJECT_ID"))
-	if err != nil {
-		logger.Error.Fatalf("❌ Failed to create Firestore client: %v", err)
+	if err := saveDeadLetter(ctx, h.Firestore, dl); err != nil {
+		logger.Error.Printf("❌ %v", err)
+		return false
 	}
-	defer fsClient.Close()
+	return true
+}
 
-	startHistoryID, err := LoadHistoryIDFromFirestore(ctx, fsClient)
+// HistoryRetrieveHandler polls Gmail history from the stored history ID, for
+// catching up without waiting for a notification.
+func (h *Handler) HistoryRetrieveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithoutCancel(r.Context())
+	logger.Info.Printf("%s🔍 Manual history polling started", requestid.Prefix(ctx))
+
+	if err := tenant.Refresh(ctx, h.Firestore); err != nil {
+		logger.Warn.Printf("%s⚠️ Using cached tenant config: %v", requestid.Prefix(ctx), err)
+	}
+
+	startHistoryID, err := h.History.Load(ctx, h.Mailbox)
 	if err != nil {
-		logger.Error.Printf("❌ Could not load history ID from Firestore: %v", err)
+		logger.Error.Printf("%s❌ Could not load history ID from Firestore: %v", requestid.Prefix(ctx), err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	retrieveHistory(ctx, srv, startHistoryID, fsClient)
+	if err := h.retrieveHistory(ctx, startHistoryID); err != nil {
+		logger.Error.Printf("%s❌ History polling failed: %v", requestid.Prefix(ctx), err)
+		http.Error(w, "History polling failed", http.StatusInternalServerError)
+		return
+	}
 
 	fmt.Fprintln(w, "✅ History polling complete. Check logs for details.")
 }
 
-func retrieveHistory(ctx context.Context, srv *gmail.Service, startHistoryID uint64, fsClient *firestore.Client) error {
-	req := srv.Users.History.List("me").
-		StartHistoryId(startHistoryID).
-		HistoryTypes("messageAdded")
-
-	err := req.Pages(ctx, func(resp *gmail.ListHistoryResponse) error {
+func (h *Handler) retrieveHistory(ctx context.Context, startHistoryID uint64) error {
+	labelIDs, err := ResolveLabelIDs(ctx, h.Gmail, ConfiguredLabels())
+	if err != nil {
+		return fmt.Errorf("failed to resolve labels: %w", err)
+	}
+	logger.Debug.Printf("%s🏷️ Processing messages with labels: %v", requestid.Prefix(ctx), labelIDs)
+
+	var seen []string
+	maxPages, maxMessages := historyMaxPages(), historyMaxMessages()
+	pages := 0
+	// pageErr keeps errors from handling a page apart from those listing
+	// it, so a 404 from Cloud Tasks isn't mistaken for an expired history ID.
+	var pageErr error
+
+	err = h.Gmail.ListHistory(ctx, startHistoryID, historyLabel(labelIDs), func(resp *gmail.ListHistoryResponse) (err error) {
+		defer func() {
+			if err != nil && !errors.Is(err, errStopPaging) {
+				pageErr = err
+			}
+		}()
+		pages++
 		if resp.History == nil {
-			logger.Info.Println("No new history records found.")
+			logger.Info.Printf("%sNo new history records found.", requestid.Prefix(ctx))
 			return nil
 		}
 
-		logger.Info.Printf("🔍 Retrieved %d history records", len(resp.History))
+		logger.Info.Printf("%s🔍 Retrieved %d history records", requestid.Prefix(ctx), len(resp.History))
 
-		for _, h := range resp.History {
-			for _, m := range h.MessagesAdded {
+		// checkpoint is where the next run resumes: the mailbox's current
+		// history ID once the last page is done, otherwise the last record
+		// taken from this page.
+		checkpoint := resp.HistoryId
+		if resp.NextPageToken != "" {
+			checkpoint = resp.History[len(resp.History)-1].Id
+		}
+		limited := false
+
+		var page []string
+		for i, record := range resp.History {
+			if maxMessages > 0 && len(seen)+len(page) >= maxMessages {
+				// Resume after the last record taken.
+				checkpoint = resp.History[i-1].Id
+				limited = true
+				break
+			}
+			for _, m := range record.MessagesAdded {
 				if m.Message != nil {
 					msgID := m.Message.Id
-					logger.Info.Printf("📨 Found message: ID=%s", msgID)
-
-					if processedMessages[msgID] {
-						logger.Debug.Printf("⚠️ Skipping already processed message: %s", msgID)
-						continue
-					}
-					processedMessages[msgID] = true
-
-					msg, err := srv.Users.Messages.Get("me", msgID).Format("full").Do()
-					if err != nil {
-						logger.Error.Printf("Failed to retrieve message %s: %v", msgID, err)
-						continue
-					}
-
-					from := GetHeader(msg.Payload.Headers, "From")
-					logger.Debug.Printf("✉️ From: %s", from)
-
-					parsed, err := mail.ParseAddress(from)
-					if err != nil {
-						logger.Error.Printf("Failed to parse From header: %v", err)
-						continue
-					}
-
-					if parsed.Address != "noreply@btonephone.com" {
-						logger.Debug.Printf("⏭️ Skipping message from %s", parsed.Address)
-						continue
-					}
-
-					//if parsed.Address != "araquach@yahoo.co.uk" {
-					//	logger.Debug.Printf("⏭️ Skipping message from %s", parsed.Address)
-					//	continue
-					//}
-
-					for _, part := range msg.Payload.Parts {
-						if part.Filename != "" && part.Body.AttachmentId != "" {
-							filePath, err := SaveAttachment(srv, "me", msg.Id, part, "/tmp")
-							if err != nil {
-								logger.Error.Printf("Failed to save attachment: %v", err)
-								continue
-							}
-
-							subject := GetHeader(msg.Payload.Headers, "Subject")
-							err = transcriber.TranscribeAndRespond(ctx, filePath, srv, subject)
-							if err != nil {
-								logger.Error.Printf("Failed to transcribe and respond: %v", err)
-							}
-
-							os.Remove(filePath)
-							MarkAsRead(srv, "me", msg.Id)
-						}
-					}
+					logger.Info.Printf("%s📨 Found message: ID=%s", requestid.Prefix(ctx), msgID)
+					page = append(page, msgID)
 				}
 			}
 		}
+		seen = append(seen, page...)
+		// Every message on the page is finished, or queued, before the
+		// history ID moves past it. One that failed temporarily keeps the
+		// checkpoint before the page, so the redelivered notification tries
+		// it again and, once it keeps failing, dead-letters it.
+		if h.Queue != nil {
+			if err := h.enqueue(ctx, page); err != nil {
+				return err
+			}
+		} else if err := failedMessages(h.handleMessages(ctx, page, labelIDs)); err != nil {
+			return err
+		}
 
-		if resp.HistoryId != 0 {
-			if err := SaveHistoryIDToFirestore(ctx, fsClient, resp.HistoryId); err != nil {
+		if checkpoint != 0 {
+			if err := h.History.Save(ctx, h.Mailbox, checkpoint); err != nil {
 				return fmt.Errorf("failed to save updated history ID to Firestore: %w", err)
 			}
 		}
 
+		more := resp.NextPageToken != ""
+		if limited || (more && maxPages > 0 && pages >= maxPages) || (more && maxMessages > 0 && len(seen) >= maxMessages) {
+			logger.Info.Printf("%s⏸️ Stopping after %d pages and %d messages; the rest of the backlog is left for the next run from history ID %d",
+				requestid.Prefix(ctx), pages, len(seen), checkpoint)
+			return errStopPaging
+		}
 		return nil
 	})
+	if errors.Is(err, errStopPaging) {
+		err = nil
+	}
 
+	if pageErr == nil && isHistoryExpired(err) {
+		logger.Warn.Printf("%s⚠️ History ID %d has expired, falling back to full sync", requestid.Prefix(ctx), startHistoryID)
+		return h.fullSync(ctx, labelIDs)
+	}
 	if err != nil {
-		return fmt.Errorf("history retrieval error: %w", err)
+		return &HistoryError{MessageIDs: seen, Err: fmt.Errorf("history retrieval error: %w", err)}
 	}
 
 	return nil
 }
+
+// historyMaxPages reads HISTORY_MAX_PAGES, how many history pages one run
+// processes before leaving the rest for the next; 0 means no limit.
+func historyMaxPages() int {
+	return historyLimit("HISTORY_MAX_PAGES", 10)
+}
+
+// historyMaxMessages reads HISTORY_MAX_MESSAGES, how many messages one run
+// takes from history before leaving the rest for the next; 0 means no limit.
+func historyMaxMessages() int {
+	return historyLimit("HISTORY_MAX_MESSAGES", 200)
+}
+
+func historyLimit(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid %s %q, using default", name, v)
+	}
+	return def
+}
+
+// historyLabel returns the label to filter history by. History can only be
+// filtered by one label, so with several the messages are checked
+// individually instead.
+func historyLabel(labelIDs []string) string {
+	if len(labelIDs) == 1 {
+		return labelIDs[0]
+	}
+	return ""
+}
+
+// handleMessage fetches a newly added message and, if it is an unprocessed
+// voicemail in the configured labels, transcribes it. Errors are logged and
+// reported here; the returned error is marked retry.Temporary when the
+// message's claim was released so it can be attempted again.
+func (h *Handler) handleMessage(ctx context.Context, msgID string, labelIDs []string) error {
+	msg, err := h.fetchMessage(ctx, msgID, labelIDs)
+	if err != nil || msg == nil {
+		return err
+	}
+	return h.handleFetched(ctx, msg, labelIDs)
+}
+
+// fetchMessage claims msgID and fetches it in full. Its metadata is fetched
+// first, and a message that accept rejects on that alone is never fetched
+// in full. It returns a nil message without an error when the message was
+// already claimed or was filtered out.
+func (h *Handler) fetchMessage(ctx context.Context, msgID string, labelIDs []string) (*gmail.Message, error) {
+	claimed, err := h.Dedup.Claim(ctx, msgID)
+	if errors.Is(err, dedup.ErrLeased) {
+		// Another attempt is working on it, or died holding the lease; try
+		// again later, and take it over once the lease runs out.
+		logger.Info.Printf("%s⏳ Message %s is already being processed", requestid.Prefix(ctx), msgID)
+		return nil, retry.Temporary(err, 0)
+	}
+	if err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+		return nil, retry.Temporary(err, 0)
+	}
+	if !claimed {
+		logger.Debug.Printf("%s⚠️ Skipping already processed message: %s", requestid.Prefix(ctx), msgID)
+		return nil, nil
+	}
+
+	meta, err := h.Gmail.GetMessage(ctx, msgID, "metadata")
+	if err == nil && !h.accept(ctx, meta, labelIDs) {
+		h.complete(ctx, msgID)
+		return nil, nil
+	}
+
+	msg, err := h.Gmail.GetMessage(ctx, msgID, "full")
+	if err != nil {
+		logger.Error.Printf("%sFailed to retrieve message %s: %v", requestid.Prefix(ctx), msgID, err)
+		err = fmt.Errorf("failed to retrieve message %s: %w", msgID, err)
+		errorreport.Report(ctx, err)
+		h.recordOutcome(ctx, msgID, err)
+		h.release(ctx, msgID)
+		return nil, retry.Temporary(err, 0)
+	}
+	return msg, nil
+}
+
+// complete turns the lease on msgID into a lasting processed mark.
+func (h *Handler) complete(ctx context.Context, msgID string) {
+	if err := h.Dedup.Complete(context.WithoutCancel(ctx), msgID); err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+	}
+}
+
+// release drops the dedup claim on msgID so a later delivery can process it.
+func (h *Handler) release(ctx context.Context, msgID string) {
+	if err := h.Dedup.Release(context.WithoutCancel(ctx), msgID); err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+	}
+}
+
+// accept reports whether msg is worth processing: it carries one of the
+// configured labels, comes from an allowed sender and passes spamReason.
+func (h *Handler) accept(ctx context.Context, msg *gmail.Message, labelIDs []string) bool {
+	if !hasAnyLabel(msg, labelIDs) {
+		logger.Debug.Printf("%s⏭️ Skipping message %s outside configured labels", requestid.Prefix(ctx), msg.Id)
+		return false
+	}
+	if msg.Payload == nil {
+		return true
+	}
+
+	from := GetHeader(msg.Payload.Headers, "From")
+	logger.Debug.Printf("%s✉️ From: %s", requestid.Prefix(ctx), from)
+
+	parsed, err := mail.ParseAddress(from)
+	if err != nil {
+		logger.Error.Printf("%sFailed to parse From header: %v", requestid.Prefix(ctx), err)
+		return false
+	}
+
+	if !isAllowedSender(parsed.Address) {
+		logger.Debug.Printf("%s⏭️ Skipping message from %s", requestid.Prefix(ctx), parsed.Address)
+		audit.Record(ctx, h.Firestore, msg.Id, audit.EventSkipped, "sender "+parsed.Address+" is not allowed")
+		return false
+	}
+
+	if reason := spamReason(msg, parsed.Address); reason != "" {
+		logger.Info.Printf("%s🚫 Skipping message %s from %s: %s", requestid.Prefix(ctx), msg.Id, parsed.Address, reason)
+		audit.Record(ctx, h.Firestore, msg.Id, audit.EventSkipped, reason)
+		return false
+	}
+	return true
+}
+
+// handleFetched filters and processes a claimed message.
+func (h *Handler) handleFetched(ctx context.Context, msg *gmail.Message, labelIDs []string) error {
+	msgID := msg.Id
+	if !h.accept(ctx, msg, labelIDs) {
+		h.complete(ctx, msgID)
+		return nil
+	}
+
+	procErr := h.processMessage(ctx, msg, false)
+	h.recordOutcome(ctx, msgID, procErr)
+	if procErr != nil && !errors.Is(procErr, errNoAudio) && !errors.Is(procErr, errDeferred) && !errors.Is(procErr, errAlreadyDone) {
+		logger.Error.Printf("%s❌ Message %s processed with errors: %v", requestid.Prefix(ctx), msgID, procErr)
+		errorreport.Report(ctx, fmt.Errorf("message %s: %w", msgID, procErr))
+	}
+
+	// Nothing was transcribed, so nothing was sent or recorded: the message
+	// can safely be tried again. A deferred message has already given up its
+	// claim.
+	switch {
+	case DryRun() || errors.Is(procErr, errNothingTranscribed):
+		h.release(ctx, msgID)
+	case !errors.Is(procErr, errDeferred):
+		h.complete(ctx, msgID)
+	}
+	if errors.Is(procErr, errNothingTranscribed) {
+		return retry.Temporary(procErr, 0)
+	}
+	return nil
+}
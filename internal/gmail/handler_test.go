@@ -0,0 +1,346 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	"voicemail-transcriber-production/internal/dedup"
+	"voicemail-transcriber-production/internal/gmailfake"
+	"voicemail-transcriber-production/internal/logger"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// recordingQueue is a TaskQueue that records what it was given and fails
+// with err when set.
+type recordingQueue struct {
+	mu    sync.Mutex
+	ids   []string
+	calls int
+	err   error
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, msgID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.calls++
+	if q.err != nil {
+		return q.err
+	}
+	q.ids = append(q.ids, msgID)
+	return nil
+}
+
+const testMailbox = "voicemail@example.com"
+
+// testMailboxWith returns a mailbox holding one unread voicemail per
+// history record, with IDs from 101 up.
+func testMailboxWith(n int) *gmailfake.Mailbox {
+	mb := &gmailfake.Mailbox{
+		EmailAddress: testMailbox,
+		Labels:       []*gmail.Label{{Id: "INBOX", Name: "INBOX"}, {Id: "UNREAD", Name: "UNREAD"}},
+	}
+	for i := range n {
+		id := fmt.Sprintf("m%d", i+1)
+		historyID := uint64(101 + i)
+		mb.Messages = append(mb.Messages, &gmail.Message{Id: id, HistoryId: historyID, LabelIds: []string{"INBOX", "UNREAD"}})
+		mb.History = append(mb.History, &gmail.History{
+			Id:            historyID,
+			MessagesAdded: []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: id, LabelIds: []string{"INBOX", "UNREAD"}}}},
+		})
+	}
+	return mb
+}
+
+func TestRetrieveHistoryCheckpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		messages    int
+		start       uint64
+		maxMessages string
+		expired     uint64
+		queueErr    error
+		wantErr     bool
+		wantQueued  []string
+		wantCalls   int
+		wantHistory uint64
+	}{
+		{
+			name:        "every message queued",
+			messages:    3,
+			start:       100,
+			wantQueued:  []string{"m1", "m2", "m3"},
+			wantCalls:   3,
+			wantHistory: 103,
+		},
+		{
+			name:        "only records after the checkpoint",
+			messages:    3,
+			start:       102,
+			wantQueued:  []string{"m3"},
+			wantCalls:   1,
+			wantHistory: 103,
+		},
+		{
+			name:        "limit stops after the last record taken",
+			messages:    3,
+			start:       100,
+			maxMessages: "2",
+			wantQueued:  []string{"m1", "m2"},
+			wantCalls:   2,
+			wantHistory: 102,
+		},
+		{
+			name:        "failed enqueue keeps the checkpoint",
+			messages:    2,
+			start:       100,
+			queueErr:    errors.New("queue unavailable"),
+			wantErr:     true,
+			wantCalls:   1,
+			wantHistory: 100,
+		},
+		{
+			name:        "queue 404 is not an expired history ID",
+			messages:    2,
+			start:       100,
+			queueErr:    &googleapi.Error{Code: http.StatusNotFound, Message: "queue not found"},
+			wantErr:     true,
+			wantCalls:   1,
+			wantHistory: 100,
+		},
+		{
+			name:        "expired history falls back to a full sync",
+			messages:    2,
+			start:       50,
+			expired:     100,
+			wantQueued:  []string{"m1", "m2"},
+			wantCalls:   2,
+			wantHistory: 102,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GMAIL_LABELS", "INBOX")
+			t.Setenv("HISTORY_MAX_MESSAGES", tt.maxMessages)
+
+			mb := testMailboxWith(tt.messages)
+			mb.ExpiredBefore = tt.expired
+			history := NewMemoryHistory()
+			history.Save(context.Background(), testMailbox, tt.start)
+			queue := &recordingQueue{err: tt.queueErr}
+			h := &Handler{
+				Mailbox: testMailbox,
+				Gmail:   mb,
+				History: history,
+				Dedup:   dedup.NewMemoryStore(time.Hour, time.Hour),
+				Queue:   queue,
+			}
+
+			err := h.retrieveHistory(context.Background(), tt.start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retrieveHistory() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(queue.ids, tt.wantQueued) {
+				t.Errorf("queued %v, want %v", queue.ids, tt.wantQueued)
+			}
+			if queue.calls != tt.wantCalls {
+				t.Errorf("enqueued %d time(s), want %d", queue.calls, tt.wantCalls)
+			}
+			if got, _ := history.Load(context.Background(), testMailbox); got != tt.wantHistory {
+				t.Errorf("history ID %d, want %d", got, tt.wantHistory)
+			}
+		})
+	}
+}
+
+// claimFailingStore is a dedup.Store whose claims fail with err.
+type claimFailingStore struct {
+	dedup.Store
+	err error
+}
+
+func (s claimFailingStore) Claim(ctx context.Context, msgID string) (bool, error) {
+	return false, s.err
+}
+
+func TestRetrieveHistoryKeepsCheckpointOnTemporaryFailure(t *testing.T) {
+	t.Setenv("GMAIL_LABELS", "INBOX")
+	t.Setenv("HISTORY_MAX_MESSAGES", "")
+
+	history := NewMemoryHistory()
+	history.Save(context.Background(), testMailbox, 100)
+	h := &Handler{
+		Mailbox: testMailbox,
+		Gmail:   testMailboxWith(2),
+		History: history,
+		Dedup:   claimFailingStore{err: errors.New("firestore unavailable")},
+	}
+
+	if err := h.retrieveHistory(context.Background(), 100); err == nil {
+		t.Fatal("retrieveHistory() returned no error for messages that failed temporarily")
+	}
+	if got, _ := history.Load(context.Background(), testMailbox); got != 100 {
+		t.Errorf("history ID %d, want 100", got)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	message := func(labels []string, headers ...string) *gmail.Message {
+		msg := &gmail.Message{Id: "m1", LabelIds: labels, Payload: &gmail.MessagePart{MimeType: "multipart/mixed"}}
+		for i := 0; i+1 < len(headers); i += 2 {
+			msg.Payload.Headers = append(msg.Payload.Headers, &gmail.MessagePartHeader{Name: headers[i], Value: headers[i+1]})
+		}
+		return msg
+	}
+	inbox := []string{"INBOX", "UNREAD"}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		msg  *gmail.Message
+		want bool
+	}{
+		{
+			name: "default carrier sender",
+			msg:  message(inbox, "From", "BT <noreply@btonephone.com>", "Subject", "Voicemail"),
+			want: true,
+		},
+		{
+			name: "outside the configured labels",
+			msg:  message([]string{"SENT"}, "From", "noreply@btonephone.com"),
+			want: false,
+		},
+		{
+			name: "metadata without a payload",
+			msg:  &gmail.Message{Id: "m1", LabelIds: inbox},
+			want: true,
+		},
+		{
+			name: "sender not allowed",
+			msg:  message(inbox, "From", "someone@example.com"),
+			want: false,
+		},
+		{
+			name: "unparseable From",
+			msg:  message(inbox, "From", "not an address"),
+			want: false,
+		},
+		{
+			name: "allowed domain",
+			env:  map[string]string{"ALLOWED_SENDERS": "@carrier.example"},
+			msg:  message(inbox, "From", "voicemail@carrier.example"),
+			want: true,
+		},
+		{
+			name: "blocked subdomain of an allowed domain",
+			env:  map[string]string{"ALLOWED_SENDERS": "@carrier.example", "BLOCKED_SENDER_DOMAINS": "news.carrier.example"},
+			msg:  message(inbox, "From", "offers@news.carrier.example"),
+			want: false,
+		},
+		{
+			name: "bulk mail",
+			msg:  message(inbox, "From", "noreply@btonephone.com", "List-Unsubscribe", "<mailto:unsubscribe@btonephone.com>"),
+			want: false,
+		},
+		{
+			name: "bulk mail allowed",
+			env:  map[string]string{"ALLOW_BULK_MAIL": "true"},
+			msg:  message(inbox, "From", "noreply@btonephone.com", "Precedence", "bulk"),
+			want: true,
+		},
+		{
+			name: "subject must match a pattern",
+			env:  map[string]string{"VOICEMAIL_SUBJECT_PATTERNS": "^voicemail"},
+			msg:  message(inbox, "From", "noreply@btonephone.com", "Subject", "Your bill is ready"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"ALLOWED_SENDERS", "BLOCKED_SENDER_DOMAINS", "ALLOW_BULK_MAIL", "REQUIRE_SENDER_AUTH", "VOICEMAIL_SUBJECT_PATTERNS"} {
+				t.Setenv(name, tt.env[name])
+			}
+			h := &Handler{Mailbox: testMailbox}
+			if got := h.accept(context.Background(), tt.msg, []string{"INBOX"}); got != tt.want {
+				t.Errorf("accept() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// formatRecorder is a GmailClient that records the format of each message
+// fetched.
+type formatRecorder struct {
+	*gmailfake.Mailbox
+	mu      sync.Mutex
+	formats []string
+}
+
+func (r *formatRecorder) GetMessage(ctx context.Context, msgID, format string) (*gmail.Message, error) {
+	r.mu.Lock()
+	r.formats = append(r.formats, format)
+	r.mu.Unlock()
+	return r.Mailbox.GetMessage(ctx, msgID, format)
+}
+
+func TestFetchMessagePrefilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        string
+		want        bool
+		wantFormats []string
+	}{
+		{
+			name:        "accepted on metadata",
+			from:        "BT <noreply@btonephone.com>",
+			want:        true,
+			wantFormats: []string{"metadata", "full"},
+		},
+		{
+			name:        "rejected on metadata",
+			from:        "someone@example.com",
+			want:        false,
+			wantFormats: []string{"metadata"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"ALLOWED_SENDERS", "BLOCKED_SENDER_DOMAINS", "ALLOW_BULK_MAIL", "REQUIRE_SENDER_AUTH", "VOICEMAIL_SUBJECT_PATTERNS"} {
+				t.Setenv(name, "")
+			}
+			mb := voicemailMailbox("m1")
+			mb.Messages[0].Payload.Headers[0].Value = tt.from
+			client := &formatRecorder{Mailbox: mb}
+			h := &Handler{
+				Mailbox: testMailbox,
+				Gmail:   client,
+				Dedup:   dedup.NewMemoryStore(time.Hour, time.Hour),
+			}
+
+			msg, err := h.fetchMessage(context.Background(), "m1", []string{"INBOX"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := msg != nil; got != tt.want {
+				t.Errorf("fetched = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(client.formats, tt.wantFormats) {
+				t.Errorf("fetched formats %v, want %v", client.formats, tt.wantFormats)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+)
+
+// HistoryStore keeps each mailbox's last processed Gmail history ID.
+type HistoryStore interface {
+	// Load returns the stored history ID for mailbox.
+	Load(ctx context.Context, mailbox string) (uint64, error)
+	// Save advances the stored history ID to id. It never moves it
+	// backwards.
+	Save(ctx context.Context, mailbox string, id uint64) error
+}
+
+// FirestoreHistory is the HistoryStore kept in the gmail_state collection.
+type FirestoreHistory struct {
+	Client *firestore.Client
+}
+
+func (f *FirestoreHistory) Load(ctx context.Context, mailbox string) (uint64, error) {
+	return LoadHistoryIDFromFirestore(ctx, f.Client, mailbox)
+}
+
+func (f *FirestoreHistory) Save(ctx context.Context, mailbox string, id uint64) error {
+	return SaveHistoryIDToFirestore(ctx, f.Client, mailbox, id)
+}
+
+// MemoryHistory is a per-process HistoryStore, suitable for tests and local
+// development.
+type MemoryHistory struct {
+	mu  sync.Mutex
+	ids map[string]uint64
+}
+
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{ids: make(map[string]uint64)}
+}
+
+func (m *MemoryHistory) Load(_ context.Context, mailbox string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.ids[strings.ToLower(mailbox)]
+	if !ok {
+		return 0, fmt.Errorf("no history ID stored for %s", mailbox)
+	}
+	return id, nil
+}
+
+func (m *MemoryHistory) Save(_ context.Context, mailbox string, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := strings.ToLower(mailbox)
+	if id > m.ids[key] {
+		m.ids[key] = id
+	}
+	return nil
+}
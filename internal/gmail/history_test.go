@@ -0,0 +1,40 @@
+package gmail
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryHistory(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemoryHistory()
+
+	if _, err := h.Load(ctx, testMailbox); err == nil {
+		t.Fatal("Load() before any Save() returned no error")
+	}
+
+	steps := []struct {
+		save uint64
+		want uint64
+	}{
+		{save: 100, want: 100},
+		{save: 120, want: 120},
+		// A slow handler saving an older ID must not move it backwards.
+		{save: 110, want: 120},
+	}
+	for _, step := range steps {
+		if err := h.Save(ctx, testMailbox, step.save); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := h.Load(ctx, testMailbox); err != nil || got != step.want {
+			t.Errorf("Load() after Save(%d) = %d, %v, want %d", step.save, got, err, step.want)
+		}
+	}
+
+	if got, err := h.Load(ctx, "VoiceMail@Example.com"); err != nil || got != 120 {
+		t.Errorf("Load() with different case = %d, %v, want 120", got, err)
+	}
+	if _, err := h.Load(ctx, "other@example.com"); err == nil {
+		t.Error("Load() for another mailbox returned no error")
+	}
+}
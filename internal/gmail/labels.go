@@ -0,0 +1,161 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/tenant"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+var (
+	labelIDCache = make(map[string]string)
+	labelIDLock  sync.Mutex
+)
+
+// ConfiguredLabels returns the label names or IDs from the tenant config or
+// GMAIL_LABELS (comma-separated), defaulting to INBOX.
+func ConfiguredLabels() []string {
+	if labels := tenant.Current().Labels; len(labels) > 0 {
+		return labels
+	}
+	labels := splitList(os.Getenv("GMAIL_LABELS"))
+	if len(labels) == 0 {
+		return []string{"INBOX"}
+	}
+	return labels
+}
+
+// ResolveLabelIDs maps label names (e.g. "Voicemail") to Gmail label IDs.
+// System labels and values that are already IDs are returned unchanged.
+func ResolveLabelIDs(ctx context.Context, client GmailClient, names []string) ([]string, error) {
+	labelIDLock.Lock()
+	defer labelIDLock.Unlock()
+
+	var missing bool
+	for _, name := range names {
+		if _, ok := labelIDCache[name]; !ok {
+			missing = true
+			break
+		}
+	}
+
+	if missing {
+		labels, err := client.ListLabels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list labels: %w", err)
+		}
+		for _, l := range labels {
+			labelIDCache[l.Name] = l.Id
+			labelIDCache[l.Id] = l.Id
+		}
+	}
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, ok := labelIDCache[name]
+		if !ok {
+			return nil, fmt.Errorf("label %q not found", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// hasAnyLabel reports whether msg carries at least one of labelIDs.
+func hasAnyLabel(msg *gmail.Message, labelIDs []string) bool {
+	for _, want := range labelIDs {
+		for _, got := range msg.LabelIds {
+			if got == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// EnsureLabel returns the ID of the user label called name, creating it if
+// it doesn't exist yet.
+func EnsureLabel(ctx context.Context, client GmailClient, name string) (string, error) {
+	ids, err := ResolveLabelIDs(ctx, client, []string{name})
+	if err == nil {
+		return ids[0], nil
+	}
+
+	label, err := client.CreateLabel(ctx, &gmail.Label{
+		Name:                  name,
+		LabelListVisibility:   "labelShow",
+		MessageListVisibility: "show",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create label %q: %w", name, err)
+	}
+
+	labelIDLock.Lock()
+	labelIDCache[label.Name] = label.Id
+	labelIDCache[label.Id] = label.Id
+	labelIDLock.Unlock()
+
+	logger.Info.Printf("🏷️ Created Gmail label %q (%s)", label.Name, label.Id)
+	return label.Id, nil
+}
+
+// processedLabel reads PROCESSED_LABEL, the label applied to successfully
+// transcribed messages. Setting it to "none" disables labelling.
+func processedLabel() string {
+	return labelSetting("PROCESSED_LABEL", "Transcribed")
+}
+
+// failedLabel reads FAILED_LABEL, the label applied to messages whose
+// transcription or email delivery failed. "none" disables labelling.
+func failedLabel() string {
+	return labelSetting("FAILED_LABEL", "Transcription-Failed")
+}
+
+// labelID ensures the named label exists, returning "" when labelling is
+// disabled or the label can't be prepared.
+func labelID(ctx context.Context, client GmailClient, name string) string {
+	if name == "" {
+		return ""
+	}
+	id, err := EnsureLabel(ctx, client, name)
+	if err != nil {
+		logger.Error.Printf("Failed to prepare label %q: %v", name, err)
+		return ""
+	}
+	return id
+}
+
+// existingLabelID returns the cached ID of an already-known label without
+// creating it.
+func existingLabelID(name string) string {
+	labelIDLock.Lock()
+	defer labelIDLock.Unlock()
+	return labelIDCache[name]
+}
+
+func labelSetting(env, def string) string {
+	v := strings.TrimSpace(os.Getenv(env))
+	switch {
+	case v == "":
+		return def
+	case strings.EqualFold(v, "none"):
+		return ""
+	default:
+		return v
+	}
+}
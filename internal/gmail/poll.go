@@ -0,0 +1,68 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// pollBatchSize caps how many unread messages one poll picks up; the rest
+// wait for the next one.
+const pollBatchSize = 100
+
+// PollMode reports whether INGEST_MODE is "poll": new mail is found by
+// listing unread messages on an interval instead of through a Gmail watch
+// and Pub/Sub push.
+func PollMode() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("INGEST_MODE")), "poll")
+}
+
+// PollInterval reads POLL_INTERVAL, how often the mailbox is polled.
+func PollInterval() time.Duration {
+	if v := os.Getenv("POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid POLL_INTERVAL %q, using default", v)
+	}
+	return time.Minute
+}
+
+// Poll lists unread messages carrying the configured labels and runs them
+// through the same pipeline as notifications, returning how many it found.
+// Processed messages are marked read and claimed, so they aren't picked up
+// again.
+func (h *Handler) Poll(ctx context.Context) (int, error) {
+	labelIDs, err := ResolveLabelIDs(ctx, h.Gmail, ConfiguredLabels())
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve labels: %w", err)
+	}
+
+	var ids []string
+	err = h.Gmail.ListMessages(ctx, "is:unread", labelIDs, pollBatchSize, func(resp *gmail.ListMessagesResponse) error {
+		for _, m := range resp.Messages {
+			ids = append(ids, m.Id)
+		}
+		return errStopPaging
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return 0, fmt.Errorf("failed to list unread messages: %w", err)
+	}
+	h.recordStatus(ctx, map[string]interface{}{"lastPollAt": time.Now()})
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	logger.Info.Printf("📥 Polling found %d unread message(s)", len(ids))
+	if h.Queue != nil {
+		return len(ids), h.enqueue(ctx, ids)
+	}
+	h.handleMessages(ctx, ids, labelIDs)
+	return len(ids), nil
+}
@@ -0,0 +1,429 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/archive"
+	"voicemail-transcriber-production/internal/audit"
+	"voicemail-transcriber-production/internal/carrier"
+	"voicemail-transcriber-production/internal/crm"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/errorreport"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/metrics"
+	"voicemail-transcriber-production/internal/notify"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/routing"
+	"voicemail-transcriber-production/internal/sink"
+	"voicemail-transcriber-production/internal/transcriber"
+	"voicemail-transcriber-production/internal/transcripts"
+	"voicemail-transcriber-production/internal/translate"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+const (
+	// AttachmentModeSeparate sends one transcription email per audio attachment.
+	AttachmentModeSeparate = "separate"
+	// AttachmentModeCombined sends a single email covering every audio attachment.
+	AttachmentModeCombined = "combined"
+)
+
+// errNoAudio is returned by processMessage for messages without audio
+// attachments.
+var errNoAudio = errors.New("message has no audio attachments")
+
+// errAlreadyDone is returned by processMessage for messages whose progress
+// record says they were processed already.
+var errAlreadyDone = errors.New("message already processed")
+
+// errNothingTranscribed wraps the error from processMessage when no
+// recording could be transcribed, so nothing was delivered.
+var errNothingTranscribed = errors.New("no recordings transcribed")
+
+var audioExtensions = map[string]bool{
+	".wav":  true,
+	".mp3":  true,
+	".m4a":  true,
+	".ogg":  true,
+	".amr":  true,
+	".aac":  true,
+	".flac": true,
+	".webm": true,
+}
+
+// DryRun reports whether DRY_RUN is "true". In a dry run voicemails are
+// downloaded, transcribed, logged and stored, but nothing is delivered,
+// messages keep their labels and read state, and neither the message nor its
+// audio is recorded as processed, so a later real run still handles it.
+func DryRun() bool {
+	return strings.EqualFold(os.Getenv("DRY_RUN"), "true")
+}
+
+// attachmentMode reads MULTI_ATTACHMENT_MODE, defaulting to one email per
+// attachment.
+func attachmentMode() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("MULTI_ATTACHMENT_MODE")))
+	if mode == AttachmentModeCombined {
+		return AttachmentModeCombined
+	}
+	return AttachmentModeSeparate
+}
+
+// audioParts walks the MIME tree of a message and returns every part that
+// carries an audio attachment, including those nested in multipart bodies.
+func audioParts(part *gmail.MessagePart) []*gmail.MessagePart {
+	if part == nil {
+		return nil
+	}
+
+	var parts []*gmail.MessagePart
+	if isAudioAttachment(part) {
+		parts = append(parts, part)
+	}
+	for _, child := range part.Parts {
+		parts = append(parts, audioParts(child)...)
+	}
+	return parts
+}
+
+func isAudioAttachment(part *gmail.MessagePart) bool {
+	if part.Filename == "" || part.Body == nil || part.Body.AttachmentId == "" {
+		return false
+	}
+	if strings.HasPrefix(strings.ToLower(part.MimeType), "audio/") {
+		return true
+	}
+	return audioExtensions[strings.ToLower(filepath.Ext(part.Filename))]
+}
+
+// processMessage transcribes every audio attachment on msg and emails the
+// results according to MULTI_ATTACHMENT_MODE. With force set, audio that was
+// already transcribed is processed again. The returned error summarizes any
+// attachments that could not be transcribed or delivered.
+func (h *Handler) processMessage(ctx context.Context, msg *gmail.Message, force bool) error {
+	srv := h.Gmail
+	start := time.Now()
+	parts := audioParts(msg.Payload)
+	if len(parts) == 0 {
+		logger.Info.Printf("%s⏭️ Message %s has no audio attachments", requestid.Prefix(ctx), msg.Id)
+		audit.Record(ctx, h.Firestore, msg.Id, audit.EventSkipped, "no audio attachments")
+		return errNoAudio
+	}
+
+	progress := newProgress()
+	if !force {
+		progress = h.loadProgress(ctx, msg.Id)
+	}
+	if progress.State == StateDone {
+		logger.Info.Printf("%s⏭️ Message %s was already processed at %s", requestid.Prefix(ctx), msg.Id,
+			progress.StateAt[StateDone].Format(time.RFC3339))
+		return errAlreadyDone
+	}
+	if progress.State != "" && progress.State != StateFailed {
+		logger.Info.Printf("%s♻️ Resuming message %s from state %s", requestid.Prefix(ctx), msg.Id, progress.State)
+	}
+
+	mode := attachmentMode()
+	dryRun := DryRun()
+	base := newVoicemail(msg)
+	base.Account = h.Mailbox
+	log := logger.Log.With("message_id", msg.Id, "caller", base.Caller)
+	log.InfoContext(ctx, "processing voicemail",
+		"stage", "start", "carrier", base.Carrier, "attachments", len(parts), "mode", mode, "dry_run", dryRun)
+	audit.Record(ctx, h.Firestore, msg.Id, audit.EventReceived,
+		fmt.Sprintf("voicemail from %s with %d attachment(s)", callerOrUnknown(base.Caller), len(parts)))
+	h.setState(ctx, msg.Id, progress, StateDiscovered, nil)
+
+	if !transcriber.Available() {
+		if err := h.deferMessage(ctx, msg.Id, transcriber.ErrUnavailable); err != nil {
+			return err
+		}
+		return errDeferred
+	}
+
+	route := routing.Resolve(ctx, h.Firestore, base.Caller)
+	crm.Enrich(ctx, base)
+	outbox := email.NewOutbox(h.Firestore, email.WithFallback(email.Backend(srv)))
+	outbox.Resend = force
+	combined := *base
+	transcribed := *base
+	provider := transcriber.Provider()
+	sent := 0
+	var errs, failedParts []string
+	for _, part := range parts {
+		rec, err := h.transcribePart(ctx, msg.Id, part, progress, force)
+		if errors.Is(err, errDuplicateAudio) {
+			logger.Info.Printf("%s⏭️ Skipping %s on message %s: same audio already transcribed", requestid.Prefix(ctx), part.Filename, msg.Id)
+			continue
+		}
+		if errors.Is(err, transcriber.ErrUnavailable) && len(transcribed.Recordings) == 0 {
+			// Nothing has been transcribed or sent yet, so the whole message
+			// can wait for the provider to recover.
+			if err := h.deferMessage(ctx, msg.Id, err); err != nil {
+				return err
+			}
+			return errDeferred
+		}
+		if err != nil {
+			log.ErrorContext(ctx, "transcription failed", "stage", "transcribe", "filename", part.Filename, "error", err)
+			errs = append(errs, fmt.Sprintf("transcribe %s: %v", part.Filename, err))
+			failedParts = append(failedParts, fmt.Sprintf("%s: %v", part.Filename, err))
+			audit.Record(ctx, h.Firestore, msg.Id, audit.EventFailed, fmt.Sprintf("transcribe %s: %v", part.Filename, err))
+			continue
+		}
+		transcribed.Recordings = append(transcribed.Recordings, *rec)
+		h.setState(ctx, msg.Id, progress, StateTranscribed, nil)
+		audit.Record(ctx, h.Firestore, msg.Id, audit.EventTranscribed,
+			fmt.Sprintf("%s (%s)", part.Filename, voicemail.FormatDuration(rec.Duration)))
+
+		if mode == AttachmentModeCombined {
+			combined.Recordings = append(combined.Recordings, *rec)
+			continue
+		}
+		if dryRun {
+			log.InfoContext(ctx, "dry run: not delivering", "stage", "deliver", "filename", part.Filename,
+				"recipients", route.Recipients.To, "channels", route.Channels)
+			continue
+		}
+
+		key := progressKey(part)
+		if progress.sent(key) {
+			log.InfoContext(ctx, "already delivered", "stage", "deliver", "filename", part.Filename)
+			sent++
+			continue
+		}
+		vm := *base
+		vm.Recordings = []voicemail.Recording{*rec}
+		analyze(ctx, &vm)
+		transcribed.ActionItems = append(transcribed.ActionItems, vm.ActionItems...)
+		mergeSentiment(&transcribed, &vm)
+		if err := notify.Deliver(ctx, outbox, &vm, route.Recipients, route.Channels,
+			h.deliveryTracker(ctx, msg.Id, key, progress)); err != nil {
+			log.ErrorContext(ctx, "delivery failed", "stage", "deliver", "filename", part.Filename, "error", err)
+			errs = append(errs, fmt.Sprintf("deliver %s: %v", part.Filename, err))
+			audit.Record(ctx, h.Firestore, msg.Id, audit.EventFailed, fmt.Sprintf("deliver %s: %v", part.Filename, err))
+			continue
+		}
+		audit.Record(ctx, h.Firestore, msg.Id, audit.EventDelivered, part.Filename+": "+deliveryDetail(route))
+		h.markSent(ctx, msg.Id, key)
+		sent++
+	}
+
+	if mode == AttachmentModeCombined && len(combined.Recordings) > 0 {
+		analyze(ctx, &combined)
+		transcribed.ActionItems = combined.ActionItems
+		mergeSentiment(&transcribed, &combined)
+		if dryRun {
+			log.InfoContext(ctx, "dry run: not delivering", "stage", "deliver",
+				"recipients", route.Recipients.To, "channels", route.Channels)
+		} else if progress.sent(combinedDeliveryKey) {
+			log.InfoContext(ctx, "already delivered", "stage", "deliver")
+			sent++
+		} else if err := notify.Deliver(ctx, outbox, &combined, route.Recipients, route.Channels,
+			h.deliveryTracker(ctx, msg.Id, combinedDeliveryKey, progress)); err != nil {
+			log.ErrorContext(ctx, "combined delivery failed", "stage", "deliver", "error", err)
+			errs = append(errs, fmt.Sprintf("deliver: %v", err))
+			audit.Record(ctx, h.Firestore, msg.Id, audit.EventFailed, fmt.Sprintf("deliver: %v", err))
+		} else {
+			audit.Record(ctx, h.Firestore, msg.Id, audit.EventDelivered, deliveryDetail(route))
+			h.markSent(ctx, msg.Id, combinedDeliveryKey)
+			sent++
+		}
+	}
+
+	if sent > 0 {
+		h.setState(ctx, msg.Id, progress, StateDelivered, nil)
+	}
+
+	if len(errs) > 0 || sent > 0 || (dryRun && len(transcribed.Recordings) > 0) {
+		status := transcripts.StatusDelivered
+		switch {
+		case len(errs) > 0:
+			status = transcripts.StatusFailed
+		case dryRun:
+			status = transcripts.StatusDryRun
+		}
+		transcribed.UrgencyKeywords = transcribed.FindKeywords(urgencyKeywords())
+		record := transcripts.NewRecord(&transcribed, provider, status, errs)
+		record.Cost = transcripts.NewCost(provider, transcriber.Model(provider), record.DurationSeconds, transcriber.RatePerMinute(provider))
+		log.InfoContext(ctx, "transcription cost", "stage", "cost", "provider", provider,
+			"audio_seconds", record.Cost.AudioSeconds, "cost_usd", record.Cost.USD)
+		if err := transcripts.Save(ctx, h.Firestore, record); err != nil {
+			logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+			errorreport.Report(ctx, err)
+		} else {
+			sink.Publish(ctx, record)
+		}
+	}
+
+	metrics.StageDuration.Since(start, "process", "")
+	log.InfoContext(ctx, "voicemail processed",
+		"stage", "complete", "sent", sent, "errors", len(errs),
+		"duration_ms", time.Since(start).Milliseconds())
+
+	switch {
+	case dryRun:
+		logger.Info.Printf("%s🧪 Dry run: leaving message %s unread and unlabelled", requestid.Prefix(ctx), msg.Id)
+		audit.Record(ctx, h.Firestore, msg.Id, audit.EventSkipped, "dry run: not delivered")
+	case len(errs) > 0:
+		MarkAsFailed(ctx, srv, msg.Id, labelID(ctx, srv, failedLabel()))
+	case sent > 0:
+		MarkAsProcessed(ctx, srv, msg.Id, labelID(ctx, srv, processedLabel()), existingLabelID(failedLabel()))
+	default:
+		MarkAsRead(ctx, srv, msg.Id)
+	}
+	if !dryRun && transcribed.Negative() {
+		markNegative(ctx, srv, msg.Id)
+	}
+	if len(failedParts) > 0 && !dryRun && !progress.sent(failureAlertKey) {
+		if h.alertUntranscribed(ctx, base, failedParts) {
+			h.markSent(ctx, msg.Id, failureAlertKey)
+		}
+	}
+
+	if len(errs) > 0 {
+		err := errors.New(strings.Join(errs, "; "))
+		if len(transcribed.Recordings) == 0 {
+			err = fmt.Errorf("%w: %s", errNothingTranscribed, err)
+		}
+		h.setState(ctx, msg.Id, progress, StateFailed, err)
+		return err
+	}
+	h.setState(ctx, msg.Id, progress, StateDone, nil)
+	return nil
+}
+
+// deliveryDetail describes where route delivers to, for the audit trail.
+func deliveryDetail(route routing.Route) string {
+	detail := "via " + strings.Join(route.Channels, ", ")
+	for _, c := range route.Channels {
+		if c == notify.ChannelEmail {
+			rcpt := route.Recipients
+			detail += " to " + strings.Join(append(append(append([]string{}, rcpt.To...), rcpt.CC...), rcpt.BCC...), ", ")
+			break
+		}
+	}
+	return detail
+}
+
+func callerOrUnknown(caller string) string {
+	if caller == "" {
+		return "unknown caller"
+	}
+	return caller
+}
+
+func newVoicemail(msg *gmail.Message) *voicemail.Voicemail {
+	vm := &voicemail.Voicemail{
+		MessageID:       msg.Id,
+		ThreadID:        msg.ThreadId,
+		RFC822MessageID: GetHeader(msg.Payload.Headers, "Message-ID"),
+		References:      GetHeader(msg.Payload.Headers, "References"),
+		Subject:         GetHeader(msg.Payload.Headers, "Subject"),
+		ReceivedAt:      time.UnixMilli(msg.InternalDate),
+	}
+	details := carrier.Parse(GetHeader(msg.Payload.Headers, "From"), vm.Subject, MessageText(msg))
+	vm.Caller = details.Caller
+	vm.Carrier = details.Carrier
+	vm.Mailbox = details.Mailbox
+	if !details.CalledAt.IsZero() {
+		vm.ReceivedAt = details.CalledAt
+	}
+	if vm.Caller != "" {
+		logger.Info.Printf("📞 Caller for message %s: %s (carrier: %s)", msg.Id, vm.Caller, vm.Carrier)
+	}
+	return vm
+}
+
+// transcribePart transcribes one attachment, reusing the transcript from an
+// earlier attempt when progress has one. force skips the duplicate-audio
+// check.
+func (h *Handler) transcribePart(ctx context.Context, msgID string, part *gmail.MessagePart, progress *messageProgress, force bool) (*voicemail.Recording, error) {
+	key := progressKey(part)
+	if rec, ok := progress.recording(key); ok {
+		logger.Info.Printf("%s♻️ Reusing transcript of %s on message %s from an earlier attempt", requestid.Prefix(ctx), part.Filename, msgID)
+		return rec, nil
+	}
+
+	downloadStarted := time.Now()
+	filePath, err := SaveAttachment(ctx, h.Gmail, msgID, part, "/tmp")
+	downloadTime := time.Since(downloadStarted)
+	metrics.StageDuration.Observe(downloadTime, "download", "")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(filePath)
+	h.setState(ctx, msgID, progress, StateDownloaded, nil)
+
+	audioData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	checksum := AudioChecksum(audioData)
+
+	duplicate, err := isDuplicateAudio(ctx, h.Firestore, checksum)
+	if err != nil {
+		logger.Warn.Printf("%s⚠️ Could not check audio checksum for %s: %v", requestid.Prefix(ctx), part.Filename, err)
+	}
+	if duplicate && !force {
+		if err := recordAudioSkipped(ctx, h.Firestore, checksum, msgID); err != nil {
+			logger.Warn.Printf("%s⚠️ %v", requestid.Prefix(ctx), err)
+		}
+		return nil, errDuplicateAudio
+	}
+
+	started := time.Now()
+	result, err := h.Transcriber.Transcribe(ctx, filePath, part.MimeType)
+	metrics.StageDuration.Since(started, "transcribe", "")
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.InfoContext(ctx, "transcribed recording",
+		"message_id", msgID, "stage", "transcribe", "filename", part.Filename,
+		"provider", result.Provider, "confidence", result.Confidence,
+		"audio_ms", result.Duration.Milliseconds(), "download_ms", downloadTime.Milliseconds(),
+		"duration_ms", time.Since(started).Milliseconds())
+
+	if !DryRun() {
+		if err := recordAudioTranscribed(ctx, h.Firestore, checksum, msgID, part.Filename); err != nil {
+			logger.Warn.Printf("%s⚠️ %v", requestid.Prefix(ctx), err)
+		}
+	}
+
+	rec := &voicemail.Recording{
+		Filename:       part.Filename,
+		Transcript:     result.Transcript,
+		Duration:       result.Duration,
+		Confidence:     result.Confidence,
+		Language:       result.Language,
+		Sentiment:      result.Sentiment,
+		SentimentScore: result.SentimentScore,
+	}
+
+	if translate.Enabled() && translate.Needed(rec.Language) {
+		translation, err := translate.Text(ctx, rec.Transcript, rec.Language)
+		if err != nil {
+			logger.Warn.Printf("%s⚠️ Could not translate %s on message %s: %v", requestid.Prefix(ctx), part.Filename, msgID, err)
+		} else {
+			rec.Translation = translation
+			logger.Info.Printf("%s🌐 Translated %s on message %s from %s", requestid.Prefix(ctx), part.Filename, msgID, rec.Language)
+		}
+	}
+
+	if archive.Enabled() {
+		object, err := archive.Upload(ctx, msgID, part.Filename, part.MimeType, audioData)
+		if err != nil {
+			logger.Warn.Printf("%s⚠️ %v", requestid.Prefix(ctx), err)
+		}
+		rec.AudioObject = object
+	}
+	h.saveRecording(ctx, msgID, key, rec)
+	return rec, nil
+}
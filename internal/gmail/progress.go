@@ -0,0 +1,272 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/dedup"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/transcripts"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const progressCollection = "message_progress"
+
+// Processing states of a message, in lifecycle order. A message moves
+// forward through them, possibly skipping some, and ends done or failed; a
+// failed message starts again from discovered when it is retried.
+const (
+	StateDiscovered  = "discovered"
+	StateDownloaded  = "downloaded"
+	StateTranscribed = "transcribed"
+	StateDelivered   = "delivered"
+	StateDone        = "done"
+	StateFailed      = "failed"
+)
+
+// States lists every processing state in lifecycle order.
+var States = []string{StateDiscovered, StateDownloaded, StateTranscribed, StateDelivered, StateDone, StateFailed}
+
+var stateOrder = map[string]int{
+	StateDiscovered:  1,
+	StateDownloaded:  2,
+	StateTranscribed: 3,
+	StateDelivered:   4,
+	StateDone:        5,
+}
+
+// messageProgress is the idempotency record for one Gmail message: its
+// processing state, the recordings already transcribed and the deliveries
+// already sent. A message picked up again, on another instance or after a
+// redelivered notification, resumes from it instead of transcribing or
+// emailing twice.
+type messageProgress struct {
+	State      string                           `firestore:"state"`
+	StateAt    map[string]time.Time             `firestore:"stateAt"`
+	Error      string                           `firestore:"error"`
+	Recordings map[string]transcripts.Recording `firestore:"recordings"`
+	Sent       map[string]time.Time             `firestore:"sent"`
+	UpdatedAt  time.Time                        `firestore:"updatedAt"`
+}
+
+// newProgress returns the progress of a message not yet worked on.
+func newProgress() *messageProgress {
+	return &messageProgress{
+		StateAt:    map[string]time.Time{},
+		Recordings: map[string]transcripts.Recording{},
+		Sent:       map[string]time.Time{},
+	}
+}
+
+// progressKey identifies a recording within its message by MIME part.
+func progressKey(part *gmail.MessagePart) string {
+	return "part_" + strings.ReplaceAll(part.PartId, ".", "_")
+}
+
+// combinedDeliveryKey is the delivery key for AttachmentModeCombined.
+const combinedDeliveryKey = "combined"
+
+// failureAlertKey marks the ops alert about recordings that couldn't be
+// transcribed as sent, so retries of the message don't repeat it.
+const failureAlertKey = "failure_alert"
+
+// loadProgress returns the message's progress, empty when there is none or
+// it can't be read.
+func (h *Handler) loadProgress(ctx context.Context, msgID string) *messageProgress {
+	p := newProgress()
+	doc, err := h.Firestore.Collection(progressCollection).Doc(msgID).Get(ctx)
+	switch {
+	case status.Code(err) == codes.NotFound:
+	case err != nil:
+		logger.Warn.Printf("%s⚠️ Could not load progress for message %s: %v", requestid.Prefix(ctx), msgID, err)
+	default:
+		if err := doc.DataTo(p); err != nil {
+			logger.Warn.Printf("%s⚠️ Could not decode progress for message %s: %v", requestid.Prefix(ctx), msgID, err)
+		}
+	}
+	if p.StateAt == nil {
+		p.StateAt = map[string]time.Time{}
+	}
+	if p.Recordings == nil {
+		p.Recordings = map[string]transcripts.Recording{}
+	}
+	if p.Sent == nil {
+		p.Sent = map[string]time.Time{}
+	}
+	return p
+}
+
+// canMove reports whether a message in the current state may move to next:
+// forward through the lifecycle, to failed from anywhere but done, and back
+// to discovered once failed.
+func (p *messageProgress) canMove(next string) bool {
+	switch {
+	case next == p.State:
+		return false
+	case p.State == "":
+		return true
+	case next == StateFailed:
+		return p.State != StateDone
+	case p.State == StateFailed:
+		return next == StateDiscovered
+	}
+	return stateOrder[next] > stateOrder[p.State]
+}
+
+// setState moves the message to state, recording when it got there and, for
+// StateFailed, why. Moves the lifecycle doesn't allow are ignored, so callers
+// can report each step without checking where a resumed message already is.
+// Starting over at StateDiscovered clears the times of the later states.
+func (h *Handler) setState(ctx context.Context, msgID string, p *messageProgress, state string, cause error) {
+	if !p.canMove(state) {
+		return
+	}
+	now := time.Now()
+	stateAt := map[string]interface{}{state: now}
+	if state == StateDiscovered {
+		p.StateAt = map[string]time.Time{}
+		for _, s := range States[1:] {
+			stateAt[s] = firestore.Delete
+		}
+	}
+	p.State = state
+	p.StateAt[state] = now
+	p.Error = ""
+	if cause != nil {
+		p.Error = cause.Error()
+	}
+	logger.Log.InfoContext(ctx, "message state changed", "message_id", msgID, "stage", "state", "state", state)
+	h.saveProgress(ctx, msgID, map[string]interface{}{
+		"state":   state,
+		"stateAt": stateAt,
+		"error":   p.Error,
+	})
+}
+
+func (p *messageProgress) recording(key string) (*voicemail.Recording, bool) {
+	r, ok := p.Recordings[key]
+	if !ok {
+		return nil, false
+	}
+	return &voicemail.Recording{
+		Filename:       r.Filename,
+		Transcript:     r.Transcript,
+		Duration:       time.Duration(r.DurationSeconds * float64(time.Second)),
+		Confidence:     r.Confidence,
+		AudioObject:    r.AudioObject,
+		Language:       r.Language,
+		Translation:    r.Translation,
+		Sentiment:      r.Sentiment,
+		SentimentScore: r.SentimentScore,
+	}, true
+}
+
+func (p *messageProgress) sent(key string) bool {
+	_, ok := p.Sent[key]
+	return ok
+}
+
+// saveRecording records that the recording under key has been transcribed.
+func (h *Handler) saveRecording(ctx context.Context, msgID, key string, rec *voicemail.Recording) {
+	h.saveProgress(ctx, msgID, map[string]interface{}{
+		"recordings": map[string]interface{}{
+			key: transcripts.Recording{
+				Filename:        rec.Filename,
+				Transcript:      rec.Transcript,
+				DurationSeconds: rec.Duration.Seconds(),
+				Confidence:      rec.Confidence,
+				AudioObject:     rec.AudioObject,
+				Language:        rec.Language,
+				Translation:     rec.Translation,
+				Sentiment:       rec.Sentiment,
+				SentimentScore:  rec.SentimentScore,
+			},
+		},
+	})
+}
+
+// markSent records that the delivery under key has gone out.
+func (h *Handler) markSent(ctx context.Context, msgID, key string) {
+	h.saveProgress(ctx, msgID, map[string]interface{}{
+		"sent": map[string]interface{}{key: time.Now()},
+	})
+}
+
+// deliveryTracker is the notify.Tracker for one delivery of a message. Each
+// channel is recorded under "<key>/<channel>" in the progress record.
+type deliveryTracker struct {
+	ctx      context.Context
+	h        *Handler
+	msgID    string
+	key      string
+	progress *messageProgress
+}
+
+func (h *Handler) deliveryTracker(ctx context.Context, msgID, key string, progress *messageProgress) *deliveryTracker {
+	return &deliveryTracker{ctx: ctx, h: h, msgID: msgID, key: key, progress: progress}
+}
+
+func (t *deliveryTracker) Sent(channel string) bool {
+	return t.progress.sent(t.key + "/" + channel)
+}
+
+func (t *deliveryTracker) MarkSent(channel string) {
+	key := t.key + "/" + channel
+	t.progress.Sent[key] = time.Now()
+	t.h.markSent(t.ctx, t.msgID, key)
+}
+
+// saveProgress merges fields into the progress record. The record expires
+// with the dedup claim; configure a Firestore TTL policy on expiresAt to
+// have it removed.
+func (h *Handler) saveProgress(ctx context.Context, msgID string, fields map[string]interface{}) {
+	if DryRun() {
+		return
+	}
+	fields["updatedAt"] = time.Now()
+	fields["expiresAt"] = time.Now().Add(dedup.TTL())
+	_, err := h.Firestore.Collection(progressCollection).Doc(msgID).Set(ctx, fields, firestore.MergeAll)
+	if err != nil {
+		logger.Warn.Printf("%s⚠️ %v", requestid.Prefix(ctx), fmt.Errorf("failed to save progress for message %s: %w", msgID, err))
+	}
+}
+
+// MessageState is where a message is in its lifecycle, for reporting.
+type MessageState struct {
+	MessageID string               `json:"messageId"`
+	State     string               `json:"state"`
+	StateAt   map[string]time.Time `json:"stateAt"`
+	Error     string               `json:"error,omitempty"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+}
+
+// LoadState returns the processing state of msgID, or nil when the message
+// has no progress record, because it was never processed or the record has
+// expired.
+func LoadState(ctx context.Context, client *firestore.Client, msgID string) (*MessageState, error) {
+	doc, err := client.Collection(progressCollection).Doc(msgID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state of message %s: %w", msgID, err)
+	}
+	var rec messageProgress
+	if err := doc.DataTo(&rec); err != nil {
+		return nil, fmt.Errorf("failed to decode state of message %s: %w", msgID, err)
+	}
+	return &MessageState{
+		MessageID: msgID,
+		State:     rec.State,
+		StateAt:   rec.StateAt,
+		Error:     rec.Error,
+		UpdatedAt: rec.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,282 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+	"voicemail-transcriber-production/internal/dedup"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/gmailfake"
+	"voicemail-transcriber-production/internal/notify"
+	"voicemail-transcriber-production/internal/retry"
+	"voicemail-transcriber-production/internal/transcriber"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestCanMove(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"", StateDiscovered, true},
+		{"", StateFailed, true},
+		{StateDiscovered, StateDownloaded, true},
+		{StateDiscovered, StateTranscribed, true},
+		{StateDownloaded, StateTranscribed, true},
+		{StateTranscribed, StateDelivered, true},
+		{StateDelivered, StateDone, true},
+		{StateTranscribed, StateDone, true},
+		{StateTranscribed, StateDownloaded, false},
+		{StateDelivered, StateDiscovered, false},
+		{StateTranscribed, StateTranscribed, false},
+		{StateDelivered, StateFailed, true},
+		{StateDone, StateFailed, false},
+		{StateDone, StateDiscovered, false},
+		{StateFailed, StateDiscovered, true},
+		{StateFailed, StateTranscribed, false},
+		{StateFailed, StateDone, false},
+	}
+	for _, tt := range tests {
+		p := &messageProgress{State: tt.from}
+		if got := p.canMove(tt.to); got != tt.want {
+			t.Errorf("canMove(%q -> %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// emulatorClient connects to the Firestore emulator, skipping the test when
+// FIRESTORE_EMULATOR_HOST isn't set.
+func emulatorClient(t *testing.T) *firestore.Client {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	client, err := firestore.NewClient(context.Background(), "voicemail-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// voicemailMailbox returns a mailbox holding one voicemail with a single
+// audio attachment whose content is unique to id, so the duplicate audio
+// check doesn't link runs.
+func voicemailMailbox(id string) *gmailfake.Mailbox {
+	return &gmailfake.Mailbox{
+		EmailAddress: testMailbox,
+		Labels:       []*gmail.Label{{Id: "INBOX", Name: "INBOX"}, {Id: "UNREAD", Name: "UNREAD"}},
+		Messages: []*gmail.Message{{
+			Id:       id,
+			ThreadId: "t-" + id,
+			LabelIds: []string{"INBOX", "UNREAD"},
+			Payload: &gmail.MessagePart{
+				MimeType: "multipart/mixed",
+				Headers: []*gmail.MessagePartHeader{
+					{Name: "From", Value: "BT <noreply@btonephone.com>"},
+					{Name: "Subject", Value: "Voicemail from 07123 456789"},
+				},
+				Parts: []*gmail.MessagePart{{
+					PartId:   "1",
+					MimeType: "audio/wav",
+					Filename: "voicemail.wav",
+					Body:     &gmail.MessagePartBody{AttachmentId: "att-" + id},
+				}},
+			},
+		}},
+		Attachments: map[string]string{id + "/att-" + id: base64.URLEncoding.EncodeToString([]byte("audio " + id))},
+	}
+}
+
+// processingClient returns an emulator client, with the environment set up
+// to email each transcription to one address.
+func processingClient(t *testing.T) *firestore.Client {
+	t.Helper()
+	client := emulatorClient(t)
+	if err := email.LoadTemplates(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DRY_RUN", "false")
+	t.Setenv("EMAIL_TO", "office@example.com")
+	t.Setenv("NOTIFY_CHANNELS", notify.ChannelEmail)
+	t.Setenv("ARCHIVE_BUCKET", "")
+	return client
+}
+
+// countingTranscriber returns a Transcriber that counts its calls in n.
+func countingTranscriber(n *atomic.Int32) Transcriber {
+	return TranscribeFunc(func(ctx context.Context, audioPath, mimeType string) (*transcriber.Result, error) {
+		n.Add(1)
+		return &transcriber.Result{Transcript: "Please call me back.", Duration: 5 * time.Second, Provider: "test"}, nil
+	})
+}
+
+func TestProcessMessageResume(t *testing.T) {
+	client := processingClient(t)
+
+	// key is the progress key of the voicemail's only attachment.
+	key := progressKey(&gmail.MessagePart{PartId: "1"})
+
+	tests := []struct {
+		name string
+		// seed records earlier progress on the message.
+		seed            func(ctx context.Context, h *Handler, msgID string)
+		wantErr         error
+		wantTranscribed int32
+		wantSent        int
+		wantState       string
+	}{
+		{
+			name:            "fresh message",
+			wantTranscribed: 1,
+			wantSent:        1,
+			wantState:       StateDone,
+		},
+		{
+			name: "transcript reused from an earlier attempt",
+			seed: func(ctx context.Context, h *Handler, msgID string) {
+				h.saveRecording(ctx, msgID, key, &voicemail.Recording{Filename: "voicemail.wav", Transcript: "earlier", Duration: time.Second})
+			},
+			wantTranscribed: 0,
+			wantSent:        1,
+			wantState:       StateDone,
+		},
+		{
+			name: "email already sent",
+			seed: func(ctx context.Context, h *Handler, msgID string) {
+				h.saveRecording(ctx, msgID, key, &voicemail.Recording{Filename: "voicemail.wav", Transcript: "earlier", Duration: time.Second})
+				h.markSent(ctx, msgID, key+"/"+notify.ChannelEmail)
+			},
+			wantTranscribed: 0,
+			wantSent:        0,
+			wantState:       StateDone,
+		},
+		{
+			name: "already done",
+			seed: func(ctx context.Context, h *Handler, msgID string) {
+				h.setState(ctx, msgID, newProgress(), StateDone, nil)
+			},
+			wantErr:         errAlreadyDone,
+			wantTranscribed: 0,
+			wantSent:        0,
+			wantState:       StateDone,
+		},
+		{
+			name: "failed message starts over",
+			seed: func(ctx context.Context, h *Handler, msgID string) {
+				h.setState(ctx, msgID, newProgress(), StateFailed, errors.New("earlier failure"))
+			},
+			wantTranscribed: 1,
+			wantSent:        1,
+			wantState:       StateDone,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			msgID := fmt.Sprintf("resume-%d-%d", time.Now().UnixNano(), i)
+			mb := voicemailMailbox(msgID)
+			var transcribed atomic.Int32
+			h := &Handler{
+				Mailbox:     testMailbox,
+				Gmail:       mb,
+				History:     NewMemoryHistory(),
+				Firestore:   client,
+				Dedup:       dedup.NewMemoryStore(time.Hour, time.Hour),
+				Transcriber: countingTranscriber(&transcribed),
+			}
+			if tt.seed != nil {
+				tt.seed(ctx, h, msgID)
+			}
+
+			msg, err := mb.GetMessage(ctx, msgID, "full")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.processMessage(ctx, msg, false); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("processMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := transcribed.Load(); got != tt.wantTranscribed {
+				t.Errorf("transcribed %d time(s), want %d", got, tt.wantTranscribed)
+			}
+			if got := len(mb.Sent()); got != tt.wantSent {
+				t.Errorf("sent %d email(s), want %d", got, tt.wantSent)
+			}
+			if p := h.loadProgress(ctx, msgID); p.State != tt.wantState {
+				t.Errorf("state %q, want %q", p.State, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestRedeliveryAfterCrash(t *testing.T) {
+	client := processingClient(t)
+	ctx := context.Background()
+	msgID := fmt.Sprintf("crash-%d", time.Now().UnixNano())
+	mb := voicemailMailbox(msgID)
+	store := dedup.NewMemoryStore(time.Hour, 50*time.Millisecond)
+	var transcribed atomic.Int32
+	h := &Handler{
+		Mailbox:     testMailbox,
+		Gmail:       mb,
+		History:     NewMemoryHistory(),
+		Firestore:   client,
+		Dedup:       store,
+		Transcriber: countingTranscriber(&transcribed),
+	}
+	labels := []string{"INBOX"}
+
+	// The first attempt claims the message, transcribes it and dies before
+	// delivering.
+	if ok, err := store.Claim(ctx, msgID); !ok || err != nil {
+		t.Fatalf("Claim() = %v, %v", ok, err)
+	}
+	key := progressKey(&gmail.MessagePart{PartId: "1"})
+	progress := newProgress()
+	h.setState(ctx, msgID, progress, StateDiscovered, nil)
+	h.saveRecording(ctx, msgID, key, &voicemail.Recording{Filename: "voicemail.wav", Transcript: "earlier", Duration: time.Second})
+	h.setState(ctx, msgID, progress, StateTranscribed, nil)
+
+	// A redelivery while the lease is held is retried rather than skipped,
+	// so the history checkpoint doesn't move past the message.
+	if err := h.handleMessage(ctx, msgID, labels); !retry.IsTemporary(err) || !errors.Is(err, dedup.ErrLeased) {
+		t.Fatalf("handleMessage() while leased = %v, want a temporary ErrLeased", err)
+	}
+	if len(mb.Sent()) != 0 {
+		t.Fatalf("sent %d email(s) while leased, want 0", len(mb.Sent()))
+	}
+
+	// Once the lease runs out, the next delivery resumes from the stored
+	// transcript.
+	time.Sleep(100 * time.Millisecond)
+	if err := h.handleMessage(ctx, msgID, labels); err != nil {
+		t.Fatalf("handleMessage() after the lease expired = %v", err)
+	}
+	if got := transcribed.Load(); got != 0 {
+		t.Errorf("transcribed %d time(s), want 0", got)
+	}
+	if got := len(mb.Sent()); got != 1 {
+		t.Errorf("sent %d email(s), want 1", got)
+	}
+	if p := h.loadProgress(ctx, msgID); p.State != StateDone {
+		t.Errorf("state %q, want %q", p.State, StateDone)
+	}
+
+	// The finished message is then skipped for good.
+	time.Sleep(100 * time.Millisecond)
+	if err := h.handleMessage(ctx, msgID, labels); err != nil {
+		t.Fatalf("handleMessage() after completion = %v", err)
+	}
+	if got := len(mb.Sent()); got != 1 {
+		t.Errorf("sent %d email(s) after completion, want 1", got)
+	}
+}
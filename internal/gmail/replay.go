@@ -0,0 +1,177 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// ReplayRequest selects the window re-run by POST /admin/replay: either a
+// history ID range or a date range. Zero end values leave the range open.
+type ReplayRequest struct {
+	StartHistoryID uint64 `json:"startHistoryId"`
+	EndHistoryID   uint64 `json:"endHistoryId"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+}
+
+// ReplayHistory re-runs history retrieval for records between start and end
+// (inclusive). Unlike retrieveHistory it leaves the stored history ID alone.
+// Messages that were already processed are still skipped by the dedup store.
+func (h *Handler) ReplayHistory(ctx context.Context, start, end uint64) ([]string, error) {
+	labelIDs, err := ResolveLabelIDs(ctx, h.Gmail, ConfiguredLabels())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve labels: %w", err)
+	}
+
+	logger.Info.Printf("%s🔁 Replaying history %d to %d", requestid.Prefix(ctx), start, end)
+
+	var seen []string
+	err = h.Gmail.ListHistory(ctx, start, historyLabel(labelIDs), func(resp *gmail.ListHistoryResponse) error {
+		for _, record := range resp.History {
+			if end != 0 && record.Id > end {
+				return errStopPaging
+			}
+			for _, m := range record.MessagesAdded {
+				if m.Message == nil {
+					continue
+				}
+				seen = append(seen, m.Message.Id)
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return seen, &HistoryError{MessageIDs: seen, Err: fmt.Errorf("history replay error: %w", err)}
+	}
+
+	failed, err := h.dispatch(ctx, seen, labelIDs)
+	if err == nil {
+		err = failedMessages(failed)
+	}
+	if err != nil {
+		return seen, &HistoryError{MessageIDs: seen, Err: fmt.Errorf("history replay error: %w", err)}
+	}
+	return seen, nil
+}
+
+// ReplayRange processes the messages in the configured labels received
+// between from and to, oldest first. Use it when the history ID for the
+// window is unknown or has expired.
+func (h *Handler) ReplayRange(ctx context.Context, from, to time.Time) ([]string, error) {
+	labelIDs, err := ResolveLabelIDs(ctx, h.Gmail, ConfiguredLabels())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve labels: %w", err)
+	}
+
+	q := fmt.Sprintf("after:%d", from.Unix())
+	if !to.IsZero() {
+		q += fmt.Sprintf(" before:%d", to.Unix())
+	}
+	logger.Info.Printf("%s🔁 Replaying messages matching %q", requestid.Prefix(ctx), q)
+
+	limit := fullSyncLimit()
+	var ids []string
+	err = h.Gmail.ListMessages(ctx, q, labelIDs, min(limit, 500), func(resp *gmail.ListMessagesResponse) error {
+		for _, m := range resp.Messages {
+			if int64(len(ids)) >= limit {
+				return errStopPaging
+			}
+			ids = append(ids, m.Id)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return nil, fmt.Errorf("failed to list messages for replay: %w", err)
+	}
+
+	slices.Reverse(ids)
+	failed, err := h.dispatch(ctx, ids, labelIDs)
+	if err == nil {
+		err = failedMessages(failed)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return ids, &HistoryError{MessageIDs: ids, Err: fmt.Errorf("replay interrupted: %w", err)}
+	}
+	return ids, nil
+}
+
+// ReplayHandler handles POST /admin/replay. The window comes from a JSON
+// ReplayRequest body or the equivalent query parameters.
+func (h *Handler) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	var req ReplayRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	} else {
+		q := r.URL.Query()
+		fmt.Sscan(q.Get("startHistoryId"), &req.StartHistoryID)
+		fmt.Sscan(q.Get("endHistoryId"), &req.EndHistoryID)
+		req.From, req.To = q.Get("from"), q.Get("to")
+	}
+
+	var (
+		ids []string
+		err error
+	)
+	switch {
+	case req.StartHistoryID != 0:
+		if req.EndHistoryID != 0 && req.EndHistoryID < req.StartHistoryID {
+			http.Error(w, "endHistoryId must not be before startHistoryId", http.StatusBadRequest)
+			return
+		}
+		ids, err = h.ReplayHistory(r.Context(), req.StartHistoryID, req.EndHistoryID)
+	case req.From != "":
+		from, perr := ParseReplayTime(req.From)
+		if perr != nil {
+			http.Error(w, "Invalid from: "+perr.Error(), http.StatusBadRequest)
+			return
+		}
+		var to time.Time
+		if req.To != "" {
+			if to, perr = ParseReplayTime(req.To); perr != nil {
+				http.Error(w, "Invalid to: "+perr.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		ids, err = h.ReplayRange(r.Context(), from, to)
+	default:
+		http.Error(w, "startHistoryId or from is required", http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]interface{}{"messageIds": ids, "count": len(ids)}
+	status := http.StatusOK
+	if err != nil {
+		logger.Error.Printf("❌ Replay failed: %v", err)
+		resp["error"] = err.Error()
+		status = http.StatusBadGateway
+	} else {
+		logger.Info.Printf("✅ Replay covered %d message(s)", len(ids))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ParseReplayTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date.
+func ParseReplayTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -0,0 +1,63 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Reprocess downloads, transcribes and delivers a message again, ignoring
+// both the processed-message claim and the audio checksum check. It's meant
+// for messages whose first transcription came out garbled.
+func (h *Handler) Reprocess(ctx context.Context, msgID string) error {
+	logger.Info.Printf("%s🔁 Reprocessing message %s", requestid.Prefix(ctx), msgID)
+
+	msg, err := h.Gmail.GetMessage(ctx, msgID, "full")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve message %s: %w", msgID, err)
+	}
+
+	// Keep the claim in place so a late notification doesn't process it a
+	// third time.
+	if _, err := h.Dedup.Claim(ctx, msgID); err != nil {
+		logger.Warn.Printf("%s⚠️ %v", requestid.Prefix(ctx), err)
+	}
+	defer h.complete(ctx, msgID)
+
+	return h.processMessage(ctx, msg, true)
+}
+
+// ReprocessHandler handles POST /admin/reprocess/{id}.
+func (h *Handler) ReprocessHandler(w http.ResponseWriter, r *http.Request) {
+	msgID := r.PathValue("id")
+	if msgID == "" {
+		http.Error(w, "Missing message ID", http.StatusBadRequest)
+		return
+	}
+
+	status, result := http.StatusOK, "reprocessed"
+	if err := h.Reprocess(r.Context(), msgID); err != nil {
+		logger.Error.Printf("❌ Reprocess of %s failed: %v", msgID, err)
+		var apiErr *googleapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+			status, result = http.StatusNotFound, "message not found"
+		case errors.Is(err, errNoAudio):
+			status, result = http.StatusUnprocessableEntity, err.Error()
+		case errors.Is(err, errDeferred):
+			status, result = http.StatusAccepted, "deferred until transcription is available"
+		default:
+			status, result = http.StatusBadGateway, err.Error()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"messageId": msgID, "result": result})
+}
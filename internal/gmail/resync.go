@@ -0,0 +1,84 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// isHistoryExpired reports whether err is Gmail's 404 for a startHistoryId
+// that is too old to list history from.
+func isHistoryExpired(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// fullSyncLimit reads FULL_SYNC_MAX_MESSAGES, the most unread messages a full
+// sync will process.
+func fullSyncLimit() int64 {
+	if v := os.Getenv("FULL_SYNC_MAX_MESSAGES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid FULL_SYNC_MAX_MESSAGES %q, using default", v)
+	}
+	return 100
+}
+
+// fullSync recovers from an expired history ID: it processes the recent
+// unread messages in the configured labels, then reseeds the stored history
+// ID from the mailbox's current one.
+func (h *Handler) fullSync(ctx context.Context, labelIDs []string) error {
+	profile, err := h.Gmail.GetProfile(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get profile for full sync: %w", err)
+	}
+
+	limit := fullSyncLimit()
+	var ids []string
+	err = h.Gmail.ListMessages(ctx, "is:unread", labelIDs, min(limit, 500), func(resp *gmail.ListMessagesResponse) error {
+		for _, m := range resp.Messages {
+			if int64(len(ids)) >= limit {
+				return errStopPaging
+			}
+			ids = append(ids, m.Id)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return fmt.Errorf("failed to list unread messages for full sync: %w", err)
+	}
+
+	logger.Info.Printf("%s🔄 Full sync found %d unread message(s)", requestid.Prefix(ctx), len(ids))
+
+	// Oldest first, so voicemails are started in the order they arrived.
+	slices.Reverse(ids)
+	failed, err := h.dispatch(ctx, ids, labelIDs)
+	if err == nil {
+		err = failedMessages(failed)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		// The history ID isn't reseeded, so the next notification syncs again.
+		return &HistoryError{MessageIDs: ids, Err: fmt.Errorf("full sync interrupted: %w", err)}
+	}
+
+	if err := h.History.Save(ctx, h.Mailbox, profile.HistoryId); err != nil {
+		return fmt.Errorf("failed to reseed history ID after full sync: %w", err)
+	}
+	logger.Info.Printf("%s🔄 Full sync complete, history reseeded at %d", requestid.Prefix(ctx), profile.HistoryId)
+	return nil
+}
+
+var errStopPaging = errors.New("stop paging")
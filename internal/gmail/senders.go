@@ -0,0 +1,40 @@
+package gmail
+
+import (
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/tenant"
+)
+
+const defaultAllowedSender = "noreply@btonephone.com"
+
+// AllowedSenders returns the sender allowlist from the tenant config or
+// ALLOWED_SENDERS (comma-separated). Entries starting with "@" allow a whole
+// domain.
+func AllowedSenders() []string {
+	if senders := tenant.Current().AllowedSenders; len(senders) > 0 {
+		return senders
+	}
+	if senders := splitList(os.Getenv("ALLOWED_SENDERS")); len(senders) > 0 {
+		return senders
+	}
+	return []string{defaultAllowedSender}
+}
+
+// isAllowedSender reports whether address matches the allowlist.
+func isAllowedSender(address string) bool {
+	address = strings.ToLower(address)
+	for _, allowed := range AllowedSenders() {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if strings.HasPrefix(allowed, "@") {
+			if strings.HasSuffix(address, allowed) {
+				return true
+			}
+			continue
+		}
+		if address == allowed {
+			return true
+		}
+	}
+	return false
+}
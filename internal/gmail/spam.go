@@ -0,0 +1,135 @@
+package gmail
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"voicemail-transcriber-production/internal/logger"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// subjectPatterns reads VOICEMAIL_SUBJECT_PATTERNS, comma-separated regular
+// expressions (matched case-insensitively) one of which a voicemail's subject
+// must match. Unset, any subject is accepted. Invalid patterns are skipped.
+func subjectPatterns() []*regexp.Regexp {
+	v := os.Getenv("VOICEMAIL_SUBJECT_PATTERNS")
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	if v == patternsSource {
+		return patterns
+	}
+
+	patterns = nil
+	for _, p := range splitList(v) {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			logger.Warn.Printf("⚠️ Invalid VOICEMAIL_SUBJECT_PATTERNS entry %q: %v", p, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	patternsSource = v
+	return patterns
+}
+
+var (
+	patternsMu     sync.Mutex
+	patternsSource string
+	patterns       []*regexp.Regexp
+)
+
+// blockedDomains reads BLOCKED_SENDER_DOMAINS, comma-separated domains whose
+// mail is never treated as voicemail even when the allowlist matches them
+// (e.g. a marketing subdomain of an allowed "@" domain).
+func blockedDomains() []string {
+	return splitList(strings.ToLower(os.Getenv("BLOCKED_SENDER_DOMAINS")))
+}
+
+// requireSenderAuth reads REQUIRE_SENDER_AUTH: when true, the sender's domain
+// must have passed DKIM or SPF according to Gmail's Authentication-Results,
+// so spoofed carrier addresses are rejected.
+func requireSenderAuth() bool {
+	return strings.EqualFold(os.Getenv("REQUIRE_SENDER_AUTH"), "true")
+}
+
+// allowBulkMail reads ALLOW_BULK_MAIL. Unless it is true, mail marked as bulk
+// or list mail (newsletters) is filtered out.
+func allowBulkMail() bool {
+	return strings.EqualFold(os.Getenv("ALLOW_BULK_MAIL"), "true")
+}
+
+// spamReason explains why msg, from address, doesn't look like a voicemail,
+// or returns "" if it does. It works on metadata, where only the top-level
+// MIME type is known, as well as on the full message.
+func spamReason(msg *gmail.Message, address string) string {
+	headers := msg.Payload.Headers
+	domain := strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+
+	for _, blocked := range blockedDomains() {
+		blocked = strings.TrimPrefix(blocked, "@")
+		if domain == blocked || strings.HasSuffix(domain, "."+blocked) {
+			return "sender domain " + domain + " is blocked"
+		}
+	}
+
+	if !allowBulkMail() {
+		precedence := strings.ToLower(GetHeader(headers, "Precedence"))
+		if GetHeader(headers, "List-Unsubscribe") != "" || precedence == "bulk" || precedence == "list" || precedence == "junk" {
+			return "bulk or mailing list mail"
+		}
+	}
+
+	if requireSenderAuth() && !senderAuthenticated(GetHeader(headers, "Authentication-Results"), domain) {
+		return "sender domain " + domain + " failed DKIM and SPF"
+	}
+
+	if res := subjectPatterns(); len(res) > 0 {
+		subject := GetHeader(headers, "Subject")
+		matched := false
+		for _, re := range res {
+			if re.MatchString(subject) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "subject doesn't match VOICEMAIL_SUBJECT_PATTERNS"
+		}
+	}
+
+	// Metadata carries the top-level MIME type but no parts; a message with
+	// an attachment is multipart unless the audio is its whole body.
+	if len(msg.Payload.Parts) == 0 {
+		mimeType := strings.ToLower(msg.Payload.MimeType)
+		if mimeType != "" && !strings.HasPrefix(mimeType, "multipart/") && !strings.HasPrefix(mimeType, "audio/") {
+			return "no attachments (" + mimeType + ")"
+		}
+	} else if len(audioParts(msg.Payload)) == 0 {
+		return "no audio attachment"
+	}
+	return ""
+}
+
+// senderAuthenticated reports whether an Authentication-Results header
+// records a DKIM or SPF pass for domain or a parent of it.
+func senderAuthenticated(results, domain string) bool {
+	for _, result := range strings.Split(strings.ToLower(results), ";") {
+		fields := strings.Fields(result)
+		if len(fields) == 0 || (fields[0] != "dkim=pass" && fields[0] != "spf=pass") {
+			continue
+		}
+		for _, f := range fields[1:] {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok || (key != "header.d" && key != "header.i" && key != "smtp.mailfrom") {
+				continue
+			}
+			value = strings.Trim(value[strings.LastIndex(value, "@")+1:], "<>\"")
+			if domain == value || strings.HasSuffix(domain, "."+value) {
+				return true
+			}
+		}
+	}
+	return false
+}
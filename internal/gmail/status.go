@@ -0,0 +1,181 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/transcripts"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusCollection holds one pipeline status document per mailbox, apart
+// from the history checkpoint so status writes never contend with it.
+const statusCollection = "pipeline_status"
+
+// statusDoc returns the mailbox's pipeline status document, keyed like
+// historyDoc.
+func statusDoc(client *firestore.Client, mailbox string) *firestore.DocumentRef {
+	return client.Collection(statusCollection).Doc(strings.ToLower(strings.TrimSpace(mailbox)))
+}
+
+// recordStatus merges fields into the mailbox's status document, where
+// Status reads them back. It is shared by every instance, unlike the
+// in-memory counters, so /status answers the same whichever one serves it.
+func (h *Handler) recordStatus(ctx context.Context, fields map[string]interface{}) {
+	if h.Firestore == nil {
+		return
+	}
+	fields["mailbox"] = h.Mailbox
+	if _, err := statusDoc(h.Firestore, h.Mailbox).Set(context.WithoutCancel(ctx), fields, firestore.MergeAll); err != nil {
+		logger.Warn.Printf("%s⚠️ Failed to record pipeline status: %v", requestid.Prefix(ctx), err)
+	}
+}
+
+// StatusCounts counts the transcripts stored in a period by status.
+type StatusCounts struct {
+	Total     int `json:"total"`
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	DryRun    int `json:"dryRun"`
+}
+
+// Status is a snapshot of the pipeline's recent activity.
+type Status struct {
+	Mailbox            string       `json:"mailbox"`
+	LastNotificationAt *time.Time   `json:"lastNotificationAt"`
+	LastPollAt         *time.Time   `json:"lastPollAt,omitempty"`
+	LastTranscribedAt  *time.Time   `json:"lastTranscribedAt"`
+	LastFailureAt      *time.Time   `json:"lastFailureAt,omitempty"`
+	LastError          string       `json:"lastError,omitempty"`
+	ErrorStreak        int64        `json:"errorStreak"`
+	WatchExpiresAt     *time.Time   `json:"watchExpiresAt,omitempty"`
+	Last24h            StatusCounts `json:"last24h"`
+	// States counts the messages worked on in the last 24 hours by their
+	// current processing state; a pile-up short of done or failed means
+	// messages are stuck.
+	States map[string]int `json:"states"`
+}
+
+// Status reads the recorded pipeline state and counts the transcripts
+// stored and the messages worked on in the last 24 hours.
+func (h *Handler) Status(ctx context.Context) (*Status, error) {
+	s := &Status{Mailbox: h.Mailbox, States: map[string]int{}}
+	doc, err := statusDoc(h.Firestore, h.Mailbox).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, fmt.Errorf("failed to load pipeline status: %w", err)
+	}
+	if err == nil {
+		data := doc.Data()
+		timeField := func(name string) *time.Time {
+			if t, ok := data[name].(time.Time); ok {
+				return &t
+			}
+			return nil
+		}
+		s.LastNotificationAt = timeField("lastNotificationAt")
+		s.LastPollAt = timeField("lastPollAt")
+		s.LastTranscribedAt = timeField("lastTranscribedAt")
+		s.LastFailureAt = timeField("lastFailureAt")
+		s.LastError, _ = data["lastError"].(string)
+		s.ErrorStreak, _ = data["errorStreak"].(int64)
+	}
+
+	expires, err := LoadWatchExpiration(ctx, h.Firestore, h.Mailbox)
+	if err != nil {
+		return nil, err
+	}
+	if !expires.IsZero() {
+		s.WatchExpiresAt = &expires
+	}
+
+	iter := h.Firestore.Collection(transcripts.Collection).
+		Where("createdAt", ">=", time.Now().Add(-24*time.Hour)).
+		Select("status").
+		Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to count recent transcripts: %w", err)
+		}
+		s.Last24h.Total++
+		switch doc.Data()["status"] {
+		case transcripts.StatusDelivered:
+			s.Last24h.Delivered++
+		case transcripts.StatusFailed:
+			s.Last24h.Failed++
+		case transcripts.StatusDryRun:
+			s.Last24h.DryRun++
+		}
+	}
+
+	states := h.Firestore.Collection(progressCollection).
+		Where("updatedAt", ">=", time.Now().Add(-24*time.Hour)).
+		Select("state").
+		Documents(ctx)
+	defer states.Stop()
+	for {
+		doc, err := states.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to count message states: %w", err)
+		}
+		if state, _ := doc.Data()["state"].(string); state != "" {
+			s.States[state]++
+		}
+	}
+	return s, nil
+}
+
+// StatusHandler serves Status as JSON. With ?maxSilence=<duration> it
+// returns 503 when nothing has been heard from Gmail for that long, or when
+// ALERT_FAILURE_THRESHOLD messages in a row have failed, so an uptime check
+// or Cloud Scheduler job can tell the pipeline has quietly stopped.
+func (h *Handler) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var maxSilence time.Duration
+	if v := r.URL.Query().Get("maxSilence"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid maxSilence duration", http.StatusBadRequest)
+			return
+		}
+		maxSilence = d
+	}
+
+	s, err := h.Status(ctx)
+	if err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+		http.Error(w, "Failed to load status", http.StatusInternalServerError)
+		return
+	}
+
+	code := http.StatusOK
+	if maxSilence > 0 {
+		heard := s.LastNotificationAt
+		if s.LastPollAt != nil && (heard == nil || s.LastPollAt.After(*heard)) {
+			heard = s.LastPollAt
+		}
+		if heard == nil || time.Since(*heard) > maxSilence || s.ErrorStreak >= alertThreshold() {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,70 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/retry"
+	"voicemail-transcriber-production/internal/tasks"
+)
+
+// TaskQueue hands messages to a worker to be processed outside the
+// notification request.
+type TaskQueue interface {
+	Enqueue(ctx context.Context, msgID string) error
+}
+
+func (h *Handler) enqueue(ctx context.Context, msgIDs []string) error {
+	for _, id := range msgIDs {
+		if err := h.Queue.Enqueue(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch queues msgIDs when there is a task queue and otherwise handles
+// them here on the worker pools, so a large batch doesn't run one message at
+// a time inside the request. It returns the IDs that failed temporarily.
+func (h *Handler) dispatch(ctx context.Context, msgIDs []string, labelIDs []string) ([]string, error) {
+	if h.Queue != nil {
+		return nil, h.enqueue(ctx, msgIDs)
+	}
+	return h.handleMessages(ctx, msgIDs, labelIDs), nil
+}
+
+// TaskHandler is the worker endpoint Cloud Tasks calls for each queued
+// message. It responds with an error status when the message can be retried,
+// so the queue's retry policy applies; messages that were partly delivered
+// are not retried, to avoid sending duplicates.
+func (h *Handler) TaskHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var job tasks.Job
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil || job.MessageID == "" {
+		// A malformed task will never succeed; acknowledge it.
+		logger.Error.Printf("%s❌ Invalid task body: %v", requestid.Prefix(ctx), err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if name := r.Header.Get("X-CloudTasks-TaskName"); name != "" {
+		logger.Info.Printf("%s📬 Task %s (attempt %s) for message %s", requestid.Prefix(ctx),
+			name, r.Header.Get("X-CloudTasks-TaskRetryCount"), job.MessageID)
+	}
+
+	labelIDs, err := ResolveLabelIDs(ctx, h.Gmail, ConfiguredLabels())
+	if err != nil {
+		logger.Error.Printf("%s❌ Failed to resolve labels: %v", requestid.Prefix(ctx), err)
+		http.Error(w, "Failed to resolve labels", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.handleMessage(ctx, job.MessageID, labelIDs); retry.IsTemporary(err) {
+		http.Error(w, fmt.Sprintf("Message %s will be retried: %v", job.MessageID, err), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
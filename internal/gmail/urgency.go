@@ -0,0 +1,34 @@
+package gmail
+
+import (
+	"context"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+var defaultUrgencyKeywords = []string{"urgent", "urgently", "emergency", "asap", "as soon as possible", "cancel today", "immediately"}
+
+// urgencyKeywords reads URGENCY_KEYWORDS, the comma-separated words and
+// phrases that mark a voicemail as urgent. Set it to "none" to turn urgency
+// detection off.
+func urgencyKeywords() []string {
+	v := os.Getenv("URGENCY_KEYWORDS")
+	if strings.EqualFold(strings.TrimSpace(v), "none") {
+		return nil
+	}
+	if keywords := splitList(v); len(keywords) > 0 {
+		return keywords
+	}
+	return defaultUrgencyKeywords
+}
+
+// flagUrgency records on vm the urgency keywords its transcript contains.
+func flagUrgency(ctx context.Context, vm *voicemail.Voicemail) {
+	vm.UrgencyKeywords = vm.FindKeywords(urgencyKeywords())
+	if vm.Urgent() {
+		logger.Info.Printf("%s🚨 Message %s is urgent (%s)", requestid.Prefix(ctx), vm.MessageID, strings.Join(vm.UrgencyKeywords, ", "))
+	}
+}
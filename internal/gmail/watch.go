@@ -0,0 +1,226 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchTopic returns the Pub/Sub topic Gmail publishes to, from
+// PUBSUB_TOPIC_NAME. A bare topic name is qualified with GCP_PROJECT_ID.
+func WatchTopic() (string, error) {
+	topic := os.Getenv("PUBSUB_TOPIC_NAME")
+	if topic == "" {
+		return "", fmt.Errorf("PUBSUB_TOPIC_NAME must be set")
+	}
+	if strings.HasPrefix(topic, "projects/") {
+		return topic, nil
+	}
+	project := os.Getenv("GCP_PROJECT_ID")
+	if project == "" {
+		return "", fmt.Errorf("GCP_PROJECT_ID must be set to qualify topic %q", topic)
+	}
+	return fmt.Sprintf("projects/%s/topics/%s", project, topic), nil
+}
+
+// WatchLabels returns the labels the watch covers: WATCH_LABELS
+// (comma-separated names or IDs) when set, otherwise the processing labels
+// from ConfiguredLabels.
+func WatchLabels() []string {
+	if labels := splitList(os.Getenv("WATCH_LABELS")); len(labels) > 0 {
+		return labels
+	}
+	return ConfiguredLabels()
+}
+
+// watchFilterBehavior reads WATCH_LABEL_FILTER: "include" (the default)
+// publishes only changes to messages carrying the watch labels, "exclude"
+// publishes changes to every other message.
+func watchFilterBehavior() string {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("WATCH_LABEL_FILTER"))); v {
+	case "", "include":
+		return "include"
+	case "exclude":
+		return v
+	default:
+		logger.Warn.Printf("⚠️ Invalid WATCH_LABEL_FILTER %q, using default", v)
+		return "include"
+	}
+}
+
+// SetupWatch asks Gmail to publish changes to the watch labels to the
+// Pub/Sub topic. Gmail expires a watch after seven days, so it has to be
+// renewed before then.
+func SetupWatch(ctx context.Context, client GmailClient) (*gmail.WatchResponse, error) {
+	topic, err := WatchTopic()
+	if err != nil {
+		return nil, err
+	}
+	labelIDs, err := ResolveLabelIDs(ctx, client, WatchLabels())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve labels: %w", err)
+	}
+	behavior := watchFilterBehavior()
+
+	resp, err := client.Watch(ctx, &gmail.WatchRequest{
+		TopicName:           topic,
+		LabelIds:            labelIDs,
+		LabelFilterBehavior: behavior,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up Gmail watch: %w", err)
+	}
+
+	logger.Info.Printf("👀 Gmail watch (%s %v) publishing to %s until %s (history %d)",
+		behavior, labelIDs, topic, time.UnixMilli(resp.Expiration).Format(time.RFC3339), resp.HistoryId)
+	return resp, nil
+}
+
+// renewWindow reads WATCH_RENEW_WINDOW, how long before expiry the watch is
+// renewed.
+func renewWindow() time.Duration {
+	if v := os.Getenv("WATCH_RENEW_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid WATCH_RENEW_WINDOW %q, using default", v)
+	}
+	return 24 * time.Hour
+}
+
+// SaveWatchExpiration stores when the mailbox's watch expires alongside its
+// history ID.
+func SaveWatchExpiration(ctx context.Context, client *firestore.Client, mailbox string, expires time.Time) error {
+	_, err := historyDoc(client, mailbox).Set(ctx, map[string]interface{}{
+		"mailbox":         mailbox,
+		"watchExpiration": expires,
+		"watchRenewedAt":  time.Now(),
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to save watch expiration: %w", err)
+	}
+	return nil
+}
+
+// LoadWatchExpiration returns when the mailbox's watch expires, or the zero
+// time if no watch has been recorded.
+func LoadWatchExpiration(ctx context.Context, client *firestore.Client, mailbox string) (time.Time, error) {
+	doc, err := historyDoc(client, mailbox).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load watch expiration: %w", err)
+	}
+	expires, _ := doc.Data()["watchExpiration"].(time.Time)
+	return expires, nil
+}
+
+// EnsureWatch renews the mailbox's watch when it has lapsed, is unknown or
+// expires within WATCH_RENEW_WINDOW, or always when force is set. It returns
+// the watch's expiry and whether it was renewed. Renewal happens under the
+// watch lock; while another instance holds it, the current expiry is
+// returned unrenewed.
+func (h *Handler) EnsureWatch(ctx context.Context, force bool) (time.Time, bool, error) {
+	expires, err := LoadWatchExpiration(ctx, h.Firestore, h.Mailbox)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	h.watchExpiry.Store(expires.UnixMilli())
+	if !force && time.Until(expires) > renewWindow() {
+		logger.Debug.Printf("👀 Gmail watch for %s valid until %s", h.Mailbox, expires.Format(time.RFC3339))
+		return expires, false, nil
+	}
+
+	acquired, err := acquireWatchLock(ctx, h.Firestore, h.Mailbox)
+	if err != nil {
+		return expires, false, err
+	}
+	if !acquired {
+		logger.Info.Printf("👀 Another instance is renewing the Gmail watch for %s", h.Mailbox)
+		return expires, false, nil
+	}
+	defer releaseWatchLock(context.WithoutCancel(ctx), h.Firestore, h.Mailbox)
+
+	// The previous holder may have renewed while this instance waited.
+	if !force {
+		if current, err := LoadWatchExpiration(ctx, h.Firestore, h.Mailbox); err == nil && time.Until(current) > renewWindow() {
+			h.watchExpiry.Store(current.UnixMilli())
+			return current, false, nil
+		}
+	}
+
+	resp, err := SetupWatch(ctx, h.Gmail)
+	if err != nil {
+		return expires, false, err
+	}
+	expires = time.UnixMilli(resp.Expiration)
+	if err := SaveWatchExpiration(ctx, h.Firestore, h.Mailbox, expires); err != nil {
+		return expires, true, err
+	}
+	h.watchExpiry.Store(expires.UnixMilli())
+	return expires, true, nil
+}
+
+// StopWatch stops the mailbox's watch and clears its recorded expiry, under
+// the watch lock so it can't race a renewal.
+func (h *Handler) StopWatch(ctx context.Context) error {
+	acquired, err := acquireWatchLock(ctx, h.Firestore, h.Mailbox)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return fmt.Errorf("another instance holds the watch lock for %s", h.Mailbox)
+	}
+	defer releaseWatchLock(context.WithoutCancel(ctx), h.Firestore, h.Mailbox)
+
+	if err := h.Gmail.StopWatch(ctx); err != nil {
+		return fmt.Errorf("failed to stop Gmail watch: %w", err)
+	}
+	_, err = historyDoc(h.Firestore, h.Mailbox).Update(ctx, []firestore.Update{
+		{Path: "watchExpiration", Value: firestore.Delete},
+	})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to clear watch expiration: %w", err)
+	}
+	h.watchExpiry.Store(0)
+	logger.Info.Printf("👀 Stopped Gmail watch for %s", h.Mailbox)
+	return nil
+}
+
+// WatchExpiry returns the watch expiry last seen by EnsureWatch, or the zero
+// time if it hasn't run.
+func (h *Handler) WatchExpiry() time.Time {
+	ms := h.watchExpiry.Load()
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// WatchHandler renews the Gmail watch on demand and reports its expiry.
+func (h *Handler) WatchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	expires, _, err := h.EnsureWatch(ctx, true)
+	if err != nil {
+		logger.Error.Printf("%s❌ %v", requestid.Prefix(ctx), err)
+		http.Error(w, "Failed to set up Gmail watch", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mailbox":   h.Mailbox,
+		"expiresAt": expires.Format(time.RFC3339),
+	})
+}
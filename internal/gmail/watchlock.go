@@ -0,0 +1,87 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	watchLockCollection = "watch_locks"
+	// watchLockTTL bounds how long a crashed instance can hold the lock.
+	watchLockTTL = 2 * time.Minute
+)
+
+// instanceID identifies this process as a lock holder.
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}()
+
+func watchLockDoc(client *firestore.Client, mailbox string) *firestore.DocumentRef {
+	return client.Collection(watchLockCollection).Doc(strings.ToLower(strings.TrimSpace(mailbox)))
+}
+
+// acquireWatchLock takes the mailbox's watch lock for this instance,
+// reporting false if another instance holds an unexpired lease. Only the
+// holder creates, renews or stops the watch, so instances starting together
+// don't set up overlapping watches.
+func acquireWatchLock(ctx context.Context, client *firestore.Client, mailbox string) (bool, error) {
+	ref := watchLockDoc(client, mailbox)
+	acquired := false
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		acquired = false
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			data := doc.Data()
+			expires, _ := data["expiresAt"].(time.Time)
+			if holder, _ := data["holder"].(string); holder != instanceID && time.Now().Before(expires) {
+				return nil
+			}
+		}
+
+		now := time.Now()
+		acquired = true
+		return tx.Set(ref, map[string]interface{}{
+			"holder":     instanceID,
+			"acquiredAt": now,
+			"expiresAt":  now.Add(watchLockTTL),
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire watch lock for %s: %w", mailbox, err)
+	}
+	return acquired, nil
+}
+
+// releaseWatchLock gives up the lock if this instance still holds it.
+func releaseWatchLock(ctx context.Context, client *firestore.Client, mailbox string) {
+	ref := watchLockDoc(client, mailbox)
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if holder, _ := doc.Data()["holder"].(string); holder != instanceID {
+			return nil
+		}
+		return tx.Delete(ref)
+	})
+	if err != nil {
+		logger.Warn.Printf("⚠️ Failed to release watch lock for %s: %v", mailbox, err)
+	}
+}
@@ -0,0 +1,117 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/retry"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// concurrency reads PROCESSING_CONCURRENCY, how many messages from one batch
+// are processed at the same time.
+func concurrency() int {
+	if v := os.Getenv("PROCESSING_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid PROCESSING_CONCURRENCY %q, using default", v)
+	}
+	return 4
+}
+
+// fetchConcurrency reads FETCH_CONCURRENCY, how many messages from one
+// batch are fetched from Gmail at the same time.
+func fetchConcurrency() int {
+	if v := os.Getenv("FETCH_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid FETCH_CONCURRENCY %q, using default", v)
+	}
+	return 10
+}
+
+// handleMessages claims and fetches the messages on a pool of
+// FETCH_CONCURRENCY workers, handing each one as it arrives to a pool of
+// PROCESSING_CONCURRENCY workers, and waits for both. Fetching ahead keeps a
+// large history page inside the Pub/Sub ack window. Messages not yet
+// fetched when ctx is done are skipped, and fetched ones have their claim
+// released. It returns the IDs of the messages that failed with a temporary
+// error and had their claim released so they can be attempted again.
+func (h *Handler) handleMessages(ctx context.Context, msgIDs []string, labelIDs []string) []string {
+	if len(msgIDs) == 0 {
+		return nil
+	}
+
+	ids := make(chan string)
+	fetched := make(chan *gmail.Message)
+	var fetchers, workers sync.WaitGroup
+	var failedLock sync.Mutex
+	var failed []string
+	fail := func(id string, err error) {
+		if retry.IsTemporary(err) {
+			failedLock.Lock()
+			failed = append(failed, id)
+			failedLock.Unlock()
+		}
+	}
+
+	for range min(fetchConcurrency(), len(msgIDs)) {
+		fetchers.Add(1)
+		go func() {
+			defer fetchers.Done()
+			for id := range ids {
+				msg, err := h.fetchMessage(ctx, id, labelIDs)
+				if err != nil {
+					fail(id, err)
+					continue
+				}
+				if msg != nil {
+					fetched <- msg
+				}
+			}
+		}()
+	}
+	for range min(concurrency(), len(msgIDs)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for msg := range fetched {
+				if ctx.Err() != nil {
+					h.release(ctx, msg.Id)
+					continue
+				}
+				if err := h.handleFetched(ctx, msg, labelIDs); err != nil {
+					fail(msg.Id, err)
+				}
+			}
+		}()
+	}
+
+	for _, id := range msgIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		ids <- id
+	}
+	close(ids)
+	fetchers.Wait()
+	close(fetched)
+	workers.Wait()
+	return failed
+}
+
+// failedMessages describes the messages handleMessages returned as failed,
+// and is nil when there are none.
+func failedMessages(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d message(s) failed temporarily: %s", len(ids), strings.Join(ids, ", "))
+}
@@ -0,0 +1,229 @@
+// Package gmailfake is an in-memory Gmail mailbox implementing
+// gmail.GmailClient, loaded from recorded fixtures, for exercising the
+// pipeline without a real account.
+package gmailfake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Mailbox is a fake Gmail account. Its exported fields are the fixture
+// data; the methods record what the pipeline did to it.
+type Mailbox struct {
+	EmailAddress string           `json:"emailAddress"`
+	Labels       []*gmail.Label   `json:"labels"`
+	Messages     []*gmail.Message `json:"messages"`
+	History      []*gmail.History `json:"history"`
+	// Attachments maps "messageID/attachmentID" to base64url data.
+	Attachments map[string]string `json:"attachments"`
+	// ExpiredBefore makes history requests starting before this ID fail
+	// with Gmail's 404, to exercise the full sync path.
+	ExpiredBefore uint64 `json:"expiredBefore"`
+
+	mu     sync.Mutex
+	sent   []*gmail.Message
+	nextID int
+}
+
+// Sent returns the messages sent through the mailbox.
+func (m *Mailbox) Sent() []*gmail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.sent)
+}
+
+// Message returns the stored message with id, or nil.
+func (m *Mailbox) Message(id string) *gmail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.message(id)
+}
+
+func (m *Mailbox) message(id string) *gmail.Message {
+	for _, msg := range m.Messages {
+		if msg.Id == id {
+			return msg
+		}
+	}
+	return nil
+}
+
+// historyID is the mailbox's current history ID: the newest record's.
+func (m *Mailbox) historyID() uint64 {
+	var id uint64
+	for _, h := range m.History {
+		id = max(id, h.Id)
+	}
+	for _, msg := range m.Messages {
+		id = max(id, msg.HistoryId)
+	}
+	return id
+}
+
+func notFound(what string) error {
+	return &googleapi.Error{Code: http.StatusNotFound, Message: what + " not found"}
+}
+
+func (m *Mailbox) GetProfile(ctx context.Context) (*gmail.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &gmail.Profile{
+		EmailAddress:  m.EmailAddress,
+		HistoryId:     m.historyID(),
+		MessagesTotal: int64(len(m.Messages)),
+	}, nil
+}
+
+func (m *Mailbox) GetMessage(ctx context.Context, msgID, format string) (*gmail.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := m.message(msgID)
+	if msg == nil {
+		return nil, notFound("message " + msgID)
+	}
+	copied := *msg
+	copied.LabelIds = slices.Clone(msg.LabelIds)
+	return &copied, nil
+}
+
+func (m *Mailbox) GetAttachment(ctx context.Context, msgID, attachmentID string) (*gmail.MessagePartBody, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.Attachments[msgID+"/"+attachmentID]
+	if !ok {
+		return nil, notFound("attachment " + attachmentID)
+	}
+	return &gmail.MessagePartBody{AttachmentId: attachmentID, Data: data, Size: int64(len(data))}, nil
+}
+
+func (m *Mailbox) ModifyMessage(ctx context.Context, msgID string, req *gmail.ModifyMessageRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := m.message(msgID)
+	if msg == nil {
+		return notFound("message " + msgID)
+	}
+	msg.LabelIds = slices.DeleteFunc(msg.LabelIds, func(l string) bool {
+		return slices.Contains(req.RemoveLabelIds, l)
+	})
+	for _, l := range req.AddLabelIds {
+		if !slices.Contains(msg.LabelIds, l) {
+			msg.LabelIds = append(msg.LabelIds, l)
+		}
+	}
+	return nil
+}
+
+func (m *Mailbox) SendMessage(ctx context.Context, msg *gmail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	sent := *msg
+	sent.Id = fmt.Sprintf("sent-%d", m.nextID)
+	sent.LabelIds = []string{"SENT"}
+	m.sent = append(m.sent, &sent)
+	return nil
+}
+
+// ListMessages returns matching messages newest first in a single page. Of
+// the search syntax only "is:unread" is understood; other terms are ignored.
+func (m *Mailbox) ListMessages(ctx context.Context, query string, labelIDs []string, pageSize int64, fn func(*gmail.ListMessagesResponse) error) error {
+	m.mu.Lock()
+	resp := &gmail.ListMessagesResponse{}
+	for i := len(m.Messages) - 1; i >= 0; i-- {
+		msg := m.Messages[i]
+		if !hasAll(msg.LabelIds, labelIDs) {
+			continue
+		}
+		if strings.Contains(query, "is:unread") && !slices.Contains(msg.LabelIds, "UNREAD") {
+			continue
+		}
+		resp.Messages = append(resp.Messages, &gmail.Message{Id: msg.Id, ThreadId: msg.ThreadId})
+		if pageSize > 0 && int64(len(resp.Messages)) >= pageSize {
+			break
+		}
+	}
+	resp.ResultSizeEstimate = int64(len(resp.Messages))
+	m.mu.Unlock()
+	return fn(resp)
+}
+
+// ListHistory returns the history records after startHistoryID in a single
+// page.
+func (m *Mailbox) ListHistory(ctx context.Context, startHistoryID uint64, labelID string, fn func(*gmail.ListHistoryResponse) error) error {
+	m.mu.Lock()
+	if startHistoryID < m.ExpiredBefore {
+		m.mu.Unlock()
+		return notFound("history " + fmt.Sprint(startHistoryID))
+	}
+	resp := &gmail.ListHistoryResponse{HistoryId: m.historyID()}
+	for _, h := range m.History {
+		if h.Id <= startHistoryID {
+			continue
+		}
+		if labelID != "" && !m.touchesLabel(h, labelID) {
+			continue
+		}
+		resp.History = append(resp.History, h)
+	}
+	m.mu.Unlock()
+	return fn(resp)
+}
+
+func (m *Mailbox) touchesLabel(h *gmail.History, labelID string) bool {
+	for _, added := range h.MessagesAdded {
+		if added.Message == nil {
+			continue
+		}
+		if slices.Contains(added.Message.LabelIds, labelID) {
+			return true
+		}
+		if msg := m.message(added.Message.Id); msg != nil && slices.Contains(msg.LabelIds, labelID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mailbox) ListLabels(ctx context.Context) ([]*gmail.Label, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.Labels), nil
+}
+
+func (m *Mailbox) CreateLabel(ctx context.Context, label *gmail.Label) (*gmail.Label, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	created := *label
+	created.Id = fmt.Sprintf("Label_%d", len(m.Labels)+1)
+	created.Type = "user"
+	m.Labels = append(m.Labels, &created)
+	return &created, nil
+}
+
+func (m *Mailbox) Watch(ctx context.Context, req *gmail.WatchRequest) (*gmail.WatchResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &gmail.WatchResponse{HistoryId: m.historyID()}, nil
+}
+
+func (m *Mailbox) StopWatch(ctx context.Context) error {
+	return nil
+}
+
+func hasAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+// Package harness runs the notification pipeline end to end against the
+// Firestore emulator and a fake Gmail mailbox, driven by fixture Pub/Sub
+// payloads, and checks the outcome against the fixture's expectations.
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/dedup"
+	"voicemail-transcriber-production/internal/gmail"
+	"voicemail-transcriber-production/internal/gmailfake"
+	"voicemail-transcriber-production/internal/transcriber"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Scenario is one fixture file.
+type Scenario struct {
+	Name string `json:"name"`
+	// Env is applied before the run, e.g. ALLOWED_SENDERS or EMAIL_TO.
+	Env map[string]string `json:"env"`
+	// StartHistoryID is stored for the mailbox before the first
+	// notification.
+	StartHistoryID uint64             `json:"startHistoryId"`
+	Mailbox        *gmailfake.Mailbox `json:"mailbox"`
+	// Notifications are the Pub/Sub payloads delivered in order.
+	Notifications []Notification `json:"notifications"`
+	// Transcripts maps attachment filenames to the text the fake
+	// transcriber returns for them.
+	Transcripts map[string]string `json:"transcripts"`
+	Expect      Expectations      `json:"expect"`
+}
+
+// Notification is the data Gmail publishes for a mailbox change.
+type Notification struct {
+	EmailAddress string `json:"emailAddress"`
+	HistoryID    uint64 `json:"historyId"`
+}
+
+// Expectations are checked once every notification has been handled.
+type Expectations struct {
+	HistoryID uint64 `json:"historyId"`
+	Sent      int    `json:"sent"`
+	// Processed messages must have lost UNREAD; Unread ones must keep it.
+	Processed []string `json:"processed"`
+	Unread    []string `json:"unread"`
+	// Status is the HTTP status expected for every notification (200 when
+	// unset).
+	Status int `json:"status"`
+}
+
+// Result is the outcome of one scenario.
+type Result struct {
+	Name     string
+	Failures []string
+	Duration time.Duration
+}
+
+func (r *Result) failf(format string, args ...interface{}) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+// Passed reports whether every expectation held.
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Load reads a scenario from a JSON fixture.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if s.Mailbox == nil {
+		return nil, fmt.Errorf("%s: mailbox is required", path)
+	}
+	return &s, nil
+}
+
+// NewClient connects to the Firestore emulator. It refuses to run without
+// FIRESTORE_EMULATOR_HOST so fixtures can never touch a real database.
+func NewClient(ctx context.Context) (*firestore.Client, error) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		return nil, fmt.Errorf("FIRESTORE_EMULATOR_HOST must be set; the harness only runs against the emulator")
+	}
+	return firestore.NewClient(ctx, project())
+}
+
+func project() string {
+	if p := os.Getenv("GCP_PROJECT_ID"); p != "" {
+		return p
+	}
+	return "voicemail-harness"
+}
+
+// resetEmulator deletes every document in the emulator's database, so state
+// such as audio checksums can't leak from one scenario into the next.
+func resetEmulator(ctx context.Context) error {
+	url := fmt.Sprintf("http://%s/emulator/v1/projects/%s/databases/(default)/documents",
+		os.Getenv("FIRESTORE_EMULATOR_HOST"), project())
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reset Firestore emulator: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to reset Firestore emulator: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Environment is the configuration the scenario runs under: its Env and
+// the mailbox address.
+func (s *Scenario) Environment() map[string]string {
+	env := map[string]string{"EMAIL_RESPONSE_ADDRESS": s.Mailbox.EmailAddress}
+	for k, v := range s.Env {
+		env[k] = v
+	}
+	return env
+}
+
+// Setenv applies the scenario's Environment and returns a function that
+// restores the previous values, so one scenario's settings don't leak into
+// the next.
+func Setenv(s *Scenario) (restore func()) {
+	var undo []func()
+	for k, v := range s.Environment() {
+		if old, ok := os.LookupEnv(k); ok {
+			undo = append(undo, func() { os.Setenv(k, old) })
+		} else {
+			undo = append(undo, func() { os.Unsetenv(k) })
+		}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for _, f := range undo {
+			f()
+		}
+	}
+}
+
+// Run plays the scenario's notifications through the Pub/Sub handler. The
+// scenario's Environment must already be applied, by Setenv or in a test
+// by t.Setenv.
+func Run(ctx context.Context, client *firestore.Client, s *Scenario) *Result {
+	start := time.Now()
+	res := &Result{Name: s.Name}
+	defer func() { res.Duration = time.Since(start) }()
+
+	mailbox := s.Mailbox.EmailAddress
+	auth.SetTokenReady(true)
+
+	if err := resetEmulator(ctx); err != nil {
+		res.failf("%v", err)
+		return res
+	}
+	if err := gmail.ResetHistoryID(ctx, client, mailbox, s.StartHistoryID); err != nil {
+		res.failf("seed history: %v", err)
+		return res
+	}
+
+	h := &gmail.Handler{
+		Mailbox:     mailbox,
+		Gmail:       s.Mailbox,
+		History:     &gmail.FirestoreHistory{Client: client},
+		Firestore:   client,
+		Transcriber: gmail.TranscribeFunc(s.transcribe),
+		Dedup:       dedup.NewMemoryStore(time.Hour, time.Hour),
+	}
+
+	wantStatus := s.Expect.Status
+	if wantStatus == 0 {
+		wantStatus = http.StatusOK
+	}
+	for i, n := range s.Notifications {
+		code, body := deliver(h, n, i)
+		if code != wantStatus {
+			res.failf("notification %d: status %d, want %d (%s)", i+1, code, wantStatus, strings.TrimSpace(body))
+		}
+	}
+
+	if s.Expect.HistoryID != 0 {
+		got, err := h.History.Load(ctx, mailbox)
+		switch {
+		case err != nil:
+			res.failf("load history: %v", err)
+		case got != s.Expect.HistoryID:
+			res.failf("history ID %d, want %d", got, s.Expect.HistoryID)
+		}
+	}
+	if sent := len(s.Mailbox.Sent()); sent != s.Expect.Sent {
+		res.failf("sent %d email(s), want %d", sent, s.Expect.Sent)
+	}
+	for _, id := range s.Expect.Processed {
+		if msg := s.Mailbox.Message(id); msg == nil || slices.Contains(msg.LabelIds, "UNREAD") {
+			res.failf("message %s was not marked processed", id)
+		}
+	}
+	for _, id := range s.Expect.Unread {
+		if msg := s.Mailbox.Message(id); msg == nil || !slices.Contains(msg.LabelIds, "UNREAD") {
+			res.failf("message %s should still be unread", id)
+		}
+	}
+	return res
+}
+
+// deliver posts n to the handler the way Pub/Sub push would.
+func deliver(h *gmail.Handler, n Notification, i int) (int, string) {
+	data, _ := json.Marshal(n)
+	var push gmail.PubSubMessage
+	push.Message.Data = base64.StdEncoding.EncodeToString(data)
+	push.Message.MessageID = fmt.Sprintf("harness-%d-%d", time.Now().UnixNano(), i)
+	push.DeliveryAttempt = 1
+	body, _ := json.Marshal(push)
+
+	req := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	if err := h.PubSubHandler(rec, req); err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	return rec.Code, rec.Body.String()
+}
+
+func (s *Scenario) transcribe(ctx context.Context, audioPath, mimeType string) (*transcriber.Result, error) {
+	// Attachments are saved as <message>-<part>-<filename>.
+	for name, text := range s.Transcripts {
+		if strings.HasSuffix(filepath.Base(audioPath), "-"+name) {
+			return &transcriber.Result{Transcript: text, Duration: 5 * time.Second, Confidence: 0.9, Provider: "fixture"}, nil
+		}
+	}
+	return nil, fmt.Errorf("no fixture transcript for %s", filepath.Base(audioPath))
+}
@@ -0,0 +1,57 @@
+package harness
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+// TestScenarios runs every fixture in testdata/harness. It needs the
+// Firestore emulator:
+//
+//	gcloud emulators firestore start --host-port=localhost:8081
+//	FIRESTORE_EMULATOR_HOST=localhost:8081 go test ./internal/harness
+func TestScenarios(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	logger.Init()
+
+	paths, err := filepath.Glob("../../testdata/harness/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	ctx := context.Background()
+	client, err := NewClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if err := email.LoadTemplates(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		s, err := Load(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		t.Run(s.Name, func(t *testing.T) {
+			for k, v := range s.Environment() {
+				t.Setenv(k, v)
+			}
+			res := Run(ctx, client, s)
+			for _, f := range res.Failures {
+				t.Error(f)
+			}
+		})
+	}
+}
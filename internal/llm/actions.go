@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// ActionItemsEnabled reads ACTION_ITEMS_ENABLED.
+func ActionItemsEnabled() bool {
+	return strings.EqualFold(os.Getenv("ACTION_ITEMS_ENABLED"), "true")
+}
+
+const actionItemsPrompt = `You read voicemail transcripts left for a business and list what the caller wants done.
+Reply with a JSON object {"actionItems": [...]} holding short imperative strings, such as
+"Move Tuesday's appointment to Thursday", "Refund the deposit" or "Call back by 5pm".
+Include deadlines and names the caller gives. Use an empty list when the caller asks for nothing.`
+
+// ActionItems extracts the caller's requests from a transcript.
+func ActionItems(ctx context.Context, transcript string) ([]string, error) {
+	var out struct {
+		ActionItems []string `json:"actionItems"`
+	}
+	if err := CompleteJSON(ctx, actionItemsPrompt, transcript, &out); err != nil {
+		return nil, err
+	}
+
+	items := out.ActionItems[:0]
+	for _, item := range out.ActionItems {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,125 @@
+// Package llm calls a chat completion model to analyse transcripts. It speaks
+// the OpenAI chat completions API, which most hosted and self-hosted models
+// also accept.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/retry"
+	"voicemail-transcriber-production/internal/secret"
+)
+
+// APIKeySecret holds the API key sent as a bearer token.
+const APIKeySecret = "llm-api-key"
+
+var httpClient = &http.Client{Timeout: 60 * time.Second, Transport: &requestid.Transport{}}
+
+// baseURL reads LLM_BASE_URL, defaulting to the OpenAI API.
+func baseURL() string {
+	if u := os.Getenv("LLM_BASE_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "https://api.openai.com/v1"
+}
+
+// model reads LLM_MODEL, defaulting to gpt-4o-mini.
+func model() string {
+	if m := os.Getenv("LLM_MODEL"); m != "" {
+		return m
+	}
+	return "gpt-4o-mini"
+}
+
+// retryPolicy reads LLM_MAX_RETRIES, LLM_RETRY_BASE_DELAY and
+// LLM_RETRY_MAX_DELAY.
+func retryPolicy() retry.Policy {
+	return retry.FromEnv("LLM", retry.Policy{Retries: 2, BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+}
+
+// CompleteJSON sends the system prompt and user content to the model, asking
+// for a JSON object, and decodes the reply into out.
+func CompleteJSON(ctx context.Context, system, user string, out interface{}) error {
+	apiKey, err := secret.LoadSecret(ctx, APIKeySecret)
+	if err != nil {
+		return fmt.Errorf("failed to load LLM API key: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"model": model(),
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+		"response_format": map[string]string{"type": "json_object"},
+		"temperature":     0,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode LLM request: %w", err)
+	}
+
+	var body []byte
+	err = retry.Do(ctx, "LLM request", retryPolicy(), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL()+"/chat/completions", bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create LLM request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(apiKey)))
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("LLM request failed: %w", err)
+			}
+			return retry.Temporary(fmt.Errorf("LLM request failed: %w", err), 0)
+		}
+		defer resp.Body.Close()
+
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return retry.Temporary(fmt.Errorf("failed to read LLM response: %w", err), 0)
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			secret.Invalidate(APIKeySecret)
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("LLM request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+			if retry.StatusTemporary(resp.StatusCode) {
+				return retry.Temporary(err, retry.RetryAfter(resp.Header))
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return fmt.Errorf("LLM returned no choices")
+	}
+	content := completion.Choices[0].Message.Content
+	if err := json.Unmarshal([]byte(content), out); err != nil {
+		return fmt.Errorf("LLM returned invalid JSON %q: %w", content, err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+const sentimentPrompt = `You rate the caller's mood in voicemail transcripts left for a business.
+Reply with a JSON object {"sentiment": "positive" | "neutral" | "negative", "score": number}
+where score runs from -1 (angry or very unhappy) to 1 (very happy).`
+
+// Sentiment scores the caller's mood in a transcript.
+func Sentiment(ctx context.Context, transcript string) (label string, score float64, err error) {
+	var out struct {
+		Sentiment string  `json:"sentiment"`
+		Score     float64 `json:"score"`
+	}
+	if err := CompleteJSON(ctx, sentimentPrompt, transcript, &out); err != nil {
+		return "", 0, err
+	}
+
+	score = max(-1, min(1, out.Score))
+	label = strings.ToLower(strings.TrimSpace(out.Sentiment))
+	if label != "positive" && label != "neutral" && label != "negative" {
+		label = voicemail.SentimentLabel(score)
+	}
+	return label, score, nil
+}
@@ -1,9 +1,14 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/requestid"
 )
 
 var (
@@ -11,13 +16,137 @@ var (
 	Error *log.Logger
 	Debug *log.Logger
 	Warn  *log.Logger
+
+	// Log is the structured logger the levelled loggers above write through.
+	// Use it directly to attach fields such as message_id or duration_ms.
+	Log *slog.Logger
+
+	level = new(slog.LevelVar)
 )
 
+// Init sets up logging. Output is JSON when LOG_FORMAT=json or when running
+// on Cloud Run, and plain text otherwise.
 func Init() {
-	Info = log.New(os.Stdout, "ℹ️  [INFO]  ", log.Ldate|log.Ltime|log.Lshortfile)
-	Error = log.New(os.Stderr, "❌ [ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Debug = log.New(os.Stdout, "🐛 [DEBUG] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Warn = log.New(os.Stdout, "⚠️  [WARN]  ", log.LstdFlags|log.Lshortfile)
+	if err := SetLevel(os.Getenv("LOG_LEVEL")); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid LOG_LEVEL: %v, using info\n", err)
+	}
+
+	h := newHandler(os.Stdout)
+	Log = slog.New(h)
+	slog.SetDefault(Log)
+
+	Info = slog.NewLogLogger(h, slog.LevelInfo)
+	Error = slog.NewLogLogger(h, slog.LevelError)
+	Debug = slog.NewLogLogger(h, slog.LevelDebug)
+	Warn = slog.NewLogLogger(h, slog.LevelWarn)
+}
+
+// SetLevel sets the minimum level logged: debug, info (the default for an
+// empty name), warn or error.
+func SetLevel(name string) error {
+	if name == "" {
+		level.Set(slog.LevelInfo)
+		return nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		level.Set(slog.LevelInfo)
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// DebugEnabled reports whether debug logs are being written.
+func DebugEnabled() bool {
+	return level.Level() <= slog.LevelDebug
+}
+
+func newHandler(w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, AddSource: true, ReplaceAttr: redactAttr}
+
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	if format == "" && os.Getenv("K_SERVICE") != "" {
+		format = "json"
+	}
+	if format == "json" {
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			return cloudLoggingAttr(groups, redactAttr(groups, a))
+		}
+		h := slog.NewJSONHandler(w, opts).WithAttrs([]slog.Attr{
+			slog.Any("logging.googleapis.com/labels", labels()),
+		})
+		return contextHandler{Handler: h, cloud: true}
+	}
+	return contextHandler{Handler: slog.NewTextHandler(w, opts)}
+}
+
+// cloudLoggingAttr renames the standard slog fields to the ones Cloud Logging
+// recognises in structured logs, so severity filters and source links work.
+func cloudLoggingAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		return slog.String("severity", severity(a.Value.Any().(slog.Level)))
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.SourceKey:
+		a.Key = "logging.googleapis.com/sourceLocation"
+	}
+	return a
+}
+
+func severity(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "ERROR"
+	case l >= slog.LevelWarn:
+		return "WARNING"
+	case l >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// labels are attached to every entry: the Cloud Run service and build.
+func labels() map[string]string {
+	service := os.Getenv("K_SERVICE")
+	if service == "" {
+		service = "voicemail-transcriber"
+	}
+	l := map[string]string{"service": service}
+	if v := os.Getenv("BUILD_VERSION"); v != "" {
+		l["version"] = v
+	}
+	return l
+}
+
+// contextHandler adds the request ID carried by the context to each record.
+// For Cloud Logging it also links the entry to the request's trace.
+type contextHandler struct {
+	slog.Handler
+	cloud bool
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+		if project := os.Getenv("GCP_PROJECT_ID"); h.cloud && project != "" && requestid.IsTrace(id) {
+			r.AddAttrs(slog.String("logging.googleapis.com/trace", "projects/"+project+"/traces/"+id))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs), cloud: h.cloud}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name), cloud: h.cloud}
 }
 
 func PrintEnvSummary() {
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// phonePattern matches numbers of ten or more digits starting with 0 or
+	// +, which leaves Gmail history IDs alone.
+	phonePattern = regexp.MustCompile(`(?:\+|\b0)\d[\d \-]{8,14}\d`)
+)
+
+// transcriptPreview is how much of a transcript is logged outside debug mode.
+const transcriptPreview = 30
+
+// Redact masks email addresses and phone numbers in s, unless debug logging
+// is enabled.
+func Redact(s string) string {
+	if DebugEnabled() {
+		return s
+	}
+	s = emailPattern.ReplaceAllStringFunc(s, maskEmail)
+	return phonePattern.ReplaceAllStringFunc(s, maskPhone)
+}
+
+// Transcript shortens transcript text for logging, unless debug logging is
+// enabled.
+func Transcript(s string) string {
+	if DebugEnabled() {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= transcriptPreview {
+		return Redact(s)
+	}
+	return Redact(string(runes[:transcriptPreview])) + "… (" + strconv.Itoa(len(runes)) + " chars)"
+}
+
+// redactAttr masks personal data in string values before they are written.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+	if a.Key == "transcript" {
+		return slog.String(a.Key, Transcript(a.Value.String()))
+	}
+	return slog.String(a.Key, Redact(a.Value.String()))
+}
+
+// maskEmail keeps the first character of the local part and the domain:
+// "jane@example.com" becomes "j***@example.com".
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPhone keeps only the last three digits.
+func maskPhone(number string) string {
+	digits := 0
+	for _, c := range number {
+		if '0' <= c && c <= '9' {
+			digits++
+		}
+	}
+	var b strings.Builder
+	seen := 0
+	for _, c := range number {
+		if '0' <= c && c <= '9' {
+			seen++
+			if seen <= digits-3 {
+				b.WriteByte('*')
+				continue
+			}
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
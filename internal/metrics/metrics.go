@@ -0,0 +1,140 @@
+// Package metrics keeps in-process latency histograms and serves them in the
+// Prometheus text format, so a scraper or the Cloud Monitoring managed
+// collector can chart where processing time goes.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are upper bounds in seconds, spanning quick Gmail calls to
+// slow transcriptions of long recordings.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// StageDuration times each pipeline stage: "download" of an attachment from
+// Gmail, "transcribe" by the provider, "deliver" per notification channel,
+// and "process" for a whole message.
+var StageDuration = NewHistogram("voicemail_stage_duration_seconds",
+	"Time spent in each voicemail processing stage.", []string{"stage", "channel"}, DefaultBuckets)
+
+var (
+	registryLock sync.Mutex
+	registry     []*Histogram
+)
+
+// Histogram counts observations into cumulative buckets per combination of
+// label values.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates and registers a histogram.
+func NewHistogram(name, help string, labels []string, buckets []float64) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*series{}}
+	registryLock.Lock()
+	registry = append(registry, h)
+	registryLock.Unlock()
+	return h
+}
+
+// Observe records d against the given label values, which must match the
+// histogram's labels in number and order.
+func (h *Histogram) Observe(d time.Duration, labelValues ...string) {
+	v := d.Seconds()
+	key := strings.Join(labelValues, "\x00")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &series{labels: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Since observes the time elapsed since start.
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start), labelValues...)
+}
+
+// Handler serves every registered histogram in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registryLock.Lock()
+		histograms := append([]*Histogram(nil), registry...)
+		registryLock.Unlock()
+		for _, h := range histograms {
+			h.write(w)
+		}
+	})
+}
+
+func (h *Histogram) write(w http.ResponseWriter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		labels := h.labelPairs(s.labels)
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, join(labels, `le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"`), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, join(labels, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", h.name, labels, s.sum)
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, labels, s.count)
+	}
+}
+
+func (h *Histogram) labelPairs(values []string) string {
+	pairs := make([]string, 0, len(h.labels))
+	for i, name := range h.labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+"="+strconv.Quote(value))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func join(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+)
+
+// opsRecipients reads OPS_ALERT_EMAIL (comma-separated), the operations
+// addresses alerts are emailed to. They are kept apart from the
+// transcription recipients, who can't act on a failing pipeline.
+func opsRecipients() []string {
+	return email.ParseList(os.Getenv("OPS_ALERT_EMAIL"))
+}
+
+// opsSlack reads OPS_ALERT_SLACK: when true, alerts are also posted to the
+// incoming webhook in the ops-slack-webhook-url secret.
+func opsSlack() bool {
+	return strings.EqualFold(os.Getenv("OPS_ALERT_SLACK"), "true")
+}
+
+// AlertsEnabled reports whether any ops alert destination is configured.
+func AlertsEnabled() bool {
+	return len(opsRecipients()) > 0 || opsSlack()
+}
+
+// Alert sends an operational alert to OPS_ALERT_EMAIL through sender and,
+// with OPS_ALERT_SLACK set, to the ops Slack webhook. It does nothing when
+// neither is configured.
+func Alert(ctx context.Context, sender email.Sender, subject, text string) error {
+	var errs []error
+	if to := opsRecipients(); len(to) > 0 && sender != nil {
+		if err := email.SendText(ctx, sender, to, subject, text); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if opsSlack() {
+		webhookURL, err := secret.LoadSecret(ctx, "ops-slack-webhook-url")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load ops Slack webhook URL: %w", err))
+		} else if err := postSlack(ctx, strings.TrimSpace(string(webhookURL)), "", map[string]interface{}{
+			"text": "*" + subject + "*\n" + text,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %w", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to send alert %q: %w", subject, err)
+	}
+	if AlertsEnabled() {
+		logger.Info.Printf("🚨 Sent ops alert: %s", subject)
+	}
+	return nil
+}
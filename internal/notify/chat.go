@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+// sendChat posts the transcription as a card to the Google Chat space whose
+// incoming webhook URL is in the google-chat-webhook-url secret. Messages
+// for the same voicemail share a thread.
+func sendChat(ctx context.Context, vm *voicemail.Voicemail) error {
+	webhookURL, err := secret.LoadSecret(ctx, "google-chat-webhook-url")
+	if err != nil {
+		return fmt.Errorf("failed to load Google Chat webhook URL: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(string(webhookURL)))
+	if err != nil {
+		return fmt.Errorf("invalid Google Chat webhook URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+	u.RawQuery = q.Encode()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"text":    title(vm),
+		"thread":  map[string]string{"threadKey": "voicemail-" + vm.MessageID},
+		"cardsV2": []map[string]interface{}{{"cardId": "voicemail", "card": chatCard(vm)}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Google Chat payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Google Chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Google Chat request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Google Chat returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	logger.Info.Printf("💬 Posted transcription for message %s to Google Chat", vm.MessageID)
+	return nil
+}
+
+func chatCard(vm *voicemail.Voicemail) map[string]interface{} {
+	details := []map[string]interface{}{
+		{"decoratedText": map[string]interface{}{"topLabel": "Caller", "text": callerName(vm)}},
+	}
+	if !vm.ReceivedAt.IsZero() {
+		details = append(details, map[string]interface{}{
+			"decoratedText": map[string]interface{}{"topLabel": "Received", "text": vm.ReceivedAt.Format("Mon 2 Jan 2006, 15:04")},
+		})
+	}
+	if total := vm.TotalDuration(); total > 0 {
+		details = append(details, map[string]interface{}{
+			"decoratedText": map[string]interface{}{"topLabel": "Length", "text": voicemail.FormatDuration(total)},
+		})
+	}
+
+	sections := []map[string]interface{}{
+		{"widgets": details},
+		{"header": "Transcript", "widgets": []map[string]interface{}{
+			{"textParagraph": map[string]interface{}{"text": truncate(chatEscape(vm.Transcript()), 4000)}},
+		}},
+	}
+	if len(vm.ActionItems) > 0 {
+		sections = append(sections, map[string]interface{}{
+			"header": "Action items",
+			"widgets": []map[string]interface{}{
+				{"textParagraph": map[string]interface{}{"text": chatEscape("• " + strings.Join(vm.ActionItems, "\n• "))}},
+			},
+		})
+	}
+	if link := vm.Link(); link != "" {
+		sections = append(sections, map[string]interface{}{
+			"widgets": []map[string]interface{}{
+				{"buttonList": map[string]interface{}{"buttons": []map[string]interface{}{
+					{"text": "Listen to voicemail", "onClick": map[string]interface{}{"openLink": map[string]string{"url": link}}},
+				}}},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"header":   map[string]interface{}{"title": title(vm), "subtitle": vm.Subject},
+		"sections": sections,
+	}
+}
+
+// chatEscape escapes text for card widgets, which render simple HTML.
+func chatEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n", "<br>").Replace(s)
+}
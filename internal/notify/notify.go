@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/metrics"
+	"voicemail-transcriber-production/internal/tenant"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+const (
+	ChannelEmail   = "email"
+	ChannelSlack   = "slack"
+	ChannelTeams   = "teams"
+	ChannelWebhook = "webhook"
+	// ChannelSalesforce logs the voicemail as a Salesforce call task.
+	ChannelSalesforce = "salesforce"
+	// ChannelTasks adds a follow-up to the mailbox owner's Google Tasks.
+	ChannelTasks = "tasks"
+	// ChannelChat posts a card to a Google Chat space.
+	ChannelChat = "chat"
+)
+
+// DefaultChannels returns the tenant config's channels or NOTIFY_CHANNELS,
+// the comma-separated list of delivery channels used when a routing rule
+// doesn't pick its own.
+func DefaultChannels() []string {
+	if channels := tenant.Current().Channels; len(channels) > 0 {
+		return channels
+	}
+	channels := email.ParseList(strings.ToLower(os.Getenv("NOTIFY_CHANNELS")))
+	if len(channels) == 0 {
+		return []string{ChannelEmail}
+	}
+	return channels
+}
+
+// SMSKey is the Tracker key of the urgent SMS alert.
+const SMSKey = "sms"
+
+// Tracker remembers which channels a voicemail has already been delivered
+// to, keyed by channel name or SMSKey, so a retry after a partial failure
+// only repeats the channels that failed.
+type Tracker interface {
+	Sent(key string) bool
+	MarkSent(key string)
+}
+
+// Deliver sends the transcription to every channel, attempting all of them
+// even if one fails. An urgent voicemail also triggers an SMS alert, whose
+// failure is logged without failing the delivery. Channels tracker has seen
+// succeed are skipped; tracker may be nil.
+func Deliver(ctx context.Context, sender email.Sender, vm *voicemail.Voicemail, rcpt email.Recipients, channels []string, tracker Tracker) error {
+	sent := func(key string) bool { return tracker != nil && tracker.Sent(key) }
+	markSent := func(key string) {
+		if tracker != nil {
+			tracker.MarkSent(key)
+		}
+	}
+
+	if vm.Urgent() && !sent(SMSKey) {
+		if err := sendUrgentSMS(ctx, vm); err != nil {
+			logger.Error.Printf("❌ Failed to send urgent SMS for message %s: %v", vm.MessageID, err)
+		} else {
+			markSent(SMSKey)
+		}
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		if sent(channel) {
+			logger.Info.Printf("⏭️ Message %s already delivered via %s", vm.MessageID, channel)
+			continue
+		}
+		started := time.Now()
+		var err error
+		switch channel {
+		case ChannelEmail:
+			err = email.SendTranscription(ctx, sender, vm, rcpt)
+		case ChannelSlack:
+			err = sendSlack(ctx, vm)
+		case ChannelTeams:
+			err = sendTeams(ctx, vm)
+		case ChannelWebhook:
+			err = sendWebhook(ctx, vm)
+		case ChannelSalesforce:
+			err = sendSalesforce(ctx, vm)
+		case ChannelTasks:
+			err = sendTasks(ctx, vm)
+		case ChannelChat:
+			err = sendChat(ctx, vm)
+		default:
+			err = fmt.Errorf("unknown notification channel %q", channel)
+		}
+		metrics.StageDuration.Since(started, "deliver", channel)
+		if err != nil {
+			logger.Error.Printf("❌ Failed to deliver message %s via %s: %v", vm.MessageID, channel, err)
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+			continue
+		}
+		markSent(channel)
+	}
+	return errors.Join(errs...)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+// salesforceAPIVersion reads SALESFORCE_API_VERSION, defaulting to v60.0.
+func salesforceAPIVersion() string {
+	if v := os.Getenv("SALESFORCE_API_VERSION"); v != "" {
+		return v
+	}
+	return "v60.0"
+}
+
+// salesforceToken is the cached access token. Client credentials tokens
+// carry no expiry, so one is kept until Salesforce rejects it.
+var (
+	salesforceLock  sync.Mutex
+	salesforceToken string
+)
+
+// salesforceAuth returns an access token from the client credentials flow of
+// the connected app in the salesforce-client-id and salesforce-client-secret
+// secrets, against SALESFORCE_INSTANCE_URL (the org's My Domain URL).
+func salesforceAuth(ctx context.Context) (string, error) {
+	salesforceLock.Lock()
+	defer salesforceLock.Unlock()
+	if salesforceToken != "" {
+		return salesforceToken, nil
+	}
+
+	instance := os.Getenv("SALESFORCE_INSTANCE_URL")
+	if instance == "" {
+		return "", fmt.Errorf("SALESFORCE_INSTANCE_URL must be set")
+	}
+	clientID, err := secret.LoadSecret(ctx, "salesforce-client-id")
+	if err != nil {
+		return "", fmt.Errorf("failed to load Salesforce client ID: %w", err)
+	}
+	clientSecret, err := secret.LoadSecret(ctx, "salesforce-client-secret")
+	if err != nil {
+		return "", fmt.Errorf("failed to load Salesforce client secret: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {strings.TrimSpace(string(clientID))},
+		"client_secret": {strings.TrimSpace(string(clientSecret))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(instance, "/")+"/services/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Salesforce token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doSalesforce(req, &token); err != nil {
+		return "", fmt.Errorf("failed to get Salesforce token: %w", err)
+	}
+	salesforceToken = token.AccessToken
+	return salesforceToken, nil
+}
+
+// salesforceCall makes a REST API request, fetching a new token once if the
+// cached one has expired.
+func salesforceCall(ctx context.Context, method, path string, in, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode Salesforce request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := salesforceAuth(ctx)
+		if err != nil {
+			return err
+		}
+		u := strings.TrimRight(os.Getenv("SALESFORCE_INSTANCE_URL"), "/") + "/services/data/" + salesforceAPIVersion() + path
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create Salesforce request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		err = doSalesforce(req, out)
+		if errors.Is(err, errSalesforceUnauthorized) && attempt == 0 {
+			salesforceLock.Lock()
+			salesforceToken = ""
+			salesforceLock.Unlock()
+			continue
+		}
+		return err
+	}
+}
+
+var errSalesforceUnauthorized = errors.New("Salesforce rejected the access token")
+
+func doSalesforce(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Salesforce request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errSalesforceUnauthorized
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Salesforce returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// salesforceMatch finds the Contact, or failing that the Lead, whose phone
+// numbers match caller, returning "" when there is none.
+func salesforceMatch(ctx context.Context, caller string) (string, error) {
+	// SOSL reserves these characters; a normalized number only has digits
+	// and a leading +, but keep the query safe regardless.
+	number := strings.NewReplacer(`\`, `\\`, `{`, `\{`, `}`, `\}`).Replace(caller)
+	q := url.Values{"q": {"FIND {" + number + "} IN PHONE FIELDS RETURNING Contact(Id), Lead(Id WHERE IsConverted = false) LIMIT 1"}}
+
+	var result struct {
+		SearchRecords []struct {
+			ID         string `json:"Id"`
+			Attributes struct {
+				Type string `json:"type"`
+			} `json:"attributes"`
+		} `json:"searchRecords"`
+	}
+	if err := salesforceCall(ctx, http.MethodGet, "/search/?"+q.Encode(), nil, &result); err != nil {
+		return "", err
+	}
+	for _, kind := range []string{"Contact", "Lead"} {
+		for _, r := range result.SearchRecords {
+			if r.Attributes.Type == kind {
+				return r.ID, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// sendSalesforce logs the voicemail as an open inbound call Task, linked to
+// the matching Contact or Lead and assigned to SALESFORCE_OWNER_ID when set,
+// so the sales team sees the missed call in the CRM.
+func sendSalesforce(ctx context.Context, vm *voicemail.Voicemail) error {
+	task := map[string]interface{}{
+		"Subject":      truncate("Voicemail from "+callerName(vm), 255),
+		"Description":  truncate(salesforceDescription(vm), 32000),
+		"TaskSubtype":  "Call",
+		"CallType":     "Inbound",
+		"Status":       "Not Started",
+		"Priority":     "Normal",
+		"ActivityDate": time.Now().Format("2006-01-02"),
+	}
+	if vm.Urgent() {
+		task["Priority"] = "High"
+	}
+	if owner := os.Getenv("SALESFORCE_OWNER_ID"); owner != "" {
+		task["OwnerId"] = owner
+	}
+	if vm.Caller != "" && vm.Caller != voicemail.Withheld {
+		whoID, err := salesforceMatch(ctx, vm.Caller)
+		if err != nil {
+			logger.Warn.Printf("⚠️ Could not match caller %s in Salesforce: %v", vm.Caller, err)
+		} else if whoID != "" {
+			task["WhoId"] = whoID
+		}
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := salesforceCall(ctx, http.MethodPost, "/sobjects/Task/", task, &created); err != nil {
+		return err
+	}
+	logger.Info.Printf("☁️ Logged message %s as Salesforce task %s", vm.MessageID, created.ID)
+	return nil
+}
+
+func salesforceDescription(vm *voicemail.Voicemail) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Caller: %s\n", callerName(vm))
+	if !vm.ReceivedAt.IsZero() {
+		fmt.Fprintf(&b, "Received: %s\n", vm.ReceivedAt.Format("Mon 2 Jan 2006, 15:04"))
+	}
+	if link := vm.Link(); link != "" {
+		fmt.Fprintf(&b, "Recording: %s\n", link)
+	}
+	b.WriteString("\n" + vm.Transcript() + "\n")
+	if len(vm.ActionItems) > 0 {
+		b.WriteString("\nAction items:\n- " + strings.Join(vm.ActionItems, "\n- ") + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// sendSlack posts the transcription to Slack. With SLACK_CHANNEL set it uses
+// the slack-bot-token secret and chat.postMessage; otherwise it posts to the
+// incoming webhook in the slack-webhook-url secret.
+func sendSlack(ctx context.Context, vm *voicemail.Voicemail) error {
+	payload := map[string]interface{}{
+		"text":   fmt.Sprintf("Voicemail from %s", callerName(vm)),
+		"blocks": slackBlocks(vm),
+	}
+
+	channel := os.Getenv("SLACK_CHANNEL")
+	if channel == "" {
+		webhookURL, err := secret.LoadSecret(ctx, "slack-webhook-url")
+		if err != nil {
+			return fmt.Errorf("failed to load Slack webhook URL: %w", err)
+		}
+		if err := postSlack(ctx, strings.TrimSpace(string(webhookURL)), "", payload); err != nil {
+			return err
+		}
+		logger.Info.Printf("💬 Posted transcription for message %s to Slack webhook", vm.MessageID)
+		return nil
+	}
+
+	token, err := secret.LoadSecret(ctx, "slack-bot-token")
+	if err != nil {
+		return fmt.Errorf("failed to load Slack bot token: %w", err)
+	}
+	payload["channel"] = channel
+	if err := postSlack(ctx, slackPostMessageURL, strings.TrimSpace(string(token)), payload); err != nil {
+		return err
+	}
+	logger.Info.Printf("💬 Posted transcription for message %s to Slack channel %s", vm.MessageID, channel)
+	return nil
+}
+
+func postSlack(ctx context.Context, url, token string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Slack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	// chat.postMessage reports failures in the body with a 200 status.
+	if token != "" {
+		var result struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &result); err == nil && !result.OK {
+			return fmt.Errorf("Slack API error: %s", result.Error)
+		}
+	}
+	return nil
+}
+
+func slackBlocks(vm *voicemail.Voicemail) []map[string]interface{} {
+	fields := []map[string]interface{}{
+		{"type": "mrkdwn", "text": fmt.Sprintf("*Caller*\n%s", callerName(vm))},
+	}
+	if !vm.ReceivedAt.IsZero() {
+		fields = append(fields, map[string]interface{}{
+			"type": "mrkdwn", "text": fmt.Sprintf("*Received*\n%s", vm.ReceivedAt.Format("Mon 2 Jan 2006, 15:04")),
+		})
+	}
+	if total := vm.TotalDuration(); total > 0 {
+		fields = append(fields, map[string]interface{}{
+			"type": "mrkdwn", "text": fmt.Sprintf("*Length*\n%s", voicemail.FormatDuration(total)),
+		})
+	}
+
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": truncate(title(vm), 150)},
+		},
+		{"type": "section", "fields": fields},
+		{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": truncate(">"+strings.ReplaceAll(vm.Transcript(), "\n", "\n>"), 2900)},
+		},
+	}
+
+	if len(vm.ActionItems) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": truncate("*Action items*\n• "+strings.Join(vm.ActionItems, "\n• "), 2900)},
+		})
+	}
+
+	if link := vm.Link(); link != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{
+				{
+					"type": "button",
+					"text": map[string]interface{}{"type": "plain_text", "text": "Listen to voicemail"},
+					"url":  link,
+				},
+			},
+		})
+	}
+	return blocks
+}
+
+// title heads chat messages, flagging urgent and negative voicemails.
+func title(vm *voicemail.Voicemail) string {
+	if vm.Urgent() {
+		return "🚨 Urgent voicemail from " + callerName(vm)
+	}
+	if vm.Negative() {
+		return "😠 Unhappy voicemail from " + callerName(vm)
+	}
+	return "📞 Voicemail from " + callerName(vm)
+}
+
+func callerName(vm *voicemail.Voicemail) string {
+	caller := vm.Caller
+	if caller == "" {
+		caller = "Unknown caller"
+	}
+	if vm.Contact != nil && vm.Contact.Name != "" {
+		return vm.Contact.Name + " (" + caller + ")"
+	}
+	return caller
+}
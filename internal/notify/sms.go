@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+// urgentSMSRecipients reads URGENT_SMS_TO, the comma-separated numbers
+// texted when an urgent voicemail arrives. Unset, no SMS is sent.
+func urgentSMSRecipients() []string {
+	return email.ParseList(os.Getenv("URGENT_SMS_TO"))
+}
+
+// sendUrgentSMS texts a short alert about an urgent voicemail to every
+// URGENT_SMS_TO number through Twilio, using the twilio-account-sid and
+// twilio-auth-token secrets and the TWILIO_FROM_NUMBER sender.
+func sendUrgentSMS(ctx context.Context, vm *voicemail.Voicemail) error {
+	recipients := urgentSMSRecipients()
+	if len(recipients) == 0 {
+		return nil
+	}
+	from := os.Getenv("TWILIO_FROM_NUMBER")
+	if from == "" {
+		return fmt.Errorf("TWILIO_FROM_NUMBER must be set to send urgent SMS alerts")
+	}
+	sid, err := secret.LoadSecret(ctx, "twilio-account-sid")
+	if err != nil {
+		return fmt.Errorf("failed to load Twilio account SID: %w", err)
+	}
+	token, err := secret.LoadSecret(ctx, "twilio-auth-token")
+	if err != nil {
+		return fmt.Errorf("failed to load Twilio auth token: %w", err)
+	}
+	accountSID := strings.TrimSpace(string(sid))
+
+	body := truncate(fmt.Sprintf("URGENT voicemail from %s: %s", callerName(vm), vm.Transcript()), 320)
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(accountSID) + "/Messages.json"
+	for _, to := range recipients {
+		form := url.Values{"To": {to}, "From": {from}, "Body": {body}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return fmt.Errorf("failed to create Twilio request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(accountSID, strings.TrimSpace(string(token)))
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("Twilio request failed: %w", err)
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("Twilio returned status %d: %s", resp.StatusCode, string(respBody))
+		}
+	}
+
+	logger.Info.Printf("📱 Sent urgent SMS alert for message %s to %d number(s)", vm.MessageID, len(recipients))
+	return nil
+}
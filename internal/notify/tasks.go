@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	gtasks "google.golang.org/api/tasks/v1"
+)
+
+var (
+	tasksServices = map[string]*gtasks.Service{}
+	tasksLock     sync.Mutex
+)
+
+// tasksList reads TASKS_LIST, the task list ID follow-ups are added to,
+// defaulting to the account's default list.
+func tasksList() string {
+	if l := os.Getenv("TASKS_LIST"); l != "" {
+		return l
+	}
+	return "@default"
+}
+
+// tasksService returns the Tasks service acting as account, creating it on
+// first use.
+func tasksService(ctx context.Context, account string) (*gtasks.Service, error) {
+	tasksLock.Lock()
+	defer tasksLock.Unlock()
+	if srv, ok := tasksServices[account]; ok {
+		return srv, nil
+	}
+
+	// The service is cached beyond this delivery.
+	ctx = context.WithoutCancel(ctx)
+	creds, err := auth.DelegatedOption(ctx, account, gtasks.TasksScope)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := gtasks.NewService(ctx, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Tasks service: %w", err)
+	}
+	tasksServices[account] = srv
+	return srv, nil
+}
+
+// sendTasks adds a follow-up to-do for the voicemail to the Google Tasks of
+// the mailbox it arrived in, due today.
+func sendTasks(ctx context.Context, vm *voicemail.Voicemail) error {
+	if vm.Account == "" {
+		return fmt.Errorf("no account to create the task for")
+	}
+	srv, err := tasksService(ctx, vm.Account)
+	if err != nil {
+		return err
+	}
+
+	title := "Call back " + callerName(vm)
+	if vm.Caller == "" || vm.Caller == voicemail.Withheld {
+		title = "Voicemail from " + callerName(vm)
+	}
+	if vm.Urgent() {
+		title = "URGENT: " + title
+	}
+	title += " — voicemail: " + truncate(strings.Join(strings.Fields(vm.Transcript()), " "), 80)
+
+	notes := vm.Transcript()
+	if len(vm.ActionItems) > 0 {
+		notes += "\n\nAction items:\n- " + strings.Join(vm.ActionItems, "\n- ")
+	}
+	if link := vm.Link(); link != "" {
+		notes += "\n\n" + link
+	}
+
+	// Tasks only keeps the date part of the due time.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	task, err := srv.Tasks.Insert(tasksList(), &gtasks.Task{
+		Title: title,
+		Notes: truncate(notes, 8000),
+		Due:   today.Format(time.RFC3339),
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	logger.Info.Printf("✅ Created Google Task %s for message %s in %s", task.Id, vm.MessageID, vm.Account)
+	return nil
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+// sendTeams posts the transcription as an adaptive card to the Teams
+// incoming webhook (or Workflows URL) in the teams-webhook-url secret.
+func sendTeams(ctx context.Context, vm *voicemail.Voicemail) error {
+	webhookURL, err := secret.LoadSecret(ctx, "teams-webhook-url")
+	if err != nil {
+		return fmt.Errorf("failed to load Teams webhook URL: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content":     teamsCard(vm),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Teams payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(string(webhookURL)), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Teams request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Teams request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Teams returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	logger.Info.Printf("💬 Posted transcription for message %s to Teams", vm.MessageID)
+	return nil
+}
+
+func teamsCard(vm *voicemail.Voicemail) map[string]interface{} {
+	facts := []map[string]string{
+		{"title": "Caller", "value": callerName(vm)},
+	}
+	if !vm.ReceivedAt.IsZero() {
+		facts = append(facts, map[string]string{"title": "Received", "value": vm.ReceivedAt.Format("Mon 2 Jan 2006, 15:04")})
+	}
+	if total := vm.TotalDuration(); total > 0 {
+		facts = append(facts, map[string]string{"title": "Length", "value": voicemail.FormatDuration(total)})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []map[string]interface{}{
+			{"type": "TextBlock", "size": "Medium", "weight": "Bolder", "text": title(vm)},
+			{"type": "FactSet", "facts": facts},
+			{"type": "TextBlock", "wrap": true, "text": truncate(vm.Transcript(), 10000)},
+		},
+	}
+
+	if link := vm.Link(); link != "" {
+		card["actions"] = []map[string]interface{}{
+			{"type": "Action.OpenUrl", "title": "Listen to voicemail", "url": link},
+		}
+	}
+	return card
+}
@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/secret"
+	"voicemail-transcriber-production/internal/voicemail"
+)
+
+const (
+	SignatureHeader = "X-Voicemail-Signature"
+	TimestampHeader = "X-Voicemail-Timestamp"
+)
+
+// WebhookPayload is the JSON body POSTed to the webhook channel.
+type WebhookPayload struct {
+	MessageID       string             `json:"messageId"`
+	ThreadID        string             `json:"threadId,omitempty"`
+	Caller          string             `json:"caller,omitempty"`
+	ContactName     string             `json:"contactName,omitempty"`
+	Carrier         string             `json:"carrier,omitempty"`
+	Mailbox         string             `json:"mailbox,omitempty"`
+	Subject         string             `json:"subject"`
+	Transcript      string             `json:"transcript"`
+	AudioURL        string             `json:"audioUrl,omitempty"`
+	ReceivedAt      time.Time          `json:"receivedAt"`
+	SentAt          time.Time          `json:"sentAt"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Recordings      []WebhookRecording `json:"recordings"`
+	Urgent          bool               `json:"urgent"`
+	UrgencyKeywords []string           `json:"urgencyKeywords,omitempty"`
+	ActionItems     []string           `json:"actionItems,omitempty"`
+	Sentiment       string             `json:"sentiment,omitempty"`
+	SentimentScore  float64            `json:"sentimentScore,omitempty"`
+}
+
+type WebhookRecording struct {
+	Filename        string  `json:"filename"`
+	Transcript      string  `json:"transcript"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Language        string  `json:"language,omitempty"`
+	Translation     string  `json:"translation,omitempty"`
+}
+
+func newWebhookPayload(vm *voicemail.Voicemail) WebhookPayload {
+	p := WebhookPayload{
+		MessageID:       vm.MessageID,
+		ThreadID:        vm.ThreadID,
+		Caller:          vm.Caller,
+		Carrier:         vm.Carrier,
+		Mailbox:         vm.Mailbox,
+		Subject:         vm.Subject,
+		Transcript:      vm.Transcript(),
+		AudioURL:        vm.Link(),
+		ReceivedAt:      vm.ReceivedAt,
+		SentAt:          time.Now().UTC(),
+		DurationSeconds: vm.TotalDuration().Seconds(),
+		Urgent:          vm.Urgent(),
+		UrgencyKeywords: vm.UrgencyKeywords,
+		ActionItems:     vm.ActionItems,
+		Sentiment:       vm.Sentiment,
+		SentimentScore:  vm.SentimentScore,
+	}
+	if vm.Contact != nil {
+		p.ContactName = vm.Contact.Name
+	}
+	for _, rec := range vm.Recordings {
+		p.Recordings = append(p.Recordings, WebhookRecording{
+			Filename:        rec.Filename,
+			Transcript:      rec.Transcript,
+			DurationSeconds: rec.Duration.Seconds(),
+			Language:        rec.Language,
+			Translation:     rec.Translation,
+		})
+	}
+	return p
+}
+
+// ZapierPayload is the webhook body with WEBHOOK_FORMAT=zapier: flat fields
+// only, since Zapier catch hooks can't map nested objects or lists into
+// most apps' fields. Lists are joined into text.
+type ZapierPayload struct {
+	MessageID       string  `json:"message_id"`
+	Caller          string  `json:"caller"`
+	ContactName     string  `json:"contact_name"`
+	Subject         string  `json:"subject"`
+	Transcript      string  `json:"transcript"`
+	Translation     string  `json:"translation"`
+	AudioURL        string  `json:"audio_url"`
+	ReceivedAt      string  `json:"received_at"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Duration        string  `json:"duration"`
+	RecordingCount  int     `json:"recording_count"`
+	Urgent          bool    `json:"urgent"`
+	UrgencyKeywords string  `json:"urgency_keywords"`
+	ActionItems     string  `json:"action_items"`
+	Sentiment       string  `json:"sentiment"`
+}
+
+func newZapierPayload(vm *voicemail.Voicemail) ZapierPayload {
+	p := ZapierPayload{
+		MessageID:       vm.MessageID,
+		Caller:          vm.Caller,
+		Subject:         vm.Subject,
+		Transcript:      vm.Transcript(),
+		Translation:     vm.Translations(),
+		AudioURL:        vm.Link(),
+		DurationSeconds: vm.TotalDuration().Seconds(),
+		Duration:        voicemail.FormatDuration(vm.TotalDuration()),
+		RecordingCount:  len(vm.Recordings),
+		Urgent:          vm.Urgent(),
+		UrgencyKeywords: strings.Join(vm.UrgencyKeywords, ", "),
+		ActionItems:     strings.Join(vm.ActionItems, "\n"),
+		Sentiment:       vm.Sentiment,
+	}
+	if vm.Contact != nil {
+		p.ContactName = vm.Contact.Name
+	}
+	if !vm.ReceivedAt.IsZero() {
+		p.ReceivedAt = vm.ReceivedAt.UTC().Format(time.RFC3339)
+	}
+	return p
+}
+
+// webhookFormat reads WEBHOOK_FORMAT: "default" for WebhookPayload or
+// "zapier" for ZapierPayload.
+func webhookFormat() string {
+	switch f := strings.ToLower(strings.TrimSpace(os.Getenv("WEBHOOK_FORMAT"))); f {
+	case "", "default":
+		return "default"
+	case "zapier":
+		return f
+	default:
+		logger.Warn.Printf("⚠️ Invalid WEBHOOK_FORMAT %q, using default", f)
+		return "default"
+	}
+}
+
+// Sign returns the signature header value for body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook POSTs the transcription as JSON, in the WEBHOOK_FORMAT, to the
+// URL in the webhook-url secret, signed with the webhook-signing-secret
+// except in the Zapier preset.
+func sendWebhook(ctx context.Context, vm *voicemail.Voicemail) error {
+	url, err := secret.LoadSecret(ctx, "webhook-url")
+	if err != nil {
+		return fmt.Errorf("failed to load webhook URL: %w", err)
+	}
+	// Zapier can't check signatures, so its preset is sent unsigned.
+	zapier := webhookFormat() == "zapier"
+	var key []byte
+	if !zapier {
+		key, err = secret.LoadSecret(ctx, "webhook-signing-secret")
+		if err != nil {
+			return fmt.Errorf("failed to load webhook signing secret: %w", err)
+		}
+	}
+
+	var payload interface{} = newWebhookPayload(vm)
+	if zapier {
+		payload = newZapierPayload(vm)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(string(url)), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if !zapier {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign([]byte(strings.TrimSpace(string(key))), timestamp, body))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	logger.Info.Printf("🔗 Delivered transcription for message %s to webhook", vm.MessageID)
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package ratelimit throttles incoming requests, both overall and per client
+// IP, so floods and runaway retries can't exhaust the service.
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter applies a global limit and a limit per client IP.
+type Limiter struct {
+	global *rate.Limiter
+
+	perIP       rate.Limit
+	perIPBurst  int
+	trustedHops int
+
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// idleTimeout is how long an IP's limiter is kept after its last request.
+const idleTimeout = 10 * time.Minute
+
+// New returns a Limiter configured from the environment:
+// RATE_LIMIT_GLOBAL_RPS and RATE_LIMIT_GLOBAL_BURST (default 50/100), and
+// RATE_LIMIT_IP_RPS and RATE_LIMIT_IP_BURST (default 5/20). A rate of 0
+// disables that limit. RATE_LIMIT_TRUSTED_HOPS is the number of proxies in
+// front of the service that append to X-Forwarded-For (default 1, Cloud
+// Run's front end); 0 ignores the header and uses the connection's address.
+func New() *Limiter {
+	l := &Limiter{
+		perIP:       rate.Limit(envFloat("RATE_LIMIT_IP_RPS", 5)),
+		perIPBurst:  envInt("RATE_LIMIT_IP_BURST", 20, 1),
+		trustedHops: envInt("RATE_LIMIT_TRUSTED_HOPS", 1, 0),
+		clients:     make(map[string]*client),
+	}
+	if rps := envFloat("RATE_LIMIT_GLOBAL_RPS", 50); rps > 0 {
+		l.global = rate.NewLimiter(rate.Limit(rps), envInt("RATE_LIMIT_GLOBAL_BURST", 100, 1))
+	}
+	go l.cleanupLoop()
+	return l
+}
+
+// Middleware rejects requests over either limit with 429 Too Many Requests.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r, l.trustedHops)
+		if !l.allowIP(ip) {
+			logger.Warn.Printf("⚠️ Rate limited %s %s from %s", r.Method, r.URL.Path, ip)
+			tooMany(w)
+			return
+		}
+		if l.global != nil && !l.global.Allow() {
+			logger.Warn.Printf("⚠️ Global rate limit hit on %s %s", r.Method, r.URL.Path)
+			tooMany(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) allowIP(ip string) bool {
+	if l.perIP <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.perIP, l.perIPBurst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = time.Now()
+	l.mu.Unlock()
+
+	return c.limiter.Allow()
+}
+
+func (l *Limiter) cleanupLoop() {
+	for range time.Tick(time.Minute) {
+		l.mu.Lock()
+		for ip, c := range l.clients {
+			if time.Since(c.lastSeen) > idleTimeout {
+				delete(l.clients, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func tooMany(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
+
+// ClientIP returns the caller's address as recorded by the outermost of
+// trustedHops proxies, each of which appends the address it received the
+// request from to X-Forwarded-For. Entries to the left of that were sent by
+// the client and can't be trusted.
+func ClientIP(r *http.Request, trustedHops int) string {
+	var entries []string
+	for _, fwd := range r.Header.Values("X-Forwarded-For") {
+		entries = append(entries, strings.Split(fwd, ",")...)
+	}
+	if len(entries) > 0 && trustedHops > 0 {
+		if ip := strings.TrimSpace(entries[max(len(entries)-trustedHops, 0)]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func envFloat(name string, def float64) float64 {
+	if v := os.Getenv(name); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			return f
+		}
+		logger.Warn.Printf("⚠️ Invalid %s %q, using default", name, v)
+	}
+	return def
+}
+
+// envInt reads an integer no smaller than atLeast from name, or def when it
+// is unset or invalid.
+func envInt(name string, def, atLeast int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= atLeast {
+			return n
+		}
+		logger.Warn.Printf("⚠️ Invalid %s %q, using default", name, v)
+	}
+	return def
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded []string
+		hops      int
+		want      string
+	}{
+		{
+			name:      "no proxy ignores the header",
+			forwarded: []string{"198.51.100.9"},
+			hops:      0,
+			want:      "192.0.2.1",
+		},
+		{
+			name: "no header",
+			hops: 1,
+			want: "192.0.2.1",
+		},
+		{
+			name:      "one proxy",
+			forwarded: []string{"203.0.113.7"},
+			hops:      1,
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "one proxy with a spoofed entry",
+			forwarded: []string{"198.51.100.9, 203.0.113.7"},
+			hops:      1,
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "spoofed header line",
+			forwarded: []string{"198.51.100.9", "203.0.113.7"},
+			hops:      1,
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "two proxies",
+			forwarded: []string{"198.51.100.9, 203.0.113.7, 10.0.0.2"},
+			hops:      2,
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "two proxies, header shorter than the chain",
+			forwarded: []string{"203.0.113.7"},
+			hops:      2,
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "empty trusted entry",
+			forwarded: []string{"198.51.100.9, "},
+			hops:      1,
+			want:      "192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:54321"
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ClientIP(r, tt.hops); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedHops(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 1},
+		{"0", 0},
+		{"2", 2},
+		{"-1", 1},
+		{"many", 1},
+	}
+	for _, tt := range tests {
+		t.Setenv("RATE_LIMIT_TRUSTED_HOPS", tt.value)
+		if got := New().trustedHops; got != tt.want {
+			t.Errorf("RATE_LIMIT_TRUSTED_HOPS=%q gives %d hops, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package report builds the monthly usage and volume report from the stored
+// transcript records.
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Collection holds one stored report per month, keyed YYYY-MM.
+const Collection = "reports"
+
+const topCallers = 5
+
+// Enabled reads REPORT_ENABLED.
+func Enabled() bool {
+	return strings.EqualFold(os.Getenv("REPORT_ENABLED"), "true")
+}
+
+// Location reads REPORT_TIMEZONE, the zone months and hours are counted
+// in, defaulting to Europe/London.
+func Location() *time.Location {
+	name := os.Getenv("REPORT_TIMEZONE")
+	if name == "" {
+		name = "Europe/London"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn.Printf("⚠️ Invalid REPORT_TIMEZONE %q, using UTC", name)
+		return time.UTC
+	}
+	return loc
+}
+
+// HourCount is how many voicemails arrived in an hour of the day.
+type HourCount struct {
+	Hour       int `firestore:"hour" json:"hour"`
+	Voicemails int `firestore:"voicemails" json:"voicemails"`
+}
+
+// CallerCount is how many voicemails a caller left.
+type CallerCount struct {
+	Caller     string `firestore:"caller" json:"caller"`
+	Name       string `firestore:"name,omitempty" json:"name,omitempty"`
+	Voicemails int    `firestore:"voicemails" json:"voicemails"`
+}
+
+// Report summarises the voicemails received in a month.
+type Report struct {
+	Month                  string        `firestore:"month" json:"month"`
+	From                   time.Time     `firestore:"from" json:"from"`
+	To                     time.Time     `firestore:"to" json:"to"`
+	Voicemails             int           `firestore:"voicemails" json:"voicemails"`
+	Failed                 int           `firestore:"failed" json:"failed"`
+	FailureRate            float64       `firestore:"failureRate" json:"failureRate"`
+	Urgent                 int           `firestore:"urgent" json:"urgent"`
+	Bounced                int           `firestore:"bounced" json:"bounced"`
+	AverageDurationSeconds float64       `firestore:"averageDurationSeconds" json:"averageDurationSeconds"`
+	BusiestHours           []HourCount   `firestore:"busiestHours" json:"busiestHours"`
+	TopCallers             []CallerCount `firestore:"topCallers" json:"topCallers"`
+	CostUSD                float64       `firestore:"costUsd" json:"costUsd"`
+	GeneratedAt            time.Time     `firestore:"generatedAt" json:"generatedAt"`
+}
+
+// MonthStart returns the first instant of the month containing t, in loc.
+func MonthStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// Build summarises the transcripts received in the month starting at from.
+// Dry runs are left out, as nothing was delivered for them.
+func Build(ctx context.Context, client *firestore.Client, from time.Time) (*Report, error) {
+	loc := from.Location()
+	to := from.AddDate(0, 1, 0)
+	rep := &Report{Month: from.Format("2006-01"), From: from, To: to}
+
+	hours := make([]int, 24)
+	callers := map[string]*CallerCount{}
+	var duration float64
+	err := transcripts.Each(ctx, client, from, to, func(rec *transcripts.Record) error {
+		if rec.Status == transcripts.StatusDryRun {
+			return nil
+		}
+		rep.Voicemails++
+		if rec.Status == transcripts.StatusFailed {
+			rep.Failed++
+		}
+		if rec.Urgent {
+			rep.Urgent++
+		}
+		if len(rec.DeliveryFailures) > 0 {
+			rep.Bounced++
+		}
+		if rec.Cost != nil {
+			rep.CostUSD += rec.Cost.USD
+		}
+		duration += rec.DurationSeconds
+		hours[rec.ReceivedAt.In(loc).Hour()]++
+
+		if rec.Caller != "" && rec.Caller != voicemail.Withheld {
+			c := callers[rec.Caller]
+			if c == nil {
+				c = &CallerCount{Caller: rec.Caller}
+				callers[rec.Caller] = c
+			}
+			c.Voicemails++
+			if rec.ContactName != "" {
+				c.Name = rec.ContactName
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rep.Voicemails > 0 {
+		rep.FailureRate = float64(rep.Failed) / float64(rep.Voicemails)
+		rep.AverageDurationSeconds = duration / float64(rep.Voicemails)
+	}
+	for hour, n := range hours {
+		if n > 0 {
+			rep.BusiestHours = append(rep.BusiestHours, HourCount{Hour: hour, Voicemails: n})
+		}
+	}
+	sort.SliceStable(rep.BusiestHours, func(i, j int) bool {
+		return rep.BusiestHours[i].Voicemails > rep.BusiestHours[j].Voicemails
+	})
+	if len(rep.BusiestHours) > 3 {
+		rep.BusiestHours = rep.BusiestHours[:3]
+	}
+	for _, c := range callers {
+		rep.TopCallers = append(rep.TopCallers, *c)
+	}
+	sort.Slice(rep.TopCallers, func(i, j int) bool {
+		if rep.TopCallers[i].Voicemails != rep.TopCallers[j].Voicemails {
+			return rep.TopCallers[i].Voicemails > rep.TopCallers[j].Voicemails
+		}
+		return rep.TopCallers[i].Caller < rep.TopCallers[j].Caller
+	})
+	if len(rep.TopCallers) > topCallers {
+		rep.TopCallers = rep.TopCallers[:topCallers]
+	}
+	rep.GeneratedAt = time.Now()
+	return rep, nil
+}
+
+// Generate builds, stores and emails the report for the month starting at
+// from. With overwrite unset it does nothing when the month's report is
+// already stored, so instances running it on a schedule only send it once;
+// an email that fails then isn't retried until it is regenerated with
+// overwrite set.
+func Generate(ctx context.Context, client *firestore.Client, sender email.Sender, from time.Time, overwrite bool) (*Report, error) {
+	ref := client.Collection(Collection).Doc(from.Format("2006-01"))
+	if !overwrite {
+		if _, err := ref.Get(ctx); err == nil {
+			return nil, nil
+		} else if status.Code(err) != codes.NotFound {
+			return nil, fmt.Errorf("failed to read report %s: %w", ref.ID, err)
+		}
+	}
+
+	rep, err := Build(ctx, client, from)
+	if err != nil {
+		return nil, err
+	}
+	if overwrite {
+		_, err = ref.Set(ctx, rep)
+	} else {
+		_, err = ref.Create(ctx, rep)
+		if status.Code(err) == codes.AlreadyExists {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store report %s: %w", ref.ID, err)
+	}
+	logger.Info.Printf("📊 Stored %s report: %d voicemail(s), %d failed", rep.Month, rep.Voicemails, rep.Failed)
+
+	if err := Send(ctx, sender, rep); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+// recipients reads REPORT_TO (comma-separated). The report is only stored
+// when it is empty.
+func recipients() []string {
+	return email.ParseList(os.Getenv("REPORT_TO"))
+}
+
+// Send emails rep to REPORT_TO.
+func Send(ctx context.Context, sender email.Sender, rep *Report) error {
+	to := recipients()
+	if len(to) == 0 || sender == nil {
+		return nil
+	}
+	err := email.SendText(ctx, sender, to, "Voicemail report for "+rep.From.Format("January 2006"), Text(rep))
+	if err != nil {
+		return fmt.Errorf("failed to email %s report: %w", rep.Month, err)
+	}
+	logger.Info.Printf("📊 Emailed %s report to %d recipient(s)", rep.Month, len(to))
+	return nil
+}
+
+// Text renders rep as a plain-text summary.
+func Text(rep *Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Voicemail report for %s\n\n", rep.From.Format("January 2006"))
+	fmt.Fprintf(&b, "Voicemails:        %d\n", rep.Voicemails)
+	fmt.Fprintf(&b, "Failed:            %d (%.1f%%)\n", rep.Failed, rep.FailureRate*100)
+	fmt.Fprintf(&b, "Urgent:            %d\n", rep.Urgent)
+	fmt.Fprintf(&b, "Bounced emails:    %d\n", rep.Bounced)
+	fmt.Fprintf(&b, "Average length:    %s\n", voicemail.FormatDuration(time.Duration(rep.AverageDurationSeconds*float64(time.Second))))
+	fmt.Fprintf(&b, "Cost (estimated):  $%.2f\n", rep.CostUSD)
+
+	if len(rep.BusiestHours) > 0 {
+		b.WriteString("\nBusiest hours:\n")
+		for _, h := range rep.BusiestHours {
+			fmt.Fprintf(&b, "  %02d:00–%02d:00  %d\n", h.Hour, (h.Hour+1)%24, h.Voicemails)
+		}
+	}
+	if len(rep.TopCallers) > 0 {
+		b.WriteString("\nTop callers:\n")
+		for _, c := range rep.TopCallers {
+			caller := c.Caller
+			if c.Name != "" {
+				caller = c.Name + " (" + c.Caller + ")"
+			}
+			fmt.Fprintf(&b, "  %s  %d\n", caller, c.Voicemails)
+		}
+	}
+	return b.String()
+}
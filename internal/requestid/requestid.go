@@ -0,0 +1,90 @@
+// Package requestid assigns each HTTP request an ID and carries it through
+// the context, so the log lines and outbound calls made while processing one
+// voicemail can be correlated.
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Header is the response and outbound request header carrying the ID.
+const Header = "X-Request-ID"
+
+type key struct{}
+
+// FromRequest returns the trace ID from X-Cloud-Trace-Context when Cloud Run
+// provides one, then an incoming X-Request-ID, and otherwise a new ID.
+func FromRequest(r *http.Request) string {
+	if trace := r.Header.Get("X-Cloud-Trace-Context"); trace != "" {
+		id, _, _ := strings.Cut(trace, "/")
+		if id != "" {
+			return id
+		}
+	}
+	if id := strings.TrimSpace(r.Header.Get(Header)); id != "" && len(id) <= 64 {
+		return id
+	}
+	return New()
+}
+
+// New returns a fresh short ID, for work that doesn't start with a request.
+func New() string {
+	return uuid.New().String()[:8]
+}
+
+// IsTrace reports whether id is a Cloud Trace ID, 32 hex digits, rather
+// than one generated by New.
+func IsTrace(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the ID carried by ctx, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// Prefix returns "[id] " for log lines, or "" when ctx carries no ID.
+func Prefix(ctx context.Context) string {
+	if id := FromContext(ctx); id != "" {
+		return "[" + id + "] "
+	}
+	return ""
+}
+
+// Transport adds the request ID from each outbound request's context as an
+// X-Request-ID header.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	id := FromContext(req.Context())
+	if id == "" || req.Header.Get(Header) != "" {
+		return base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, id)
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,147 @@
+// Package retry runs calls to flaky upstream APIs with jittered exponential
+// backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+// Policy controls how often and how long a call is retried.
+type Policy struct {
+	// Retries is the number of attempts after the first.
+	Retries int
+	// BaseDelay is the backoff before the first retry; it doubles on each
+	// further retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// FromEnv reads <prefix>_MAX_RETRIES, <prefix>_RETRY_BASE_DELAY and
+// <prefix>_RETRY_MAX_DELAY, using def for anything unset or invalid.
+func FromEnv(prefix string, def Policy) Policy {
+	p := def
+	if v := os.Getenv(prefix + "_MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			p.Retries = n
+		} else {
+			logger.Warn.Printf("⚠️ Invalid %s_MAX_RETRIES %q, using default", prefix, v)
+		}
+	}
+	p.BaseDelay = envDuration(prefix+"_RETRY_BASE_DELAY", p.BaseDelay)
+	p.MaxDelay = envDuration(prefix+"_RETRY_MAX_DELAY", p.MaxDelay)
+	return p
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		logger.Warn.Printf("⚠️ Invalid %s %q, using default", name, v)
+		return def
+	}
+	return d
+}
+
+// Backoff returns the delay before retry number n (starting at 1): a random
+// duration up to BaseDelay doubled n-1 times, capped at MaxDelay.
+func (p Policy) Backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+// temporary wraps an error that is worth retrying.
+type temporary struct {
+	err   error
+	after time.Duration
+}
+
+func (t *temporary) Error() string { return t.err.Error() }
+func (t *temporary) Unwrap() error { return t.err }
+
+// Temporary marks err as retryable. A positive after is the delay the server
+// asked for, which is used instead of the backoff when it's longer.
+func Temporary(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &temporary{err: err, after: after}
+}
+
+// IsTemporary reports whether err was marked retryable.
+func IsTemporary(err error) bool {
+	var t *temporary
+	return errors.As(err, &t)
+}
+
+// Do calls fn until it succeeds, returns an error not marked Temporary, the
+// retries run out or ctx is done. name identifies the call in logs. The
+// returned error is the last one fn returned, without the Temporary marker.
+func Do(ctx context.Context, name string, p Policy, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		var t *temporary
+		if err == nil || !errors.As(err, &t) {
+			return err
+		}
+		if attempt >= p.Retries {
+			return t.err
+		}
+
+		delay := max(p.Backoff(attempt+1), t.after)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			logger.Warn.Printf("⚠️ %s failed and there's no time left to retry: %v", name, t.err)
+			return t.err
+		}
+		logger.Warn.Printf("⚠️ %s failed (attempt %d of %d), retrying in %s: %v",
+			name, attempt+1, p.Retries+1, delay.Round(time.Millisecond), t.err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return t.err
+		case <-timer.C:
+		}
+	}
+}
+
+// RetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date, returning 0 when it is absent or invalid.
+func RetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// StatusTemporary reports whether an HTTP status is worth retrying: 429 and
+// any 5xx.
+func StatusTemporary(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
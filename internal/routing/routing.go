@@ -0,0 +1,217 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/email"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/notify"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	MatchExact  = "exact"
+	MatchPrefix = "prefix"
+)
+
+// Rule maps a caller number, or a number prefix, to the recipients and
+// notification channels that should receive the transcription.
+type Rule struct {
+	ID         string           `firestore:"-" yaml:"id"`
+	Name       string           `firestore:"name" yaml:"name"`
+	Match      string           `firestore:"match" yaml:"match"`
+	Number     string           `firestore:"number" yaml:"number"`
+	Recipients email.Recipients `firestore:"recipients" yaml:"recipients"`
+	Channels   []string         `firestore:"channels" yaml:"channels"`
+	Disabled   bool             `firestore:"disabled" yaml:"disabled"`
+}
+
+// Route is where a particular voicemail's transcription is delivered.
+type Route struct {
+	Recipients email.Recipients
+	Channels   []string
+}
+
+var (
+	cachedRules []Rule
+	cachedAt    time.Time
+	cacheLock   sync.Mutex
+
+	// staticRules come from the config file and apply alongside the rules
+	// stored in Firestore.
+	staticRules []Rule
+)
+
+// SetStaticRules installs rules loaded from the config file.
+func SetStaticRules(rules []Rule) {
+	var valid []Rule
+	for i, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("config-%d", i+1)
+		}
+		if rule, ok := normalize(rule); ok {
+			valid = append(valid, rule)
+		}
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	staticRules = valid
+	cachedRules = nil
+}
+
+// Invalidate drops the cached rules so the next lookup reads Firestore.
+func Invalidate() {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cachedRules = nil
+}
+
+// normalize tidies a copy of a rule's fields and reports whether the rule is
+// usable. An empty match means MatchExact; a rule with any other unknown
+// match could never apply, so it is rejected.
+func normalize(rule Rule) (Rule, bool) {
+	rule.Match = strings.ToLower(strings.TrimSpace(rule.Match))
+	switch rule.Match {
+	case "":
+		rule.Match = MatchExact
+	case MatchExact, MatchPrefix:
+	default:
+		logger.Warn.Printf("⚠️ Skipping routing rule %s with unknown match %q", rule.ID, rule.Match)
+		return rule, false
+	}
+	rule.Number = voicemail.NormalizeNumber(rule.Number)
+	channels := make([]string, len(rule.Channels))
+	for i, c := range rule.Channels {
+		channels[i] = strings.ToLower(strings.TrimSpace(c))
+	}
+	rule.Channels = channels
+	return rule, !rule.Disabled && rule.Number != ""
+}
+
+func collection() string {
+	if c := os.Getenv("ROUTING_RULES_COLLECTION"); c != "" {
+		return c
+	}
+	return "routing_rules"
+}
+
+func cacheTTL() time.Duration {
+	if v := os.Getenv("ROUTING_RULES_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid ROUTING_RULES_TTL %q, using default", v)
+	}
+	return 5 * time.Minute
+}
+
+// LoadRules returns the routing rules, reading them from Firestore at most
+// once per ROUTING_RULES_TTL.
+func LoadRules(ctx context.Context, client *firestore.Client) ([]Rule, error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if cachedRules != nil && time.Since(cachedAt) < cacheTTL() {
+		return cachedRules, nil
+	}
+
+	rules := []Rule{}
+	if client == nil {
+		return append(rules, staticRules...), nil
+	}
+
+	iter := client.Collection(collection()).Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load routing rules: %w", err)
+		}
+
+		var rule Rule
+		if err := doc.DataTo(&rule); err != nil {
+			logger.Warn.Printf("⚠️ Skipping malformed routing rule %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		rule.ID = doc.Ref.ID
+		if rule, ok := normalize(rule); ok {
+			rules = append(rules, rule)
+		}
+	}
+	// Firestore rules come first so they win over config file rules for the
+	// same number.
+	rules = append(rules, staticRules...)
+
+	cachedRules = rules
+	cachedAt = time.Now()
+	logger.Info.Printf("🧭 Loaded %d routing rule(s)", len(rules))
+	return rules, nil
+}
+
+// Match picks the rule for caller: an exact match wins, otherwise the longest
+// matching prefix. It returns nil when no rule applies.
+func Match(rules []Rule, caller string) *Rule {
+	caller = voicemail.NormalizeNumber(caller)
+	if caller == "" {
+		return nil
+	}
+
+	var best *Rule
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.Match {
+		case MatchExact:
+			if rule.Number == caller {
+				return rule
+			}
+		case MatchPrefix:
+			if strings.HasPrefix(caller, rule.Number) && (best == nil || len(rule.Number) > len(best.Number)) {
+				best = rule
+			}
+		}
+	}
+	return best
+}
+
+// Resolve returns the route for a voicemail from caller: the default
+// recipients and channels, overridden by the matching rule if any.
+func Resolve(ctx context.Context, client *firestore.Client, caller string) Route {
+	route := Route{
+		Recipients: email.DefaultRecipients(),
+		Channels:   notify.DefaultChannels(),
+	}
+	if caller == "" {
+		return route
+	}
+
+	rules, err := LoadRules(ctx, client)
+	if err != nil {
+		logger.Error.Printf("❌ Falling back to default route: %v", err)
+		return route
+	}
+
+	rule := Match(rules, caller)
+	if rule == nil {
+		logger.Debug.Printf("🧭 No routing rule for %s, using default route", caller)
+		return route
+	}
+
+	logger.Info.Printf("🧭 Routing voicemail from %s using rule %s (%s)", caller, rule.ID, rule.Name)
+	route.Recipients = route.Recipients.Override(rule.Recipients)
+	if len(rule.Channels) > 0 {
+		route.Channels = rule.Channels
+	}
+	return route
+}
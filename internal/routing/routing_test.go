@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      Rule
+		wantOK    bool
+		wantMatch string
+	}{
+		{"exact", Rule{Match: "Exact", Number: "+44 7123 456789"}, true, MatchExact},
+		{"prefix", Rule{Match: " prefix ", Number: "0161"}, true, MatchPrefix},
+		{"empty match defaults to exact", Rule{Number: "07123 456789"}, true, MatchExact},
+		{"unknown match", Rule{Match: "regex", Number: "07123 456789"}, false, ""},
+		{"no number", Rule{Match: MatchExact}, false, MatchExact},
+		{"disabled", Rule{Match: MatchExact, Number: "07123 456789", Disabled: true}, false, MatchExact},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalize(tt.rule)
+			if ok != tt.wantOK {
+				t.Errorf("normalize() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got.Match != tt.wantMatch {
+				t.Errorf("normalize() match = %q, want %q", got.Match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestNormalizeCopiesChannels(t *testing.T) {
+	channels := []string{" Email", "SMS "}
+	got, _ := normalize(Rule{Number: "07123 456789", Channels: channels})
+	if want := []string{"email", "sms"}; !slices.Equal(got.Channels, want) {
+		t.Errorf("normalized channels %q, want %q", got.Channels, want)
+	}
+	if want := []string{" Email", "SMS "}; !slices.Equal(channels, want) {
+		t.Errorf("caller's channels changed to %q", channels)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var rules []Rule
+	for _, r := range []Rule{
+		{ID: "mobile", Match: MatchPrefix, Number: "07"},
+		{ID: "mobile-123", Match: MatchPrefix, Number: "07123"},
+		{ID: "exact", Number: "+44 7123 456789"},
+		{ID: "unknown", Match: "regex", Number: "0161"},
+	} {
+		if r, ok := normalize(r); ok {
+			rules = append(rules, r)
+		}
+	}
+
+	tests := []struct {
+		caller string
+		want   string
+	}{
+		{"07123 456789", "exact"},
+		{"07123 000000", "mobile-123"},
+		{"07999 000000", "mobile"},
+		{"0161 496 0000", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if rule := Match(rules, tt.caller); rule != nil {
+			got = rule.ID
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.caller, got, tt.want)
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	for value, want := range map[string]string{"": "5m0s", "30s": "30s", "0": "5m0s", "-1m": "5m0s", "soon": "5m0s"} {
+		t.Setenv("ROUTING_RULES_TTL", value)
+		if got := cacheTTL().String(); got != want {
+			t.Errorf("ROUTING_RULES_TTL=%q gives %s, want %s", value, got, want)
+		}
+	}
+}
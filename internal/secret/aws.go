@@ -0,0 +1,103 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// awsSecretsManager reads secrets from AWS Secrets Manager, for deployments
+// on ECS or EKS. Each secret is named <AWS_SECRET_PREFIX><name>.
+type awsSecretsManager struct {
+	region string
+	prefix string
+
+	mu     sync.Mutex
+	client *secretsmanager.Client
+}
+
+// newAWSSecretsManager reads AWS_REGION (or AWS_DEFAULT_REGION), which is
+// required, and AWS_SECRET_PREFIX (default "voicemail-transcriber/").
+func newAWSSecretsManager() (*awsSecretsManager, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION must be set when SECRET_BACKEND=aws")
+	}
+	prefix, ok := os.LookupEnv("AWS_SECRET_PREFIX")
+	if !ok {
+		prefix = "voicemail-transcriber/"
+	}
+	return &awsSecretsManager{region: region, prefix: prefix}, nil
+}
+
+func (a *awsSecretsManager) Name() string { return "AWS Secrets Manager" }
+
+// smClient creates the client on first use. Credentials come from the SDK's
+// default chain: environment, shared config, then the ECS or EKS role.
+func (a *awsSecretsManager) smClient(ctx context.Context) (*secretsmanager.Client, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client != nil {
+		return a.client, nil
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(a.region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	a.client = secretsmanager.NewFromConfig(cfg)
+	return a.client, nil
+}
+
+// Fetch reads a version of the secret. "latest" reads the AWSCURRENT stage;
+// a version starting with "AWS" (e.g. AWSPREVIOUS) is taken as a staging
+// label and anything else as a version ID.
+func (a *awsSecretsManager) Fetch(ctx context.Context, name, version string) ([]byte, error) {
+	client, err := a.smClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	in := &secretsmanager.GetSecretValueInput{SecretId: aws.String(a.prefix + name)}
+	switch {
+	case version == "latest":
+		in.VersionStage = aws.String("AWSCURRENT")
+	case strings.HasPrefix(version, "AWS"):
+		in.VersionStage = aws.String(version)
+	default:
+		in.VersionId = aws.String(version)
+	}
+
+	out, err := client.GetSecretValue(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret value: %w", err)
+	}
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+	return out.SecretBinary, nil
+}
+
+// Save stores a new AWSCURRENT version of the secret, which must already
+// exist.
+func (a *awsSecretsManager) Save(ctx context.Context, name string, data []byte) error {
+	client, err := a.smClient(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(a.prefix + name),
+		SecretString: aws.String(string(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put secret value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package secret
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+type cached struct {
+	data      []byte
+	fetchedAt time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	// cache is keyed by name@version.
+	cache = map[string]cached{}
+)
+
+// cacheTTL reads SECRET_CACHE_TTL, how long a secret fetched from Secret
+// Manager is reused before being fetched again. Zero disables caching.
+func cacheTTL() time.Duration {
+	if v := os.Getenv("SECRET_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid SECRET_CACHE_TTL %q, using default", v)
+	}
+	return 5 * time.Minute
+}
+
+func cacheGet(name string) ([]byte, bool) {
+	ttl := cacheTTL()
+	if ttl == 0 {
+		return nil, false
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	c, ok := cache[name]
+	if !ok || time.Since(c.fetchedAt) >= ttl {
+		return nil, false
+	}
+	return c.data, true
+}
+
+func cachePut(name string, data []byte) {
+	if cacheTTL() == 0 {
+		return
+	}
+	cacheMu.Lock()
+	cache[name] = cached{data: data, fetchedAt: time.Now()}
+	cacheMu.Unlock()
+}
+
+// Invalidate drops a cached secret, so the next load fetches it from Secret
+// Manager. Call it when a credential is rejected, in case it was rotated.
+func Invalidate(name string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	for key := range cache {
+		if strings.HasPrefix(key, name+"@") {
+			delete(cache, key)
+		}
+	}
+}
+
+// InvalidateAll drops every cached secret.
+func InvalidateAll() {
+	cacheMu.Lock()
+	clear(cache)
+	cacheMu.Unlock()
+}
@@ -0,0 +1,46 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// mountedFiles reads secrets from files under SECRET_DIR (default
+// /var/secrets), the way Kubernetes and Cloud Run secret volumes deliver
+// them. A secret is either a file named after it, or a directory named after
+// it holding one file per version (e.g. deepgram-api-key/latest), which is
+// how Cloud Run mounts a secret with version paths.
+type mountedFiles struct {
+	dir string
+}
+
+func newMountedFiles() *mountedFiles {
+	dir := os.Getenv("SECRET_DIR")
+	if dir == "" {
+		dir = "/var/secrets"
+	}
+	return &mountedFiles{dir: dir}
+}
+
+func (m *mountedFiles) Name() string { return "mounted files in " + m.dir }
+
+func (m *mountedFiles) Fetch(ctx context.Context, name, version string) ([]byte, error) {
+	path := filepath.Join(m.dir, filepath.Base(name))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s is not mounted: %w", name, err)
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, filepath.Base(version))
+	} else if version != "latest" {
+		return nil, fmt.Errorf("secret %s is pinned to version %s but mounted as a single file", name, version)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounted secret %s: %w", name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	secretpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+)
+
+// secretManager reads secrets from Google Secret Manager in GCP_PROJECT_ID,
+// sharing one client across loads.
+type secretManager struct {
+	mu     sync.Mutex
+	client *secretmanager.Client
+}
+
+func (s *secretManager) Name() string { return "Secret Manager" }
+
+func (s *secretManager) smClient(ctx context.Context) (*secretmanager.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	c, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	s.client = c
+	return c, nil
+}
+
+func (s *secretManager) Fetch(ctx context.Context, name, version string) ([]byte, error) {
+	projectID := os.Getenv("GCP_PROJECT_ID")
+	if projectID == "" {
+		return nil, fmt.Errorf("GCP_PROJECT_ID environment variable is not set")
+	}
+	client, err := s.smClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.AccessSecretVersion(ctx, &secretpb.AccessSecretVersionRequest{
+		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/%s", projectID, name, version),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to access secret version: %w", err)
+	}
+	return result.Payload.Data, nil
+}
+
+// Save adds a version to a secret, which must already exist.
+func (s *secretManager) Save(ctx context.Context, name string, data []byte) error {
+	projectID := os.Getenv("GCP_PROJECT_ID")
+	if projectID == "" {
+		return fmt.Errorf("GCP_PROJECT_ID environment variable is not set")
+	}
+	client, err := s.smClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.AddSecretVersion(ctx, &secretpb.AddSecretVersionRequest{
+		Parent:  fmt.Sprintf("projects/%s/secrets/%s", projectID, name),
+		Payload: &secretpb.SecretPayload{Data: data},
+	})
+	return err
+}
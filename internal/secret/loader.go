@@ -6,13 +6,37 @@ import (
 	"os"
 	"strings"
 	"voicemail-transcriber-production/internal/logger"
-
-	secretmanager "cloud.google.com/go/secretmanager/apiv1"
-	secretpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
 )
 
-// LoadSecret fetches the latest version of a secret from Secret Manager
-// LoadSecret fetches the latest version of a secret from Secret Manager
+// Loader fetches secrets by name. It lets code that needs credentials be
+// given a fake instead of reaching Secret Manager.
+type Loader interface {
+	Load(ctx context.Context, name string) ([]byte, error)
+}
+
+// LoaderFunc adapts a function to the Loader interface.
+type LoaderFunc func(ctx context.Context, name string) ([]byte, error)
+
+func (f LoaderFunc) Load(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+// Default is the Loader used when none is given: LoadSecret.
+var Default Loader = LoaderFunc(LoadSecret)
+
+// Version returns the version to read for a secret:
+// SECRET_VERSION_<NAME> (e.g. SECRET_VERSION_DEEPGRAM_API_KEY=3) when set,
+// otherwise "latest". Pinning lets a bad rotation be rolled back by config.
+func Version(secretName string) string {
+	envName := "SECRET_VERSION_" + strings.ToUpper(strings.ReplaceAll(secretName, "-", "_"))
+	if v := strings.TrimSpace(os.Getenv(envName)); v != "" {
+		return v
+	}
+	return "latest"
+}
+
+// LoadSecret fetches a secret from the configured Provider at the version
+// chosen by Version, reusing a fetched value for SECRET_CACHE_TTL.
 func LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
 	// First check if secret is available as environment variable
 	envName := strings.ToUpper(strings.ReplaceAll(secretName, "-", "_"))
@@ -20,36 +44,52 @@ func LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
 		logger.Info.Printf("🔍 Debug: Found secret %s in environment variables", secretName)
 		return []byte(envValue), nil
 	}
-	logger.Info.Printf("🔍 Debug: Secret %s not found in environment, trying Secret Manager", secretName)
 
-	// If not in environment, fall back to Secret Manager
+	p, err := provider()
+	if err != nil {
+		return nil, err
+	}
+	// If not in environment, fall back to the provider
 	if secretName == "" {
 		return nil, fmt.Errorf("secret name must not be empty")
 	}
 
-	client, err := secretmanager.NewClient(ctx)
-	if err != nil {
-		logger.Error.Printf("❌ Debug: Failed to create Secret Manager client: %v", err)
-		return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+	version := Version(secretName)
+	cacheKey := secretName + "@" + version
+	if data, ok := cacheGet(cacheKey); ok {
+		return data, nil
 	}
-	defer client.Close()
+	logger.Info.Printf("🔍 Debug: Secret %s not found in environment, trying %s", secretName, p.Name())
 
-	projectID := os.Getenv("GCP_PROJECT_ID")
-	if projectID == "" {
-		return nil, fmt.Errorf("GCP_PROJECT_ID environment variable is not set")
+	data, err := p.Fetch(ctx, secretName, version)
+	if err != nil {
+		logger.Error.Printf("❌ Debug: Failed to fetch secret %s from %s: %v", secretName, p.Name(), err)
+		return nil, err
 	}
-	logger.Info.Printf("🔍 Debug: Attempting to access secret %s in project %s", secretName, projectID)
 
-	accessRequest := &secretpb.AccessSecretVersionRequest{
-		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, secretName),
+	logger.Info.Printf("✅ Debug: Successfully retrieved secret %s from %s", secretName, p.Name())
+	cachePut(cacheKey, data)
+	if version == "latest" {
+		track(secretName, data)
 	}
+	return data, nil
+}
 
-	result, err := client.AccessSecretVersion(ctx, accessRequest)
+// SaveSecret stores data as a new version of a secret, for providers that
+// support writing. Readers of "latest" pick it up on their next load.
+func SaveSecret(ctx context.Context, secretName string, data []byte) error {
+	p, err := provider()
 	if err != nil {
-		logger.Error.Printf("❌ Debug: Failed to access secret version: %v", err)
-		return nil, fmt.Errorf("failed to access secret version: %w", err)
+		return err
 	}
-
-	logger.Info.Printf("✅ Debug: Successfully retrieved secret %s from Secret Manager", secretName)
-	return result.Payload.Data, nil
+	saver, ok := p.(Saver)
+	if !ok {
+		return fmt.Errorf("secret backend %s doesn't support saving secrets", p.Name())
+	}
+	if err := saver.Save(ctx, secretName, data); err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", secretName, err)
+	}
+	Invalidate(secretName)
+	logger.Info.Printf("🔐 Saved new version of secret %s", secretName)
+	return nil
 }
@@ -0,0 +1,73 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Provider is a secret backend that LoadSecret reads from when a secret isn't
+// set in the environment.
+type Provider interface {
+	// Name identifies the backend in logs.
+	Name() string
+	// Fetch returns the given version of a secret; version is "latest" unless
+	// pinned.
+	Fetch(ctx context.Context, name, version string) ([]byte, error)
+}
+
+// Saver is implemented by providers that can store new secret versions.
+type Saver interface {
+	Save(ctx context.Context, name string, data []byte) error
+}
+
+var (
+	providerMu sync.Mutex
+	providers  = map[string]Provider{}
+)
+
+// backend reads SECRET_BACKEND: "gcp" (Secret Manager, the default),
+// "vault", "aws" or "file".
+func backend() string {
+	if b := strings.ToLower(strings.TrimSpace(os.Getenv("SECRET_BACKEND"))); b != "" {
+		return b
+	}
+	return "gcp"
+}
+
+// provider returns the Provider selected by SECRET_BACKEND, creating it on
+// first use.
+func provider() (Provider, error) {
+	name := backend()
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if p, ok := providers[name]; ok {
+		return p, nil
+	}
+
+	var p Provider
+	switch name {
+	case "gcp":
+		p = &secretManager{}
+	case "vault":
+		v, err := newVault()
+		if err != nil {
+			return nil, err
+		}
+		p = v
+	case "aws":
+		a, err := newAWSSecretsManager()
+		if err != nil {
+			return nil, err
+		}
+		p = a
+	case "file":
+		p = newMountedFiles()
+	default:
+		return nil, fmt.Errorf("unknown SECRET_BACKEND %q", name)
+	}
+	providers[name] = p
+	return p, nil
+}
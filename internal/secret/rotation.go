@@ -0,0 +1,108 @@
+package secret
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+var (
+	rotationMu sync.Mutex
+	// fingerprints holds a hash of the last value seen for each secret
+	// read at "latest".
+	fingerprints = map[string][32]byte{}
+	handlers     = map[string][]func(){}
+)
+
+// OnRotate registers fn to run, in its own goroutine, whenever a new value of
+// the named secret is seen, either by WatchRotation or by a load after the
+// cache expired.
+func OnRotate(name string, fn func()) {
+	rotationMu.Lock()
+	handlers[name] = append(handlers[name], fn)
+	rotationMu.Unlock()
+}
+
+// track records the value fetched for a secret at "latest", running its
+// rotation handlers if it differs from the last one seen.
+func track(name string, data []byte) {
+	sum := sha256.Sum256(data)
+	rotationMu.Lock()
+	prev, known := fingerprints[name]
+	fingerprints[name] = sum
+	fns := handlers[name]
+	rotationMu.Unlock()
+
+	if !known || prev == sum {
+		return
+	}
+	logger.Info.Printf("🔑 Secret %s was rotated", name)
+	for _, fn := range fns {
+		go fn()
+	}
+}
+
+// rotationInterval reads SECRET_ROTATION_INTERVAL, how often WatchRotation
+// re-fetches secrets. Zero disables the watcher.
+func rotationInterval() time.Duration {
+	if v := os.Getenv("SECRET_ROTATION_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid SECRET_ROTATION_INTERVAL %q, using default", v)
+	}
+	return 5 * time.Minute
+}
+
+// WatchRotation periodically re-fetches every secret loaded so far at
+// "latest", bypassing the cache, so a newly published version replaces the
+// cached one and rotation handlers run without waiting for a restart.
+// Secrets pinned to a version or set in the environment aren't watched.
+func WatchRotation(ctx context.Context) {
+	interval := rotationInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		refreshWatched(ctx)
+	}
+}
+
+func refreshWatched(ctx context.Context) {
+	p, err := provider()
+	if err != nil {
+		logger.Warn.Printf("⚠️ Skipping secret rotation check: %v", err)
+		return
+	}
+
+	rotationMu.Lock()
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	rotationMu.Unlock()
+
+	for _, name := range names {
+		if Version(name) != "latest" {
+			continue
+		}
+		data, err := p.Fetch(ctx, name, "latest")
+		if err != nil {
+			logger.Warn.Printf("⚠️ Could not check secret %s for rotation: %v", name, err)
+			continue
+		}
+		cachePut(name+"@latest", data)
+		track(name, data)
+	}
+}
@@ -0,0 +1,129 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// vault reads secrets from a HashiCorp Vault KV version 2 engine. Each secret
+// is stored at <VAULT_KV_MOUNT>/<VAULT_SECRET_PREFIX>/<name>, with its value
+// in the VAULT_SECRET_FIELD field.
+type vault struct {
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	prefix    string
+	field     string
+	client    *http.Client
+}
+
+// newVault reads VAULT_ADDR and VAULT_TOKEN (both required), VAULT_NAMESPACE,
+// VAULT_KV_MOUNT (default "secret"), VAULT_SECRET_PREFIX (default
+// "voicemail-transcriber") and VAULT_SECRET_FIELD (default "value").
+func newVault() (*vault, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set when SECRET_BACKEND=vault")
+	}
+	return &vault{
+		addr:      addr,
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		mount:     envOr("VAULT_KV_MOUNT", "secret"),
+		prefix:    envOr("VAULT_SECRET_PREFIX", "voicemail-transcriber"),
+		field:     envOr("VAULT_SECRET_FIELD", "value"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return strings.Trim(v, "/")
+	}
+	return def
+}
+
+func (v *vault) Name() string { return "Vault" }
+
+func (v *vault) url(name string) string {
+	path := v.mount + "/data/"
+	if v.prefix != "" {
+		path += v.prefix + "/"
+	}
+	return v.addr + "/v1/" + path + url.PathEscape(name)
+}
+
+func (v *vault) do(ctx context.Context, method, u string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (v *vault) Fetch(ctx context.Context, name, version string) ([]byte, error) {
+	u := v.url(name)
+	if version != "latest" {
+		u += "?version=" + url.QueryEscape(version)
+	}
+	data, err := v.do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	value, ok := resp.Data.Data[v.field].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault secret %s has no string field %q", name, v.field)
+	}
+	return []byte(value), nil
+}
+
+// Save writes a new version of the secret.
+func (v *vault) Save(ctx context.Context, name string, data []byte) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]string{v.field: string(data)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = v.do(ctx, http.MethodPost, v.url(name), bytes.NewReader(body))
+	return err
+}
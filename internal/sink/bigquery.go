@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+
+	"google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	bqService *bigquery.Service
+	bqTable   bool
+	bqLock    sync.Mutex
+)
+
+// bigQueryEnabled reports whether BIGQUERY_DATASET and BIGQUERY_TABLE are
+// configured.
+func bigQueryEnabled() bool {
+	return os.Getenv("BIGQUERY_DATASET") != "" && os.Getenv("BIGQUERY_TABLE") != ""
+}
+
+func bigQueryProject() string {
+	if p := os.Getenv("BIGQUERY_PROJECT_ID"); p != "" {
+		return p
+	}
+	return os.Getenv("GCP_PROJECT_ID")
+}
+
+var bigQuerySchema = &bigquery.TableSchema{
+	Fields: []*bigquery.TableFieldSchema{
+		{Name: "message_id", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "caller", Type: "STRING"},
+		{Name: "carrier", Type: "STRING"},
+		{Name: "mailbox", Type: "STRING"},
+		{Name: "subject", Type: "STRING"},
+		{Name: "received_at", Type: "TIMESTAMP"},
+		{Name: "duration_seconds", Type: "FLOAT"},
+		{Name: "transcript", Type: "STRING"},
+		{Name: "confidence", Type: "FLOAT"},
+		{Name: "provider", Type: "STRING"},
+		{Name: "status", Type: "STRING"},
+		{Name: "recording_count", Type: "INTEGER"},
+		{Name: "created_at", Type: "TIMESTAMP"},
+		{Name: "exported_at", Type: "TIMESTAMP"},
+	},
+}
+
+// bigQueryClient returns the shared BigQuery service, creating the
+// destination table on first use if it doesn't exist.
+func bigQueryClient(ctx context.Context) (*bigquery.Service, error) {
+	bqLock.Lock()
+	defer bqLock.Unlock()
+
+	if bqService == nil {
+		srv, err := bigquery.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create BigQuery service: %w", err)
+		}
+		bqService = srv
+	}
+
+	if !bqTable {
+		project, dataset, table := bigQueryProject(), os.Getenv("BIGQUERY_DATASET"), os.Getenv("BIGQUERY_TABLE")
+		_, err := bqService.Tables.Get(project, dataset, table).Context(ctx).Do()
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			_, err = bqService.Tables.Insert(project, dataset, &bigquery.Table{
+				TableReference: &bigquery.TableReference{ProjectId: project, DatasetId: dataset, TableId: table},
+				Schema:         bigQuerySchema,
+				TimePartitioning: &bigquery.TimePartitioning{
+					Type:  "DAY",
+					Field: "received_at",
+				},
+			}).Context(ctx).Do()
+			if err == nil {
+				logger.Info.Printf("📊 Created BigQuery table %s.%s.%s", project, dataset, table)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare BigQuery table: %w", err)
+		}
+		bqTable = true
+	}
+	return bqService, nil
+}
+
+// exportBigQuery streams the record into BIGQUERY_DATASET.BIGQUERY_TABLE.
+func exportBigQuery(ctx context.Context, rec *transcripts.Record) error {
+	srv, err := bigQueryClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	row := map[string]bigquery.JsonValue{
+		"message_id":       rec.MessageID,
+		"caller":           rec.Caller,
+		"carrier":          rec.Carrier,
+		"mailbox":          rec.Mailbox,
+		"subject":          rec.Subject,
+		"duration_seconds": rec.DurationSeconds,
+		"transcript":       rec.Transcript,
+		"confidence":       rec.Confidence,
+		"provider":         rec.Provider,
+		"status":           rec.Status,
+		"recording_count":  len(rec.Recordings),
+		"exported_at":      time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if !rec.ReceivedAt.IsZero() {
+		row["received_at"] = rec.ReceivedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if !rec.CreatedAt.IsZero() {
+		row["created_at"] = rec.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	req := &bigquery.TableDataInsertAllRequest{
+		Rows: []*bigquery.TableDataInsertAllRequestRows{
+			{
+				// The insert ID lets BigQuery drop retried duplicates of the
+				// same update.
+				InsertId: rec.ID + "-" + strconv.FormatInt(rec.UpdatedAt.UnixNano(), 10),
+				Json:     row,
+			},
+		},
+	}
+
+	resp, err := srv.Tabledata.InsertAll(bigQueryProject(), os.Getenv("BIGQUERY_DATASET"), os.Getenv("BIGQUERY_TABLE"), req).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("insertAll failed: %w", err)
+	}
+	if len(resp.InsertErrors) > 0 && len(resp.InsertErrors[0].Errors) > 0 {
+		return fmt.Errorf("row rejected: %s", resp.InsertErrors[0].Errors[0].Message)
+	}
+
+	logger.Info.Printf("📊 Exported transcript %s to BigQuery", rec.ID)
+	return nil
+}
@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/auth"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+var (
+	sheetsService *sheets.Service
+	sheetsLock    sync.Mutex
+)
+
+// sheetsEnabled reports whether SHEETS_SPREADSHEET_ID is configured.
+func sheetsEnabled() bool {
+	return os.Getenv("SHEETS_SPREADSHEET_ID") != ""
+}
+
+// sheetsRange reads SHEETS_RANGE, the A1 range rows are appended after,
+// defaulting to the first sheet.
+func sheetsRange() string {
+	if r := os.Getenv("SHEETS_RANGE"); r != "" {
+		return r
+	}
+	return "Sheet1!A:D"
+}
+
+// sheetsLocation reads SHEETS_TIMEZONE, the zone times are written in,
+// defaulting to Europe/London.
+func sheetsLocation() *time.Location {
+	name := os.Getenv("SHEETS_TIMEZONE")
+	if name == "" {
+		name = "Europe/London"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn.Printf("⚠️ Invalid SHEETS_TIMEZONE %q, using UTC", name)
+		return time.UTC
+	}
+	return loc
+}
+
+func sheetsClient(ctx context.Context) (*sheets.Service, error) {
+	sheetsLock.Lock()
+	defer sheetsLock.Unlock()
+	if sheetsService != nil {
+		return sheetsService, nil
+	}
+
+	// The spreadsheet is shared with the service account, so no mailbox is
+	// impersonated. The client outlives this request.
+	ctx = context.WithoutCancel(ctx)
+	creds, err := auth.ServiceAccountOption(ctx, sheets.SpreadsheetsScope)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := sheets.NewService(ctx, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sheets service: %w", err)
+	}
+	sheetsService = srv
+	return srv, nil
+}
+
+// exportSheets appends a row (time, caller, duration, transcript) to the
+// SHEETS_SPREADSHEET_ID spreadsheet. Values are written raw so a transcript
+// is never interpreted as a formula.
+func exportSheets(ctx context.Context, rec *transcripts.Record) error {
+	srv, err := sheetsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	caller := rec.Caller
+	if rec.ContactName != "" {
+		caller = rec.ContactName + " (" + rec.Caller + ")"
+	}
+	received := ""
+	if !rec.ReceivedAt.IsZero() {
+		received = rec.ReceivedAt.In(sheetsLocation()).Format("2006-01-02 15:04")
+	}
+	row := &sheets.ValueRange{Values: [][]interface{}{{
+		received,
+		caller,
+		voicemail.FormatDuration(time.Duration(rec.DurationSeconds * float64(time.Second))),
+		rec.Transcript,
+	}}}
+
+	_, err = srv.Spreadsheets.Values.Append(os.Getenv("SHEETS_SPREADSHEET_ID"), sheetsRange(), row).
+		ValueInputOption("RAW").
+		InsertDataOption("INSERT_ROWS").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("append failed: %w", err)
+	}
+
+	logger.Info.Printf("📗 Appended transcript %s to Google Sheets", rec.ID)
+	return nil
+}
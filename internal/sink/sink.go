@@ -0,0 +1,22 @@
+package sink
+
+import (
+	"context"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/transcripts"
+)
+
+// Publish sends a stored transcript record to every enabled export sink.
+// Sink failures are logged and never fail the pipeline.
+func Publish(ctx context.Context, rec *transcripts.Record) {
+	if bigQueryEnabled() {
+		if err := exportBigQuery(ctx, rec); err != nil {
+			logger.Error.Printf("❌ BigQuery export failed for %s: %v", rec.ID, err)
+		}
+	}
+	if sheetsEnabled() {
+		if err := exportSheets(ctx, rec); err != nil {
+			logger.Error.Printf("❌ Google Sheets export failed for %s: %v", rec.ID, err)
+		}
+	}
+}
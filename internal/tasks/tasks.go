@@ -0,0 +1,122 @@
+// Package tasks queues per-message processing jobs on Cloud Tasks, so the
+// Pub/Sub notification can be acknowledged before any transcription runs.
+package tasks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"voicemail-transcriber-production/internal/logger"
+
+	"google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// ProcessPath is where the worker endpoint is mounted.
+const ProcessPath = "/tasks/process"
+
+// Job is the body of a processing task.
+type Job struct {
+	MessageID string `json:"messageId"`
+}
+
+// Queue enqueues jobs on a Cloud Tasks queue that calls back the worker
+// endpoint with an OIDC token.
+type Queue struct {
+	svc            *cloudtasks.Service
+	name           string
+	url            string
+	serviceAccount string
+}
+
+// Enabled reports whether TASK_QUEUE is set.
+func Enabled() bool {
+	return os.Getenv("TASK_QUEUE") != ""
+}
+
+// queueName qualifies TASK_QUEUE with GCP_PROJECT_ID and TASK_LOCATION
+// unless it is already a full queue name.
+func queueName() (string, error) {
+	queue := os.Getenv("TASK_QUEUE")
+	if strings.HasPrefix(queue, "projects/") {
+		return queue, nil
+	}
+	project, location := os.Getenv("GCP_PROJECT_ID"), os.Getenv("TASK_LOCATION")
+	if project == "" || location == "" {
+		return "", fmt.Errorf("GCP_PROJECT_ID and TASK_LOCATION must be set to qualify queue %q", queue)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s", project, location, queue), nil
+}
+
+// New returns the queue configured by TASK_QUEUE, TASK_WORKER_URL (the
+// service's base URL) and TASK_SERVICE_ACCOUNT (the identity tasks call the
+// worker as), or nil when TASK_QUEUE is unset.
+func New(ctx context.Context) (*Queue, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	name, err := queueName()
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(os.Getenv("TASK_WORKER_URL"), "/")
+	if base == "" {
+		return nil, fmt.Errorf("TASK_WORKER_URL must be set when TASK_QUEUE is")
+	}
+
+	svc, err := cloudtasks.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Tasks client: %w", err)
+	}
+	logger.Info.Printf("📬 Queuing message processing on %s", name)
+	return &Queue{
+		svc:            svc,
+		name:           name,
+		url:            base + ProcessPath,
+		serviceAccount: os.Getenv("TASK_SERVICE_ACCOUNT"),
+	}, nil
+}
+
+var unsafeTaskChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Enqueue adds a job for msgID. The task is named after the message, so
+// Cloud Tasks drops a second job for the same message while the name is
+// still reserved.
+func (q *Queue) Enqueue(ctx context.Context, msgID string) error {
+	body, err := json.Marshal(Job{MessageID: msgID})
+	if err != nil {
+		return err
+	}
+
+	req := &cloudtasks.HttpRequest{
+		HttpMethod: http.MethodPost,
+		Url:        q.url,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       base64.StdEncoding.EncodeToString(body),
+	}
+	if q.serviceAccount != "" {
+		req.OidcToken = &cloudtasks.OidcToken{ServiceAccountEmail: q.serviceAccount, Audience: os.Getenv("OIDC_AUDIENCE")}
+	}
+
+	task := &cloudtasks.Task{
+		Name:        q.name + "/tasks/msg-" + unsafeTaskChars.ReplaceAllString(msgID, "_"),
+		HttpRequest: req,
+	}
+	_, err = q.svc.Projects.Locations.Queues.Tasks.Create(q.name, &cloudtasks.CreateTaskRequest{Task: task}).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+		logger.Debug.Printf("📬 Task for message %s already queued", msgID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message %s: %w", msgID, err)
+	}
+	logger.Info.Printf("📬 Queued message %s", msgID)
+	return nil
+}
@@ -0,0 +1,127 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Config holds the settings business users can change at runtime. Empty
+// fields leave the corresponding environment-variable default in place.
+type Config struct {
+	To             []string `firestore:"to"`
+	CC             []string `firestore:"cc"`
+	BCC            []string `firestore:"bcc"`
+	AllowedSenders []string `firestore:"allowedSenders"`
+	Provider       string   `firestore:"provider"`
+	Language       string   `firestore:"language"`
+	Labels         []string `firestore:"labels"`
+	Channels       []string `firestore:"channels"`
+}
+
+var (
+	current  Config
+	loadedAt time.Time
+	lock     sync.RWMutex
+)
+
+// DocPath returns the tenant config document: tenants/<TENANT_ID>, where
+// TENANT_ID defaults to "default".
+func DocPath() string {
+	id := os.Getenv("TENANT_ID")
+	if id == "" {
+		id = "default"
+	}
+	return "tenants/" + id
+}
+
+func cacheTTL() time.Duration {
+	if v := os.Getenv("TENANT_CONFIG_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		logger.Warn.Printf("⚠️ Invalid TENANT_CONFIG_TTL %q, using default", v)
+	}
+	return time.Minute
+}
+
+// Current returns the most recently loaded tenant config.
+func Current() Config {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current
+}
+
+// Refresh reloads the tenant config from Firestore when the cached copy is
+// older than TENANT_CONFIG_TTL. On error the previous config stays in use.
+func Refresh(ctx context.Context, client *firestore.Client) error {
+	lock.RLock()
+	fresh := !loadedAt.IsZero() && time.Since(loadedAt) < cacheTTL()
+	lock.RUnlock()
+	if fresh || client == nil {
+		return nil
+	}
+	return Load(ctx, client)
+}
+
+// Load reads the tenant config from Firestore unconditionally. A missing
+// document means no overrides.
+func Load(ctx context.Context, client *firestore.Client) error {
+	var cfg Config
+	doc, err := client.Doc(DocPath()).Get(ctx)
+	switch {
+	case status.Code(err) == codes.NotFound:
+		logger.Debug.Printf("⚙️ No tenant config at %s, using environment defaults", DocPath())
+	case err != nil:
+		return fmt.Errorf("failed to load tenant config: %w", err)
+	default:
+		if err := doc.DataTo(&cfg); err != nil {
+			return fmt.Errorf("failed to decode tenant config: %w", err)
+		}
+		logger.Debug.Printf("⚙️ Loaded tenant config from %s", DocPath())
+	}
+
+	set(cfg)
+	return nil
+}
+
+// Watch applies changes to the tenant config document as soon as they are
+// written, until ctx is cancelled.
+func Watch(ctx context.Context, client *firestore.Client) {
+	iter := client.Doc(DocPath()).Snapshots(ctx)
+	defer iter.Stop()
+
+	for {
+		snap, err := iter.Next()
+		if err != nil {
+			if ctx.Err() == nil && status.Code(err) != codes.Canceled {
+				logger.Warn.Printf("⚠️ Stopped watching tenant config: %v", err)
+			}
+			return
+		}
+
+		var cfg Config
+		if snap.Exists() {
+			if err := snap.DataTo(&cfg); err != nil {
+				logger.Warn.Printf("⚠️ Ignoring invalid tenant config update: %v", err)
+				continue
+			}
+		}
+		set(cfg)
+		logger.Info.Printf("⚙️ Applied tenant config from %s", DocPath())
+	}
+}
+
+func set(cfg Config) {
+	lock.Lock()
+	current = cfg
+	loadedAt = time.Now()
+	lock.Unlock()
+}
@@ -0,0 +1,59 @@
+package transcriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"voicemail-transcriber-production/internal/breaker"
+	"voicemail-transcriber-production/internal/errorreport"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+// ErrUnavailable is returned without calling Deepgram while its circuit
+// breaker is open.
+var ErrUnavailable = errors.New("transcription provider unavailable")
+
+var (
+	breakerOnce sync.Once
+	dgBreaker   *breaker.Breaker
+)
+
+// circuit returns the Deepgram breaker, configured from
+// TRANSCRIBER_BREAKER_THRESHOLD (consecutive failed requests, default 5) and
+// TRANSCRIBER_BREAKER_COOLDOWN (default 1m).
+func circuit() *breaker.Breaker {
+	breakerOnce.Do(func() {
+		threshold := 5
+		if v := os.Getenv("TRANSCRIBER_BREAKER_THRESHOLD"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				threshold = n
+			} else {
+				logger.Warn.Printf("⚠️ Invalid TRANSCRIBER_BREAKER_THRESHOLD %q, using default", v)
+			}
+		}
+		cooldown := time.Minute
+		if v := os.Getenv("TRANSCRIBER_BREAKER_COOLDOWN"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				cooldown = d
+			} else {
+				logger.Warn.Printf("⚠️ Invalid TRANSCRIBER_BREAKER_COOLDOWN %q, using default", v)
+			}
+		}
+
+		dgBreaker = breaker.New("Deepgram", threshold, cooldown)
+		dgBreaker.OnOpen = func(err error) {
+			errorreport.Report(context.Background(), fmt.Errorf("transcription paused, Deepgram is failing: %w", err))
+		}
+	})
+	return dgBreaker
+}
+
+// Available reports whether transcription requests are currently allowed
+// through the breaker.
+func Available() bool {
+	return circuit().Available()
+}
@@ -0,0 +1,37 @@
+package transcriber
+
+import (
+	"os"
+	"strconv"
+	"voicemail-transcriber-production/internal/logger"
+)
+
+// deepgramModel is the Deepgram model every recording is transcribed with.
+const deepgramModel = "nova-2"
+
+// defaultRates are list prices in USD per audio minute, for pay-as-you-go
+// accounts.
+var defaultRates = map[string]float64{
+	"deepgram": 0.0043,
+}
+
+// Model returns the model provider transcribes with.
+func Model(provider string) string {
+	if provider == "deepgram" {
+		return deepgramModel
+	}
+	return ""
+}
+
+// RatePerMinute returns the estimated cost in USD of transcribing a minute
+// of audio with provider. TRANSCRIPTION_COST_PER_MINUTE overrides the list
+// price, for accounts on a negotiated rate.
+func RatePerMinute(provider string) float64 {
+	if v := os.Getenv("TRANSCRIPTION_COST_PER_MINUTE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 {
+			return rate
+		}
+		logger.Warn.Printf("⚠️ Invalid TRANSCRIPTION_COST_PER_MINUTE %q, using default", v)
+	}
+	return defaultRates[provider]
+}
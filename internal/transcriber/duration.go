@@ -0,0 +1,38 @@
+package transcriber
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// wavDuration reads the duration of a PCM WAV file from its RIFF header. It
+// returns zero when the data is not a WAV file or the header is incomplete.
+func wavDuration(data []byte) time.Duration {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0
+	}
+
+	var byteRate uint32
+	offset := 12
+	for offset+8 <= len(data) {
+		chunkID := string(data[offset : offset+4])
+		chunkSize := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
+		body := offset + 8
+
+		switch chunkID {
+		case "fmt ":
+			if body+12 > len(data) {
+				return 0
+			}
+			byteRate = binary.LittleEndian.Uint32(data[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0
+			}
+			return time.Duration(float64(chunkSize) / float64(byteRate) * float64(time.Second))
+		}
+
+		offset = body + int(chunkSize) + int(chunkSize%2)
+	}
+	return 0
+}
@@ -3,126 +3,295 @@ package transcriber
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/requestid"
+	"voicemail-transcriber-production/internal/retry"
 	"voicemail-transcriber-production/internal/secret"
-
-	"google.golang.org/api/gmail/v1"
+	"voicemail-transcriber-production/internal/tenant"
 )
 
 type DeepgramResponse struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
 	Results struct {
 		Channels []struct {
 			Alternatives []struct {
-				Transcript string `json:"transcript"`
+				Transcript string  `json:"transcript"`
+				Confidence float64 `json:"confidence"`
 			} `json:"alternatives"`
+			DetectedLanguage string `json:"detected_language"`
 		} `json:"channels"`
+		Sentiments struct {
+			Average struct {
+				Sentiment      string  `json:"sentiment"`
+				SentimentScore float64 `json:"sentiment_score"`
+			} `json:"average"`
+		} `json:"sentiments"`
 	} `json:"results"`
 }
 
-func TranscribeAndRespond(ctx context.Context, audioPath string, gmailSrv *gmail.Service, subject string) error {
+// Result is the outcome of transcribing a single audio file.
+type Result struct {
+	Transcript string
+	Duration   time.Duration
+	Confidence float64
+	Provider   string
+	// Language is the language detected in the audio, or the configured
+	// one when detection is off.
+	Language string
+	// Sentiment and SentimentScore are set when Deepgram scores sentiment.
+	Sentiment      string
+	SentimentScore float64
+}
+
+// Provider returns the configured transcription provider. Only Deepgram is
+// currently supported.
+func Provider() string {
+	if p := tenant.Current().Provider; p != "" {
+		return strings.ToLower(p)
+	}
+	if p := os.Getenv("TRANSCRIPTION_PROVIDER"); p != "" {
+		return strings.ToLower(p)
+	}
+	return "deepgram"
+}
+
+// SentimentProvider reads SENTIMENT_PROVIDER: "deepgram" scores sentiment
+// as part of transcription, "llm" asks the LLM afterwards, and "" (the
+// default) or "none" turns sentiment analysis off.
+func SentimentProvider() string {
+	switch p := strings.ToLower(strings.TrimSpace(os.Getenv("SENTIMENT_PROVIDER"))); p {
+	case "", "none":
+		return ""
+	case "deepgram", "llm":
+		return p
+	default:
+		logger.Warn.Printf("⚠️ Invalid SENTIMENT_PROVIDER %q, using default", p)
+		return ""
+	}
+}
+
+// language returns the transcription language from the tenant config or
+// TRANSCRIPTION_LANGUAGE, defaulting to en-US. "auto" has Deepgram detect
+// the language of each recording.
+func language() string {
+	if l := tenant.Current().Language; l != "" {
+		return l
+	}
+	if l := os.Getenv("TRANSCRIPTION_LANGUAGE"); l != "" {
+		return l
+	}
+	return "en-US"
+}
+
+// Deepgram transcribes audio with the Deepgram API, reading its key through
+// Secrets (secret.Default when nil).
+type Deepgram struct {
+	Secrets secret.Loader
+}
+
+func (d *Deepgram) secrets() secret.Loader {
+	if d.Secrets == nil {
+		return secret.Default
+	}
+	return d.Secrets
+}
+
+// Transcribe transcribes the audio file with the default Deepgram client.
+func Transcribe(ctx context.Context, audioPath, mimeType string) (*Result, error) {
+	return (&Deepgram{}).Transcribe(ctx, audioPath, mimeType)
+}
+
+func (d *Deepgram) Transcribe(ctx context.Context, audioPath, mimeType string) (*Result, error) {
+	if p := Provider(); p != "deepgram" {
+		return nil, fmt.Errorf("unsupported transcription provider %q", p)
+	}
+
 	// Get API key from Secret Manager
-	apiKey, err := secret.LoadSecret(ctx, "deepgram-api-key")
+	apiKey, err := d.secrets().Load(ctx, "deepgram-api-key")
 	if err != nil {
-		return fmt.Errorf("failed to load Deepgram API key: %w", err)
+		return nil, fmt.Errorf("failed to load Deepgram API key: %w", err)
 	}
 
 	// Read audio file
 	audioData, err := os.ReadFile(audioPath)
 	if err != nil {
-		return fmt.Errorf("failed to read audio file: %w", err)
+		return nil, fmt.Errorf("failed to read audio file: %w", err)
 	}
 
 	// Create HTTP client with timeout
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   30 * time.Second,
+		Transport: &requestid.Transport{},
 	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(
-		ctx,
-		"POST",
-		"https://api.deepgram.com/v1/listen?language=en-US&model=nova-2&smart_format=true",
-		bytes.NewReader(audioData),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	params := url.Values{}
+	lang := language()
+	if strings.EqualFold(lang, "auto") {
+		params.Set("detect_language", "true")
+	} else {
+		params.Set("language", lang)
+	}
+	params.Set("model", deepgramModel)
+	params.Set("smart_format", "true")
+	if SentimentProvider() == "deepgram" {
+		params.Set("sentiment", "true")
 	}
 
-	// Set headers
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", strings.TrimSpace(string(apiKey))))
-	req.Header.Set("Content-Type", "audio/wav")
-
-	// Send request
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("transcription request failed: %w", err)
+	if mimeType == "" || !strings.HasPrefix(mimeType, "audio/") {
+		mimeType = "audio/wav"
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+	if err := circuit().Allow(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("transcription failed with status %d: %s", resp.StatusCode, string(body))
+	// Send the request, retrying rate limits, server errors and network
+	// failures.
+	var (
+		body      []byte
+		transient bool
+	)
+	err = retry.Do(ctx, "Deepgram request", retryPolicy(), func(ctx context.Context) error {
+		transient = false
+		req, err := http.NewRequestWithContext(
+			ctx,
+			"POST",
+			"https://api.deepgram.com/v1/listen?"+params.Encode(),
+			bytes.NewReader(audioData),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Token %s", strings.TrimSpace(string(apiKey))))
+		req.Header.Set("Content-Type", mimeType)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			// Our own deadline or a cancelled caller says nothing about
+			// whether Deepgram is up.
+			if ctx.Err() != nil {
+				return fmt.Errorf("transcription request failed: %w", err)
+			}
+			transient = true
+			return retry.Temporary(fmt.Errorf("transcription request failed: %w", err), 0)
+		}
+		defer resp.Body.Close()
+
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("failed to read response: %w", err)
+			}
+			transient = true
+			return retry.Temporary(fmt.Errorf("failed to read response: %w", err), 0)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized {
+			// The key may have been rotated since it was cached.
+			secret.Invalidate("deepgram-api-key")
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("transcription failed with status %d: %s", resp.StatusCode, string(body))
+			if retry.StatusTemporary(resp.StatusCode) {
+				transient = true
+				return retry.Temporary(err, retry.RetryAfter(resp.Header))
+			}
+			return err
+		}
+		return nil
+	})
+	// Only outages count against the breaker; a rejected file means
+	// Deepgram is up, and a call given up by the caller says nothing either
+	// way.
+	switch {
+	case transient && err != nil:
+		circuit().Failure(err)
+	case err != nil && ctx.Err() != nil:
+		circuit().Cancel()
+	default:
+		circuit().Success()
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse response
 	var dgResp DeepgramResponse
 	if err := json.Unmarshal(body, &dgResp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	// Extract transcript
 	if len(dgResp.Results.Channels) == 0 ||
 		len(dgResp.Results.Channels[0].Alternatives) == 0 {
-		return fmt.Errorf("no transcription results found")
+		return nil, fmt.Errorf("no transcription results found")
 	}
 
-	transcript := dgResp.Results.Channels[0].Alternatives[0].Transcript
+	alt := dgResp.Results.Channels[0].Alternatives[0]
+	if detected := dgResp.Results.Channels[0].DetectedLanguage; detected != "" {
+		lang = detected
+	} else if strings.EqualFold(lang, "auto") {
+		lang = ""
+	}
+	transcript := alt.Transcript
 	if transcript == "" {
-		return fmt.Errorf("empty transcript received")
+		return nil, fmt.Errorf("empty transcript received")
 	}
 
-	logger.Info.Printf("🎯 Transcription successful: %s", transcript)
-
-	// Create email message
-	var message gmail.Message
-	emailBody := fmt.Sprintf("Transcription of voicemail from: %s\n\n%s", subject, transcript)
+	logger.Info.Printf("%s🎯 Transcription successful: %s", requestid.Prefix(ctx), logger.Transcript(transcript))
 
-	// RFC 2822 email formatting
-	emailTo := os.Getenv("EMAIL_RESPONSE_ADDRESS")
-	if emailTo == "" {
-		return fmt.Errorf("EMAIL_RESPONSE_ADDRESS not set")
+	duration := time.Duration(dgResp.Metadata.Duration * float64(time.Second))
+	if duration == 0 {
+		duration = wavDuration(audioData)
 	}
 
-	var msg bytes.Buffer
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", emailTo))
-	msg.WriteString(fmt.Sprintf("Subject: Voicemail Transcription: %s\r\n", subject))
-	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
-	msg.WriteString("\r\n")
-	msg.WriteString(emailBody)
+	return &Result{
+		Transcript:     transcript,
+		Duration:       duration,
+		Confidence:     alt.Confidence,
+		Provider:       "deepgram",
+		Language:       lang,
+		Sentiment:      dgResp.Results.Sentiments.Average.Sentiment,
+		SentimentScore: dgResp.Results.Sentiments.Average.SentimentScore,
+	}, nil
+}
 
-	// Encode the message
-	message.Raw = base64.URLEncoding.EncodeToString(msg.Bytes())
+// retryPolicy reads DEEPGRAM_MAX_RETRIES, DEEPGRAM_RETRY_BASE_DELAY and
+// DEEPGRAM_RETRY_MAX_DELAY.
+func retryPolicy() retry.Policy {
+	return retry.FromEnv("DEEPGRAM", retry.Policy{Retries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second})
+}
 
-	// Send the email
-	_, err = gmailSrv.Users.Messages.Send("me", &message).Do()
+// Ping checks that Deepgram is reachable and accepts the configured API key.
+func Ping(ctx context.Context) error {
+	apiKey, err := secret.LoadSecret(ctx, "deepgram-api-key")
 	if err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+		return fmt.Errorf("failed to load Deepgram API key: %w", err)
 	}
 
-	logger.Info.Printf("✉️ Transcription email sent successfully")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.deepgram.com/v1/projects", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", strings.TrimSpace(string(apiKey))))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deepgram unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("deepgram returned status %d", resp.StatusCode)
+	}
 	return nil
 }
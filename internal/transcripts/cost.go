@@ -0,0 +1,77 @@
+package transcripts
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Cost is the estimated transcription cost of a voicemail.
+type Cost struct {
+	Provider      string  `firestore:"provider" json:"provider"`
+	Model         string  `firestore:"model,omitempty" json:"model,omitempty"`
+	AudioSeconds  float64 `firestore:"audioSeconds" json:"audioSeconds"`
+	RatePerMinute float64 `firestore:"ratePerMinute" json:"ratePerMinute"`
+	USD           float64 `firestore:"usd" json:"usd"`
+}
+
+// NewCost estimates the cost of transcribing seconds of audio at
+// ratePerMinute.
+func NewCost(provider, model string, seconds, ratePerMinute float64) *Cost {
+	return &Cost{
+		Provider:      provider,
+		Model:         model,
+		AudioSeconds:  seconds,
+		RatePerMinute: ratePerMinute,
+		USD:           seconds / 60 * ratePerMinute,
+	}
+}
+
+// CostTotal sums the estimated costs of one provider's transcripts received
+// in a calendar month (UTC).
+type CostTotal struct {
+	Month        string  `json:"month"`
+	Provider     string  `json:"provider"`
+	Messages     int     `json:"messages"`
+	AudioSeconds float64 `json:"audioSeconds"`
+	USD          float64 `json:"usd"`
+}
+
+// CostTotals sums the costs of the transcripts received in [from, to) by
+// month and provider, oldest month first. Transcripts stored before costs
+// were recorded are left out.
+func CostTotals(ctx context.Context, client *firestore.Client, from, to time.Time) ([]CostTotal, error) {
+	totals := map[[2]string]*CostTotal{}
+	err := Each(ctx, client, from, to, func(rec *Record) error {
+		if rec.Cost == nil {
+			return nil
+		}
+		key := [2]string{rec.ReceivedAt.UTC().Format("2006-01"), rec.Cost.Provider}
+		t := totals[key]
+		if t == nil {
+			t = &CostTotal{Month: key[0], Provider: key[1]}
+			totals[key] = t
+		}
+		t.Messages++
+		t.AudioSeconds += rec.Cost.AudioSeconds
+		t.USD += rec.Cost.USD
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CostTotal, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Month != result[j].Month {
+			return result[i].Month < result[j].Month
+		}
+		return result[i].Provider < result[j].Provider
+	})
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package transcripts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/firestore"
+)
+
+// maxQueryTokens is Firestore's limit on array-contains-any values.
+const maxQueryTokens = 30
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "from": true, "had": true, "has": true, "have": true, "hi": true, "i": true,
+	"if": true, "in": true, "is": true, "it": true, "its": true, "just": true, "me": true, "my": true,
+	"of": true, "on": true, "or": true, "so": true, "that": true, "the": true, "this": true, "to": true,
+	"um": true, "uh": true, "was": true, "we": true, "were": true, "with": true, "you": true, "your": true,
+}
+
+// Tokenize splits text into the lower-cased, de-duplicated words used by the
+// search index, dropping stop words and single characters.
+func Tokenize(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		word = strings.Trim(word, "'")
+		if len([]rune(word)) < 2 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// keywords builds the search index for a record from its transcript, caller
+// and subject.
+func keywords(rec *Record) []string {
+	tokens := Tokenize(strings.Join([]string{rec.Transcript, rec.Subject, rec.Carrier}, " "))
+	if rec.Caller != "" {
+		tokens = append(tokens, rec.Caller)
+	}
+	return tokens
+}
+
+// SearchResult is a transcript matching a search, with the number of query
+// words it contains.
+type SearchResult struct {
+	Record
+	Score int `json:"score"`
+}
+
+// Search returns transcripts containing any of the words in query, best
+// matches first and newest first among equal matches.
+func Search(ctx context.Context, client *firestore.Client, query string, limit int) ([]SearchResult, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 25
+	}
+
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	if len(tokens) > maxQueryTokens {
+		tokens = tokens[:maxQueryTokens]
+	}
+
+	docs, err := client.Collection(Collection).
+		Where("keywords", "array-contains-any", tokens).
+		Limit(500).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(docs))
+	for _, doc := range docs {
+		rec, err := fromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Record: *rec, Score: score(rec.Keywords, tokens)})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ReceivedAt.After(results[j].ReceivedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func score(keywords, tokens []string) int {
+	have := make(map[string]bool, len(keywords))
+	for _, k := range keywords {
+		have[k] = true
+	}
+	n := 0
+	for _, t := range tokens {
+		if have[t] {
+			n++
+		}
+	}
+	return n
+}
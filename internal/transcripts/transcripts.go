@@ -0,0 +1,306 @@
+package transcripts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"voicemail-transcriber-production/internal/logger"
+	"voicemail-transcriber-production/internal/voicemail"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const Collection = "transcripts"
+
+const (
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+	// StatusDryRun marks voicemails transcribed with DRY_RUN set, which
+	// were never delivered.
+	StatusDryRun = "dry_run"
+)
+
+// Record is the stored result of processing one voicemail message.
+type Record struct {
+	ID              string      `firestore:"-" json:"id"`
+	MessageID       string      `firestore:"messageId" json:"messageId"`
+	ThreadID        string      `firestore:"threadId" json:"threadId,omitempty"`
+	Caller          string      `firestore:"caller" json:"caller,omitempty"`
+	ContactName     string      `firestore:"contactName,omitempty" json:"contactName,omitempty"`
+	Carrier         string      `firestore:"carrier" json:"carrier,omitempty"`
+	Mailbox         string      `firestore:"mailbox" json:"mailbox,omitempty"`
+	Subject         string      `firestore:"subject" json:"subject"`
+	ReceivedAt      time.Time   `firestore:"receivedAt" json:"receivedAt"`
+	DurationSeconds float64     `firestore:"durationSeconds" json:"durationSeconds"`
+	Transcript      string      `firestore:"transcript" json:"transcript"`
+	Confidence      float64     `firestore:"confidence" json:"confidence"`
+	Provider        string      `firestore:"provider" json:"provider"`
+	Status          string      `firestore:"status" json:"status"`
+	Errors          []string    `firestore:"errors" json:"errors,omitempty"`
+	Recordings      []Recording `firestore:"recordings" json:"recordings"`
+	// Urgent is set when the transcript contains any of the urgency
+	// keywords, which UrgencyKeywords lists.
+	Urgent          bool     `firestore:"urgent" json:"urgent"`
+	UrgencyKeywords []string `firestore:"urgencyKeywords,omitempty" json:"urgencyKeywords,omitempty"`
+	ActionItems     []string `firestore:"actionItems,omitempty" json:"actionItems,omitempty"`
+	Sentiment       string   `firestore:"sentiment,omitempty" json:"sentiment,omitempty"`
+	SentimentScore  float64  `firestore:"sentimentScore" json:"sentimentScore"`
+	// Cost is the estimated transcription cost.
+	Cost *Cost `firestore:"cost,omitempty" json:"cost,omitempty"`
+	// DeliveryFailures lists the bounces received for the transcription
+	// email.
+	DeliveryFailures []DeliveryFailure `firestore:"deliveryFailures,omitempty" json:"deliveryFailures,omitempty"`
+	// Keywords is the search index built from the transcript.
+	Keywords  []string  `firestore:"keywords" json:"-"`
+	CreatedAt time.Time `firestore:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `firestore:"updatedAt" json:"updatedAt"`
+}
+
+// Recording is the stored transcription of one audio attachment.
+type Recording struct {
+	Filename        string  `firestore:"filename" json:"filename"`
+	Transcript      string  `firestore:"transcript" json:"transcript"`
+	DurationSeconds float64 `firestore:"durationSeconds" json:"durationSeconds"`
+	Confidence      float64 `firestore:"confidence" json:"confidence"`
+	AudioObject     string  `firestore:"audioObject,omitempty" json:"-"`
+	Language        string  `firestore:"language,omitempty" json:"language,omitempty"`
+	Translation     string  `firestore:"translation,omitempty" json:"translation,omitempty"`
+	Sentiment       string  `firestore:"sentiment,omitempty" json:"sentiment,omitempty"`
+	SentimentScore  float64 `firestore:"sentimentScore,omitempty" json:"sentimentScore,omitempty"`
+	// AudioURL is a short-lived signed link to the archived audio, filled in
+	// when the record is served.
+	AudioURL string `firestore:"-" json:"audioUrl,omitempty"`
+}
+
+// DeliveryFailure is a bounce reported for a transcription email.
+type DeliveryFailure struct {
+	Recipient string    `firestore:"recipient" json:"recipient"`
+	Reason    string    `firestore:"reason" json:"reason,omitempty"`
+	BounceID  string    `firestore:"bounceId" json:"bounceId"`
+	At        time.Time `firestore:"at" json:"at"`
+}
+
+// NewRecord builds a record from a processed voicemail. Confidence is the
+// duration-weighted average across recordings.
+func NewRecord(vm *voicemail.Voicemail, provider, status string, errs []string) *Record {
+	rec := &Record{
+		ID:              vm.MessageID,
+		MessageID:       vm.MessageID,
+		ThreadID:        vm.ThreadID,
+		Caller:          vm.Caller,
+		Carrier:         vm.Carrier,
+		Mailbox:         vm.Mailbox,
+		Subject:         vm.Subject,
+		ReceivedAt:      vm.ReceivedAt,
+		DurationSeconds: vm.TotalDuration().Seconds(),
+		Transcript:      vm.Transcript(),
+		Provider:        provider,
+		Status:          status,
+		Errors:          errs,
+		Urgent:          vm.Urgent(),
+		UrgencyKeywords: vm.UrgencyKeywords,
+		ActionItems:     vm.ActionItems,
+		Sentiment:       vm.Sentiment,
+		SentimentScore:  vm.SentimentScore,
+	}
+
+	var weighted, weight float64
+	for _, r := range vm.Recordings {
+		rec.Recordings = append(rec.Recordings, Recording{
+			Filename:        r.Filename,
+			Transcript:      r.Transcript,
+			DurationSeconds: r.Duration.Seconds(),
+			Confidence:      r.Confidence,
+			AudioObject:     r.AudioObject,
+			Language:        r.Language,
+			Translation:     r.Translation,
+			Sentiment:       r.Sentiment,
+			SentimentScore:  r.SentimentScore,
+		})
+		w := r.Duration.Seconds()
+		if w == 0 {
+			w = 1
+		}
+		weighted += r.Confidence * w
+		weight += w
+	}
+	if vm.Contact != nil {
+		rec.ContactName = vm.Contact.Name
+	}
+	if weight > 0 {
+		rec.Confidence = weighted / weight
+	}
+	rec.Keywords = keywords(rec)
+	return rec
+}
+
+// Save writes the record, keyed by Gmail message ID, preserving the original
+// creation time when the message is processed again.
+func Save(ctx context.Context, client *firestore.Client, rec *Record) error {
+	ref := client.Collection(Collection).Doc(rec.ID)
+	now := time.Now()
+	rec.UpdatedAt = now
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		rec.CreatedAt = now
+		if doc, err := tx.Get(ref); err == nil {
+			if created, ok := doc.Data()["createdAt"].(time.Time); ok {
+				rec.CreatedAt = created
+			}
+		}
+		return tx.Set(ref, rec)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save transcript %s: %w", rec.ID, err)
+	}
+
+	logger.Info.Printf("🗄️ Stored transcript %s (status: %s)", rec.ID, rec.Status)
+	return nil
+}
+
+// AddDeliveryFailure appends f to the transcript stored under id, once per
+// bounce message.
+func AddDeliveryFailure(ctx context.Context, client *firestore.Client, id string, f DeliveryFailure) error {
+	ref := client.Collection(Collection).Doc(id)
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var rec Record
+		if err := doc.DataTo(&rec); err != nil {
+			return err
+		}
+		for _, existing := range rec.DeliveryFailures {
+			if existing.BounceID == f.BounceID && existing.Recipient == f.Recipient {
+				return nil
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "deliveryFailures", Value: append(rec.DeliveryFailures, f)},
+			{Path: "updatedAt", Value: time.Now()},
+		})
+	})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record delivery failure on transcript %s: %w", id, err)
+	}
+	return nil
+}
+
+// ErrNotFound is returned by Get when no transcript has the given ID.
+var ErrNotFound = errors.New("transcript not found")
+
+// Get returns the transcript stored under id.
+func Get(ctx context.Context, client *firestore.Client, id string) (*Record, error) {
+	doc, err := client.Collection(Collection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transcript %s: %w", id, err)
+	}
+	return fromDoc(doc)
+}
+
+// ListOptions filters and pages a transcript listing. Results are ordered
+// newest first.
+type ListOptions struct {
+	From      time.Time
+	To        time.Time
+	Caller    string
+	Limit     int
+	PageToken string
+}
+
+// List returns one page of transcripts and the token for the next page, which
+// is empty on the last page.
+func List(ctx context.Context, client *firestore.Client, opts ListOptions) ([]Record, string, error) {
+	if opts.Limit <= 0 || opts.Limit > 100 {
+		opts.Limit = 25
+	}
+
+	q := client.Collection(Collection).Query
+	if opts.Caller != "" {
+		q = q.Where("caller", "==", voicemail.NormalizeNumber(opts.Caller))
+	}
+	if !opts.From.IsZero() {
+		q = q.Where("receivedAt", ">=", opts.From)
+	}
+	if !opts.To.IsZero() {
+		q = q.Where("receivedAt", "<", opts.To)
+	}
+	q = q.OrderBy("receivedAt", firestore.Desc).Limit(opts.Limit + 1)
+
+	if opts.PageToken != "" {
+		cursor, err := client.Collection(Collection).Doc(opts.PageToken).Get(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid page token: %w", err)
+		}
+		q = q.StartAfter(cursor)
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list transcripts: %w", err)
+	}
+
+	next := ""
+	if len(docs) > opts.Limit {
+		docs = docs[:opts.Limit]
+		next = docs[len(docs)-1].Ref.ID
+	}
+
+	records := make([]Record, 0, len(docs))
+	for _, doc := range docs {
+		rec, err := fromDoc(doc)
+		if err != nil {
+			return nil, "", err
+		}
+		records = append(records, *rec)
+	}
+	return records, next, nil
+}
+
+func fromDoc(doc *firestore.DocumentSnapshot) (*Record, error) {
+	var rec Record
+	if err := doc.DataTo(&rec); err != nil {
+		return nil, fmt.Errorf("failed to decode transcript %s: %w", doc.Ref.ID, err)
+	}
+	rec.ID = doc.Ref.ID
+	return &rec, nil
+}
+
+// Each calls fn for every transcript received in [from, to), oldest first,
+// stopping at the first error.
+func Each(ctx context.Context, client *firestore.Client, from, to time.Time, fn func(*Record) error) error {
+	iter := client.Collection(Collection).
+		Where("receivedAt", ">=", from).
+		Where("receivedAt", "<", to).
+		OrderBy("receivedAt", firestore.Asc).
+		Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read transcripts: %w", err)
+		}
+		rec, err := fromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
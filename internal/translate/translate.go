@@ -0,0 +1,80 @@
+// Package translate translates transcripts of voicemails left in other
+// languages with the Cloud Translation API.
+package translate
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"sync"
+
+	translate "google.golang.org/api/translate/v2"
+)
+
+var (
+	service     *translate.Service
+	serviceLock sync.Mutex
+)
+
+// Enabled reads TRANSLATION_ENABLED.
+func Enabled() bool {
+	return strings.EqualFold(os.Getenv("TRANSLATION_ENABLED"), "true")
+}
+
+// Target reads TRANSLATION_TARGET, the language transcripts are translated
+// into, defaulting to "en".
+func Target() string {
+	if t := strings.TrimSpace(os.Getenv("TRANSLATION_TARGET")); t != "" {
+		return t
+	}
+	return "en"
+}
+
+// Needed reports whether a transcript in language (a code such as "es" or
+// "en-GB") should be translated: it is known and differs from the target.
+func Needed(language string) bool {
+	if language == "" {
+		return false
+	}
+	return !strings.EqualFold(base(language), base(Target()))
+}
+
+func base(language string) string {
+	code, _, _ := strings.Cut(language, "-")
+	return code
+}
+
+func client(ctx context.Context) (*translate.Service, error) {
+	serviceLock.Lock()
+	defer serviceLock.Unlock()
+	if service == nil {
+		srv, err := translate.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Translation service: %w", err)
+		}
+		service = srv
+	}
+	return service, nil
+}
+
+// Text translates text from source into the target language.
+func Text(ctx context.Context, text, source string) (string, error) {
+	srv, err := client(ctx)
+	if err != nil {
+		return "", err
+	}
+	resp, err := srv.Translations.List([]string{text}, Target()).
+		Source(base(source)).
+		Format("text").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to translate from %s: %w", source, err)
+	}
+	if len(resp.Translations) == 0 {
+		return "", fmt.Errorf("translation returned no results")
+	}
+	return html.UnescapeString(resp.Translations[0].TranslatedText), nil
+}
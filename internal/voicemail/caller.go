@@ -0,0 +1,43 @@
+package voicemail
+
+import "regexp"
+
+// Withheld is reported as the caller when the carrier says the number was
+// withheld or unavailable.
+const Withheld = "Withheld"
+
+var (
+	// callerPatterns match the phrasing carriers use to introduce the
+	// caller's number, in order of preference.
+	callerPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?:voicemail|voice message|message|missed call|call)\s+(?:received\s+)?from[:\s]+(\+?\d[\d \-().]{6,}\d)`),
+		regexp.MustCompile(`(?i)caller(?:\s*id|\s+number|'s number)?\s*[:\-]\s*(\+?\d[\d \-().]{6,}\d)`),
+		regexp.MustCompile(`(?i)(?:from|calling)\s+(?:number|no\.?)[:\s]+(\+?\d[\d \-().]{6,}\d)`),
+	}
+	withheldPattern = regexp.MustCompile(`(?i)\b(?:number withheld|withheld number|caller withheld|private number|unknown caller|no caller id|number unavailable)\b`)
+)
+
+// ExtractCaller finds the caller's number in a voicemail notification,
+// looking first for carrier phrasing in the subject then the body, and
+// finally for any phone number in either. It returns Withheld when the
+// carrier reports a hidden number and "" when nothing is found.
+func ExtractCaller(subject, body string) string {
+	for _, text := range []string{subject, body} {
+		for _, re := range callerPatterns {
+			if m := re.FindStringSubmatch(text); m != nil {
+				return NormalizeNumber(m[1])
+			}
+		}
+	}
+
+	for _, text := range []string{subject, body} {
+		if withheldPattern.MatchString(text) {
+			return Withheld
+		}
+	}
+
+	if n := NumberFromText(subject); n != "" {
+		return n
+	}
+	return NumberFromText(body)
+}
@@ -0,0 +1,93 @@
+package voicemail
+
+import "testing"
+
+func TestExtractCaller(t *testing.T) {
+	tests := []struct {
+		name          string
+		subject, body string
+		want          string
+	}{
+		{
+			name:    "subject phrasing",
+			subject: "New voicemail from 07123 456789",
+			want:    "07123456789",
+		},
+		{
+			name:    "next line starts with digits",
+			subject: "Voicemail from 07123 456789\n12 March 2024 at 10:15",
+			want:    "07123456789",
+		},
+		{
+			name: "body line followed by a date",
+			body: "Caller: 07123 456789\n12/03/2024 10:15\nDuration: 0:42",
+			want: "07123456789",
+		},
+		{
+			name: "bare number followed by digits on the next line",
+			body: "Call us back on 020 7946 0000\n2024 Acme Ltd",
+			want: "02079460000",
+		},
+		{
+			name:    "subject preferred over body",
+			subject: "Voicemail from +44 7123 456789",
+			body:    "Caller: 07999 000111",
+			want:    "07123456789",
+		},
+		{
+			name:    "UK international with trunk zero",
+			subject: "Missed call from +44 (0)20 7946 0000",
+			want:    "02079460000",
+		},
+		{
+			name: "US number",
+			body: "Voice message from +1 (415) 555-0100",
+			want: "+14155550100",
+		},
+		{
+			name: "dotted French number",
+			body: "Caller ID: +33 1.23.45.67.89",
+			want: "+33123456789",
+		},
+		{
+			name:    "withheld",
+			subject: "Voicemail from a private number",
+			want:    Withheld,
+		},
+		{
+			name:    "nothing found",
+			subject: "Your bill is ready",
+			body:    "Ref 1234",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractCaller(tt.subject, tt.body); got != tt.want {
+				t.Errorf("ExtractCaller(%q, %q) = %q, want %q", tt.subject, tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"07123 456789", "07123456789"},
+		{"+44 7123 456789", "07123456789"},
+		{"+44 (0)7123 456789", "07123456789"},
+		{"0044 7123-456789", "07123456789"},
+		{"(020) 7946 0000", "02079460000"},
+		{"+1 (415) 555-0100", "+14155550100"},
+		{"+353 1 234 5678", "+35312345678"},
+		{" +33 1.23.45.67.89 ", "+33123456789"},
+		{"44+7123", "447123"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeNumber(tt.in); got != tt.want {
+			t.Errorf("NormalizeNumber(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
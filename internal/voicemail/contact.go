@@ -0,0 +1,12 @@
+package voicemail
+
+import "time"
+
+// Contact is the caller's record in the business's CRM.
+type Contact struct {
+	ID      string
+	Name    string
+	Company string
+	// LastAppointment is zero when the CRM has none.
+	LastAppointment time.Time
+}
@@ -0,0 +1,25 @@
+package voicemail
+
+import (
+	"fmt"
+	"time"
+)
+
+// FormatDuration renders a recording length the way staff read it, e.g.
+// "1m 42s" or "38s".
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// TotalDuration is the combined length of every recording on the voicemail.
+func (v *Voicemail) TotalDuration() time.Duration {
+	var total time.Duration
+	for _, rec := range v.Recordings {
+		total += rec.Duration
+	}
+	return total
+}
@@ -0,0 +1,35 @@
+package voicemail
+
+import (
+	"regexp"
+	"strings"
+)
+
+var phonePattern = regexp.MustCompile(`\+?\d[\d \-().]{6,}\d`)
+
+// NormalizeNumber reduces a phone number to its digits in UK national format,
+// so "+44 7123 456789", "+44 (0)7123 456789", "0044 7123-456789" and
+// "07123 456789" compare equal. Other international numbers keep their "+".
+func NormalizeNumber(number string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		if r >= '0' && r <= '9' || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	n := b.String()
+
+	switch {
+	case strings.HasPrefix(n, "+44"):
+		return "0" + strings.TrimPrefix(n[3:], "0")
+	case strings.HasPrefix(n, "0044"):
+		return "0" + strings.TrimPrefix(n[4:], "0")
+	}
+	return n
+}
+
+// NumberFromText returns the first phone number found in s, normalized, or
+// "" when there is none.
+func NumberFromText(s string) string {
+	return NormalizeNumber(phonePattern.FindString(s))
+}
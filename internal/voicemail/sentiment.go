@@ -0,0 +1,41 @@
+package voicemail
+
+// SentimentLabel names a sentiment score the way Deepgram does: above 0.333
+// is positive, below -0.333 negative and anything between neutral.
+func SentimentLabel(score float64) string {
+	switch {
+	case score > 0.333:
+		return "positive"
+	case score < -0.333:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+// RecordingSentiment averages the sentiment scored on each recording,
+// weighted by duration. ok is false when no recording was scored.
+func (v *Voicemail) RecordingSentiment() (label string, score float64, ok bool) {
+	var weighted, weight float64
+	for _, rec := range v.Recordings {
+		if rec.Sentiment == "" {
+			continue
+		}
+		w := rec.Duration.Seconds()
+		if w == 0 {
+			w = 1
+		}
+		weighted += rec.SentimentScore * w
+		weight += w
+	}
+	if weight == 0 {
+		return "", 0, false
+	}
+	score = weighted / weight
+	return SentimentLabel(score), score, true
+}
+
+// Negative reports whether the voicemail was scored as negative.
+func (v *Voicemail) Negative() bool {
+	return v.Sentiment == "negative"
+}
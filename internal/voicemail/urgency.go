@@ -0,0 +1,29 @@
+package voicemail
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FindKeywords returns the keywords that occur in the transcript, or its
+// translation, as whole words or phrases, ignoring case.
+func (v *Voicemail) FindKeywords(keywords []string) []string {
+	transcript := v.Transcript() + "\n\n" + v.Translations()
+	var found []string
+	for _, kw := range keywords {
+		kw = strings.TrimSpace(kw)
+		if kw == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(?i)\b` + strings.Join(strings.Fields(regexp.QuoteMeta(kw)), `\s+`) + `\b`)
+		if re.MatchString(transcript) {
+			found = append(found, kw)
+		}
+	}
+	return found
+}
+
+// Urgent reports whether urgency keywords were found in the transcript.
+func (v *Voicemail) Urgent() bool {
+	return len(v.UrgencyKeywords) > 0
+}
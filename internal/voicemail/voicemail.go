@@ -0,0 +1,104 @@
+package voicemail
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Recording is a single transcribed audio attachment.
+type Recording struct {
+	Filename   string
+	Transcript string
+	Duration   time.Duration
+	Confidence float64
+	// AudioObject is the Cloud Storage object the audio was archived to.
+	AudioObject string
+	// Language is the spoken language, when known.
+	Language string
+	// Translation is the transcript translated into the target language,
+	// for recordings left in another language.
+	Translation string
+	// Sentiment is "positive", "neutral" or "negative" when it was scored,
+	// with SentimentScore from -1 (most negative) to 1.
+	Sentiment      string
+	SentimentScore float64
+}
+
+// Voicemail is a voicemail email together with the transcriptions of its
+// audio attachments.
+type Voicemail struct {
+	MessageID string
+	ThreadID  string
+	// Account is the Gmail mailbox the voicemail arrived in.
+	Account string
+	// RFC822MessageID is the Message-ID header of the original email, used
+	// to thread the transcription reply.
+	RFC822MessageID string
+	References      string
+	Subject         string
+	// Caller is the caller's phone number in normalized national format.
+	Caller string
+	// Carrier names the provider whose notification format was recognised.
+	Carrier string
+	// Contact is the caller's CRM record, when one matched.
+	Contact *Contact
+	// Mailbox is the number the voicemail was left on, when the carrier
+	// reports it.
+	Mailbox    string
+	ReceivedAt time.Time
+	// AudioURL links to an archived copy of the recording, when one exists.
+	AudioURL   string
+	Recordings []Recording
+	// UrgencyKeywords are the urgency keywords found in the transcript;
+	// empty unless the voicemail is urgent.
+	UrgencyKeywords []string
+	// ActionItems are the caller's requests, when they were extracted.
+	ActionItems []string
+	// Sentiment and SentimentScore rate the whole voicemail, as on
+	// Recording.
+	Sentiment      string
+	SentimentScore float64
+}
+
+// Transcript joins the transcripts of every recording.
+func (v *Voicemail) Transcript() string {
+	transcripts := make([]string, 0, len(v.Recordings))
+	for _, rec := range v.Recordings {
+		transcripts = append(transcripts, rec.Transcript)
+	}
+	return strings.Join(transcripts, "\n\n")
+}
+
+// Translations joins the translations of every translated recording.
+func (v *Voicemail) Translations() string {
+	var translations []string
+	for _, rec := range v.Recordings {
+		if rec.Translation != "" {
+			translations = append(translations, rec.Translation)
+		}
+	}
+	return strings.Join(translations, "\n\n")
+}
+
+// Link returns a URL where the original recording can be listened to: the
+// archived audio when available, otherwise the Gmail message.
+func (v *Voicemail) Link() string {
+	if v.AudioURL != "" {
+		return v.AudioURL
+	}
+	return v.GmailLink()
+}
+
+// GmailLink returns a URL that opens the original message in Gmail, signed
+// in as Account when it is known.
+func (v *Voicemail) GmailLink() string {
+	if v.MessageID == "" {
+		return ""
+	}
+	account := "0"
+	if v.Account != "" {
+		account = url.PathEscape(v.Account)
+	}
+	return "https://mail.google.com/mail/u/" + account + "/#all/" + v.MessageID
+}